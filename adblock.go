@@ -0,0 +1,615 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	defaultAdBlockReloadInterval = 24 * time.Hour
+	adBlockFetchTimeout          = 30 * time.Second
+	maxAdBlockListBytes          = 20 << 20
+)
+
+// defaultAdFilters 为内置的精简过滤规则（未配置 ADBLOCK_LISTS 或全部加载失败时使用）。
+//
+//go:embed adblock/default.txt
+var defaultAdFilters []byte
+
+// adFilters 为当前生效的过滤规则，后台重新加载时整体替换。
+var adFilters atomic.Pointer[adFilterEngine]
+
+// 规则适用的资源类型（$script、$image 等）。
+const (
+	adTypeScript uint32 = 1 << iota
+	adTypeImage
+	adTypeStylesheet
+	adTypeXHR
+	adTypeSubdocument
+	adTypeFont
+	adTypeMedia
+	adTypeObject
+	adTypePing
+	adTypeWebSocket
+	adTypeOther
+	adTypeDocument
+
+	// 未指定类型的规则适用于除主文档外的所有请求
+	adTypeDefault = adTypeDocument - 1
+)
+
+var adTypeNames = map[string]uint32{
+	"script":         adTypeScript,
+	"image":          adTypeImage,
+	"stylesheet":     adTypeStylesheet,
+	"xmlhttprequest": adTypeXHR,
+	"xhr":            adTypeXHR,
+	"subdocument":    adTypeSubdocument,
+	"frame":          adTypeSubdocument,
+	"font":           adTypeFont,
+	"media":          adTypeMedia,
+	"object":         adTypeObject,
+	"ping":           adTypePing,
+	"beacon":         adTypePing,
+	"websocket":      adTypeWebSocket,
+	"other":          adTypeOther,
+	"document":       adTypeDocument,
+	"doc":            adTypeDocument,
+}
+
+// adRule 为一条 EasyList 网络过滤规则。
+type adRule struct {
+	pattern     string         // 去掉锚点后的模式（* 通配，^ 分隔符）；match-case 以外均为小写
+	re          *regexp.Regexp // /正则/ 规则
+	hostAnchor  bool           // ||
+	startAnchor bool           // |...
+	endAnchor   bool           // ...|
+	matchCase   bool
+	types       uint32
+	thirdParty  int8 // 1 仅第三方，-1 仅第一方，0 不限
+	domains     []string
+	notDomains  []string
+}
+
+// adRuleSet 按规则中的一个完整 token 建立索引，匹配时只检查 URL 中出现的 token 对应的规则。
+type adRuleSet struct {
+	byToken map[string][]*adRule
+	generic []*adRule
+}
+
+func (s *adRuleSet) add(r *adRule) {
+	if tok := r.token(); tok != "" {
+		if s.byToken == nil {
+			s.byToken = make(map[string][]*adRule)
+		}
+		s.byToken[tok] = append(s.byToken[tok], r)
+		return
+	}
+	s.generic = append(s.generic, r)
+}
+
+func (s *adRuleSet) match(req *adRequest) bool {
+	for _, tok := range req.tokens {
+		for _, r := range s.byToken[tok] {
+			if r.matches(req) {
+				return true
+			}
+		}
+	}
+	for _, r := range s.generic {
+		if r.matches(req) {
+			return true
+		}
+	}
+	return false
+}
+
+// adFilterEngine 为解析后的过滤规则：命中 block 且未命中例外（@@）规则的请求被拦截。
+type adFilterEngine struct {
+	block    adRuleSet
+	allow    adRuleSet
+	rules    int
+	skipped  int
+	sources  []string
+	loadedAt time.Time
+}
+
+func (f *adFilterEngine) blocks(req *adRequest) bool {
+	return f.block.match(req) && !f.allow.match(req)
+}
+
+// add 解析过滤列表并加入规则。不支持的规则（元素隐藏、$csp / $redirect / $popup 等选项、无法编译的正则）被跳过。
+func (f *adFilterEngine) add(data []byte) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		r, allow := parseAdRule(line)
+		if r == nil {
+			f.skipped++
+			continue
+		}
+		if allow {
+			f.allow.add(r)
+		} else {
+			f.block.add(r)
+		}
+		f.rules++
+	}
+}
+
+func (f *adFilterEngine) stats() map[string]interface{} {
+	return map[string]interface{}{
+		"rules":     f.rules,
+		"skipped":   f.skipped,
+		"sources":   f.sources,
+		"loaded_at": f.loadedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// parseAdRule 解析一行网络过滤规则；返回 nil 表示跳过。allow 为例外规则（@@）。
+func parseAdRule(line string) (*adRule, bool) {
+	for _, sep := range []string{"##", "#@#", "#?#", "#$#", "#%#"} {
+		if strings.Contains(line, sep) {
+			return nil, false
+		}
+	}
+	allow := strings.HasPrefix(line, "@@")
+	line = strings.TrimPrefix(line, "@@")
+
+	r := &adRule{types: adTypeDefault}
+	if i := strings.LastIndex(line, "$"); i >= 0 && !strings.Contains(line[i+1:], "/") {
+		if !r.parseOptions(line[i+1:]) {
+			return nil, allow
+		}
+		line = line[:i]
+	}
+
+	if len(line) > 2 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") {
+		expr := line[1 : len(line)-1]
+		if !r.matchCase {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, allow
+		}
+		r.re = re
+		return r, allow
+	}
+
+	switch {
+	case strings.HasPrefix(line, "||"):
+		r.hostAnchor, line = true, line[2:]
+	case strings.HasPrefix(line, "|"):
+		r.startAnchor, line = true, line[1:]
+	}
+	if strings.HasSuffix(line, "|") {
+		r.endAnchor, line = true, line[:len(line)-1]
+	}
+	if !r.hostAnchor && !r.startAnchor {
+		line = strings.TrimLeft(line, "*")
+	}
+	if !r.endAnchor {
+		line = strings.TrimRight(line, "*")
+	}
+	// 空模式匹配所有请求，只接受限定了域名的规则
+	if line == "" && len(r.domains) == 0 {
+		return nil, allow
+	}
+	if !r.matchCase {
+		line = strings.ToLower(line)
+	}
+	r.pattern = line
+	return r, allow
+}
+
+// parseOptions 解析 $ 之后的选项；包含不支持的选项时返回 false（整条规则跳过，避免误拦截）。
+func (r *adRule) parseOptions(opts string) bool {
+	var include, exclude uint32
+	for _, opt := range strings.Split(opts, ",") {
+		opt = strings.ToLower(strings.TrimSpace(opt))
+		switch {
+		case opt == "third-party" || opt == "3p":
+			r.thirdParty = 1
+		case opt == "~third-party" || opt == "first-party" || opt == "1p":
+			r.thirdParty = -1
+		case opt == "match-case":
+			r.matchCase = true
+		case opt == "important":
+		case strings.HasPrefix(opt, "domain="):
+			for _, d := range strings.Split(opt[len("domain="):], "|") {
+				if strings.HasPrefix(d, "~") {
+					r.notDomains = append(r.notDomains, d[1:])
+				} else if d != "" {
+					r.domains = append(r.domains, d)
+				}
+			}
+		case strings.HasPrefix(opt, "~") && adTypeNames[opt[1:]] != 0:
+			exclude |= adTypeNames[opt[1:]]
+		case adTypeNames[opt] != 0:
+			include |= adTypeNames[opt]
+		default:
+			return false
+		}
+	}
+	if include != 0 {
+		r.types = include
+	}
+	r.types &^= exclude
+	return r.types != 0
+}
+
+// token 返回模式中一个两侧都是分隔边界的 token（在 URL 中必然以完整 token 出现），用于索引；没有时为空。
+func (r *adRule) token() string {
+	if r.re != nil {
+		return ""
+	}
+	p := strings.ToLower(r.pattern)
+	best := ""
+	for i := 0; i < len(p); {
+		if !isTokenChar(p[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(p) && isTokenChar(p[j]) {
+			j++
+		}
+		startOK := (i == 0 && (r.hostAnchor || r.startAnchor)) || (i > 0 && p[i-1] != '*')
+		endOK := (j == len(p) && r.endAnchor) || (j < len(p) && p[j] != '*')
+		if startOK && endOK && j-i > len(best) && !commonURLTokens[p[i:j]] {
+			best = p[i:j]
+		}
+		i = j
+	}
+	return best
+}
+
+// commonURLTokens 几乎出现在所有 URL 中，不用于索引。
+var commonURLTokens = map[string]bool{"http": true, "https": true, "www": true, "com": true, "net": true, "org": true, "js": true}
+
+func isTokenChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '%'
+}
+
+// isSeparatorChar 为 ^ 可匹配的分隔符：字母、数字与 _ - . % 以外的字符。
+func isSeparatorChar(c byte) bool {
+	return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.' || c == '%')
+}
+
+func (r *adRule) matches(req *adRequest) bool {
+	if r.types&req.typ == 0 {
+		return false
+	}
+	if r.thirdParty == 1 && !req.thirdParty || r.thirdParty == -1 && req.thirdParty {
+		return false
+	}
+	if len(r.domains) > 0 && !hostInDomains(req.pageHost, r.domains) {
+		return false
+	}
+	if hostInDomains(req.pageHost, r.notDomains) {
+		return false
+	}
+	u := req.lowerURL
+	if r.matchCase {
+		u = req.url
+	}
+	if r.re != nil {
+		return r.re.MatchString(u)
+	}
+	switch {
+	case r.hostAnchor:
+		start, end := hostBounds(u)
+		for i := start; i < end; i++ {
+			if (i == start || u[i-1] == '.') && matchAdPattern(r.pattern, u[i:], r.endAnchor) {
+				return true
+			}
+		}
+		return false
+	case r.startAnchor:
+		return matchAdPattern(r.pattern, u, r.endAnchor)
+	}
+	for i := 0; i <= len(u); i++ {
+		if matchAdPattern(r.pattern, u[i:], r.endAnchor) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAdPattern 判断 s 是否以模式 p 开头（end 时要求完整匹配）：* 匹配任意字符，^ 匹配一个分隔符或字符串末尾。
+// 两个 * 之间的片段从给定位置起的匹配是确定的，因此只需回溯到最近一个 *，复杂度为 O(len(p)*len(s))。
+func matchAdPattern(p, s string, end bool) bool {
+	pi, si := 0, 0
+	star, mark := -1, 0 // 最近一个 * 之后的模式位置，及该 * 当前匹配到的 s 位置
+	for {
+		if pi == len(p) {
+			if !end || si == len(s) {
+				return true
+			}
+		} else {
+			switch c := p[pi]; {
+			case c == '*':
+				pi++
+				star, mark = pi, si
+				continue
+			case c == '^' && si == len(s):
+				pi++
+				continue
+			case si < len(s) && (c == '^' && isSeparatorChar(s[si]) || c != '^' && c == s[si]):
+				pi++
+				si++
+				continue
+			}
+		}
+		// 当前位置不匹配：让最近的 * 多吞一个字符后重试
+		if star < 0 || mark >= len(s) {
+			return false
+		}
+		mark++
+		pi, si = star, mark
+	}
+}
+
+// hostBounds 返回 URL 中主机名的起止下标。
+func hostBounds(u string) (int, int) {
+	start := strings.Index(u, "://")
+	if start < 0 {
+		return 0, 0
+	}
+	start += 3
+	end := start
+	for end < len(u) && !strings.ContainsRune("/?#:", rune(u[end])) {
+		end++
+	}
+	if at := strings.LastIndexByte(u[start:end], '@'); at >= 0 {
+		start += at + 1
+	}
+	return start, end
+}
+
+func hostInDomains(host string, domains []string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// adRequest 为待匹配的请求。
+type adRequest struct {
+	url        string
+	lowerURL   string
+	tokens     []string
+	pageHost   string
+	typ        uint32
+	thirdParty bool
+}
+
+func newAdRequest(rawURL string, resourceType network.ResourceType, pageHost string) *adRequest {
+	req := &adRequest{url: rawURL, lowerURL: strings.ToLower(rawURL), pageHost: pageHost, typ: adResourceType(resourceType)}
+	host := ""
+	if u, err := url.Parse(rawURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	req.thirdParty = pageHost == "" || registrableDomain(host) != registrableDomain(pageHost)
+	seen := make(map[string]bool)
+	for i := 0; i < len(req.lowerURL); {
+		if !isTokenChar(req.lowerURL[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(req.lowerURL) && isTokenChar(req.lowerURL[j]) {
+			j++
+		}
+		if tok := req.lowerURL[i:j]; !seen[tok] {
+			seen[tok] = true
+			req.tokens = append(req.tokens, tok)
+		}
+		i = j
+	}
+	return req
+}
+
+func registrableDomain(host string) string {
+	if d, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return d
+	}
+	return host
+}
+
+func adResourceType(t network.ResourceType) uint32 {
+	switch t {
+	case network.ResourceTypeScript:
+		return adTypeScript
+	case network.ResourceTypeImage:
+		return adTypeImage
+	case network.ResourceTypeStylesheet:
+		return adTypeStylesheet
+	case network.ResourceTypeXHR, network.ResourceTypeFetch, network.ResourceTypeEventSource:
+		return adTypeXHR
+	case network.ResourceTypeDocument:
+		return adTypeSubdocument
+	case network.ResourceTypeFont:
+		return adTypeFont
+	case network.ResourceTypeMedia:
+		return adTypeMedia
+	case network.ResourceTypePing, network.ResourceTypeCSPViolationReport:
+		return adTypePing
+	case network.ResourceTypeWebSocket:
+		return adTypeWebSocket
+	}
+	return adTypeOther
+}
+
+// adBlockTab 为启用 block_ads 的 tab 的状态；pageHost 为主框架当前文档的主机名（用于 $domain 与 $third-party）。
+type adBlockTab struct {
+	mainFrame cdp.FrameID
+	mu        sync.Mutex
+	pageHost  string
+}
+
+// adBlockTabs: target ID -> *adBlockTab。
+var adBlockTabs sync.Map
+
+// blockAd 判断被 Fetch 暂停的请求是否应按 block_ads 拦截；主框架的文档请求从不拦截。
+func blockAd(ctx context.Context, e *fetch.EventRequestPaused) bool {
+	c := chromedp.FromContext(ctx)
+	if c == nil || c.Target == nil {
+		return false
+	}
+	v, ok := adBlockTabs.Load(c.Target.TargetID)
+	if !ok {
+		return false
+	}
+	tab := v.(*adBlockTab)
+	if e.ResourceType == network.ResourceTypeDocument && e.FrameID == tab.mainFrame {
+		host := ""
+		if u, err := url.Parse(e.Request.URL); err == nil {
+			host = strings.ToLower(u.Hostname())
+		}
+		tab.mu.Lock()
+		tab.pageHost = host
+		tab.mu.Unlock()
+		return false
+	}
+	f := adFilters.Load()
+	if f == nil {
+		return false
+	}
+	tab.mu.Lock()
+	pageHost := tab.pageHost
+	tab.mu.Unlock()
+	return f.blocks(newAdRequest(e.Request.URL, e.ResourceType, pageHost))
+}
+
+// adBlockAction 为当前 tab 启用广告与追踪过滤：通过 Fetch 拦截全部请求，命中过滤规则的以 net::ERR_BLOCKED_BY_CLIENT 失败。
+// 已安装请求拦截（域名策略或 file: 限制）时复用其监听（handlePausedRequest），只把拦截范围扩大到全部请求。
+func adBlockAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		c := chromedp.FromContext(ctx)
+		if c == nil || c.Target == nil {
+			return nil
+		}
+		id := c.Target.TargetID
+		if _, loaded := adBlockTabs.LoadOrStore(id, &adBlockTab{mainFrame: cdp.FrameID(id)}); loaded {
+			return nil
+		}
+		go func() {
+			<-ctx.Done()
+			adBlockTabs.Delete(id)
+		}()
+		if !requestGuardEnabled() {
+			chromedp.ListenTarget(ctx, func(ev interface{}) {
+				if e, ok := ev.(*fetch.EventRequestPaused); ok {
+					// 事件回调中不能同步执行 CDP 命令
+					go handlePausedRequest(ctx, e)
+				}
+			})
+		}
+		return fetch.Enable().WithPatterns([]*fetch.RequestPattern{
+			{URLPattern: "*", RequestStage: fetch.RequestStageRequest},
+		}).Do(ctx)
+	})
+}
+
+// adBlockSources 读取 ADBLOCK_LISTS（逗号分隔的本地文件路径或 http/https 地址）。
+func adBlockSources() []string {
+	var out []string
+	for _, s := range strings.Split(os.Getenv("ADBLOCK_LISTS"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// loadAdBlock 加载过滤规则；配置了 ADBLOCK_LISTS 时每隔 ADBLOCK_RELOAD_INTERVAL（默认 24h，0 不重新加载）在后台重新加载。
+func loadAdBlock() {
+	reloadAdFilters()
+	interval := envDuration("ADBLOCK_RELOAD_INTERVAL", defaultAdBlockReloadInterval)
+	if len(adBlockSources()) == 0 || interval <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(interval) {
+			reloadAdFilters()
+		}
+	}()
+}
+
+// reloadAdFilters 重新加载 ADBLOCK_LISTS；单个列表失败时跳过，全部失败时保留当前规则（首次加载时使用内置规则）。
+func reloadAdFilters() {
+	f := &adFilterEngine{loadedAt: time.Now()}
+	for _, src := range adBlockSources() {
+		data, err := readAdBlockList(src)
+		if err != nil {
+			log.Printf("adblock: failed to load %s: %v", redactSensitiveURL(src), err)
+			continue
+		}
+		f.add(data)
+		f.sources = append(f.sources, redactSensitiveURL(src))
+	}
+	if len(f.sources) == 0 {
+		if adFilters.Load() != nil {
+			return
+		}
+		f.add(defaultAdFilters)
+		f.sources = []string{"builtin"}
+	}
+	adFilters.Store(f)
+	log.Printf("adblock: loaded %d rules from %s (%d skipped)", f.rules, strings.Join(f.sources, ", "), f.skipped)
+}
+
+func readAdBlockList(src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return os.ReadFile(src)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), adBlockFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAdBlockListBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxAdBlockListBytes {
+		return nil, fmt.Errorf("list exceeds %d bytes", maxAdBlockListBytes)
+	}
+	return data, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+// 规则与 URL 主要取自 Adblock Plus / EasyList 过滤规则语法文档中的示例。
+func TestAdFilterMatching(t *testing.T) {
+	tests := []struct {
+		rule     string
+		url      string
+		pageHost string
+		typ      network.ResourceType
+		want     bool
+	}{
+		// || 匹配域名及其子域名的开头
+		{"||example.com^", "http://example.com/", "", "", true},
+		{"||example.com^", "http://example.com:8000/", "", "", true},
+		{"||example.com^", "http://subdomain.example.com/", "", "", true},
+		{"||example.com^", "http://example.com.ar/", "", "", false},
+		{"||example.com^", "http://notexample.com/", "", "", false},
+		{"||example.com^", "http://other.org/?ref=example.com", "", "", false},
+		// | 锚定地址开头 / 结尾
+		{"|http://baddomain.example/", "http://baddomain.example/banner.gif", "", "", true},
+		{"|http://baddomain.example/", "http://gooddomain.example/analyze?http://baddomain.example", "", "", false},
+		{"swf|", "http://example.com/annoyingflash.swf", "", "", true},
+		{"swf|", "http://example.com/swf/index.html", "", "", false},
+		{"|http://example.com/|", "http://example.com/", "", "", true},
+		{"|http://example.com/|", "http://example.com/foo.gif", "", "", false},
+		// ^ 匹配分隔符或地址末尾
+		{"^example.com^", "http://example.com:8000/foo.bar?a=12&b=%D1%82%D0%B5%D1%81%D1%82", "", "", true},
+		{"^%D1%82%D0%B5%D1%81%D1%82^", "http://example.com:8000/foo.bar?a=12&b=%D1%82%D0%B5%D1%81%D1%82", "", "", true},
+		{"^foo.bar^", "http://example.com:8000/foo.bar?a=12&b=%D1%82%D0%B5%D1%81%D1%82", "", "", true},
+		{"^foo.ba^", "http://example.com:8000/foo.bar?a=12", "", "", false},
+		// * 通配
+		{"/banner/*/img^", "http://example.com/banner/foo/img", "", "", true},
+		{"/banner/*/img^", "http://example.com/banner/foo/bar/img?param", "", "", true},
+		{"/banner/*/img^", "http://example.com/banner//img/foo", "", "", true},
+		{"/banner/*/img^", "http://example.com/banner/img", "", "", false},
+		{"/banner/*/img^", "http://example.com/banner/foo/imgraph", "", "", false},
+		{"/banner/*/img^", "http://example.com/banner/foo/img.gif", "", "", false},
+		{"-ad-300x250.", "http://cdn.example.com/img/top-ad-300x250.png", "", "", true},
+		// $domain= 与 $third-party
+		{"*/ads/*$domain=example.com|~foo.example.com", "http://cdn.net/ads/1.png", "example.com", "", true},
+		{"*/ads/*$domain=example.com|~foo.example.com", "http://cdn.net/ads/1.png", "www.example.com", "", true},
+		{"*/ads/*$domain=example.com|~foo.example.com", "http://cdn.net/ads/1.png", "foo.example.com", "", false},
+		{"*/ads/*$domain=example.com|~foo.example.com", "http://cdn.net/ads/1.png", "example.org", "", false},
+		{"||tracker.example^$third-party", "https://tracker.example/p.js", "news.site.com", "", true},
+		{"||tracker.example^$third-party", "https://tracker.example/p.js", "www.tracker.example", "", false},
+		// 资源类型与大小写
+		{"||example.com/ads/$script", "http://example.com/ads/a.js", "", network.ResourceTypeScript, true},
+		{"||example.com/ads/$script", "http://example.com/ads/a.png", "", network.ResourceTypeImage, false},
+		{"/Banner.$match-case", "http://example.com/Banner.gif", "", "", true},
+		{"/Banner.$match-case", "http://example.com/banner.gif", "", "", false},
+		{"/BANNER.", "http://example.com/banner.gif", "", "", true},
+		// /正则/
+		{`/\/ads?\/[0-9]+\.gif/`, "http://example.com/ad/123.gif", "", "", true},
+		{`/\/ads?\/[0-9]+\.gif/`, "http://example.com/ad/abc.gif", "", "", false},
+	}
+	for _, tt := range tests {
+		f := &adFilterEngine{}
+		f.add([]byte(tt.rule))
+		if f.rules != 1 {
+			t.Fatalf("rule %q was skipped", tt.rule)
+		}
+		typ := tt.typ
+		if typ == "" {
+			typ = network.ResourceTypeImage
+		}
+		if got := f.blocks(newAdRequest(tt.url, typ, tt.pageHost)); got != tt.want {
+			t.Errorf("%q on %q (page %q) = %v, want %v", tt.rule, tt.url, tt.pageHost, got, tt.want)
+		}
+	}
+}
+
+func TestAdFilterExceptions(t *testing.T) {
+	f := &adFilterEngine{}
+	f.add([]byte("! comment\n[Adblock Plus 2.0]\n||example.com/ads/\n@@||example.com/ads/allowed.gif\nexample.com##.banner\n||example.com^$popup\n"))
+	if f.rules != 2 || f.skipped != 2 {
+		t.Fatalf("rules = %d, skipped = %d, want 2 and 2", f.rules, f.skipped)
+	}
+	for url, want := range map[string]bool{
+		"http://example.com/ads/banner.gif":  true,
+		"http://example.com/ads/allowed.gif": false,
+		"http://example.com/index.html":      false,
+	} {
+		if got := f.blocks(newAdRequest(url, network.ResourceTypeImage, "")); got != want {
+			t.Errorf("blocks(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
+
+func TestAdRuleToken(t *testing.T) {
+	tests := []struct{ rule, want string }{
+		{"||ads.example.com^", "example"},
+		{"/banner/*/img^", "banner"},
+		{"-ad-300x250.", "300x250"},
+		{"|https://ads.", "ads"},
+		{"|adserver*", ""},
+		{"swf|", ""},
+		{"ads|", ""},
+		{"/ads|", "ads"},
+		{"*/pixel.gif", "pixel"},
+		{"||doubleclick.net^", "doubleclick"},
+		{"/^https?:\\/\\/ads\\./", ""},
+	}
+	for _, tt := range tests {
+		r, _ := parseAdRule(tt.rule)
+		if r == nil {
+			t.Fatalf("rule %q was skipped", tt.rule)
+		}
+		if got := r.token(); got != tt.want {
+			t.Errorf("token(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+// 旧的递归实现在多个 * 且不匹配时为指数级，这里应立即返回。
+func TestMatchAdPatternManyWildcards(t *testing.T) {
+	p := strings.Repeat("a*", 30) + "b"
+	s := strings.Repeat("a", 2000)
+	if matchAdPattern(p, s, false) {
+		t.Error("unexpected match")
+	}
+	if !matchAdPattern(p, s+"b", true) {
+		t.Error("expected match")
+	}
+	if !matchAdPattern("*", "", true) || !matchAdPattern("a^", "a", true) || matchAdPattern("a^", "ab", false) {
+		t.Error("edge cases")
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+
+	"github.com/chromedp/cdproto/animation"
+	"github.com/chromedp/chromedp"
+)
+
+// disableAnimationsCSS 去掉 CSS 动画与过渡，元素直接处于最终样式。
+const disableAnimationsCSS = `*, *::before, *::after { animation: none !important; transition: none !important; }`
+
+// animationPlaybackPauseAction 在导航前把页面动画时间线的播放速率设为 0（Animation.setPlaybackRate），
+// 需先启用 Animation 域，之后加载的文档同样生效。
+func animationPlaybackPauseAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := animation.Enable().Do(ctx); err != nil {
+			return err
+		}
+		return animation.SetPlaybackRate(0).Do(ctx)
+	})
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	archiveMinTimeoutSec  = 60
+	archiveNetworkIdle    = 500 * time.Millisecond
+	archiveMaxInflight    = 0
+	archiveLazyScrollMaxY = 30000
+)
+
+// ArchiveMetadata 随归档一起保存（metadata.json），同时以文本块写入 PNG。
+type ArchiveMetadata struct {
+	URL            string   `json:"url"`
+	FinalURL       string   `json:"final_url"`
+	Title          string   `json:"title"`
+	UserAgent      string   `json:"user_agent"`
+	CapturedAt     string   `json:"captured_at"`
+	ViewportWidth  int64    `json:"viewport_width"`
+	ViewportHeight int64    `json:"viewport_height"`
+	DeviceScale    float64  `json:"device_scale"`
+	SkippedStages  []string `json:"skipped_stages,omitempty"`
+	PNGSHA256      string   `json:"png_sha256"`
+	MHTMLSHA256    string   `json:"mhtml_sha256"`
+}
+
+// archiveLazyLoadJS 逐屏滚动到页面底部以触发懒加载（最多滚动到 archiveLazyScrollMaxY），并把 loading=lazy 的图片改为立即加载，最后回到顶部。
+const archiveLazyLoadJS = `(async (maxY) => {
+	document.querySelectorAll('img[loading="lazy"], iframe[loading="lazy"]').forEach((el) => { el.loading = 'eager'; });
+	const root = document.scrollingElement || document.documentElement;
+	const step = Math.max(200, Math.floor(window.innerHeight * 0.8));
+	for (let y = 0; y < Math.min(root.scrollHeight, maxY); y += step) {
+		window.scrollTo(0, y);
+		await new Promise((r) => setTimeout(r, 100));
+	}
+	window.scrollTo(0, 0);
+	await new Promise((r) => requestAnimationFrame(() => r()));
+	return true;
+})`
+
+// archiveAssetsReadyJS 等待 Web 字体与所有图片加载完成（失败的图片同样视为完成）。
+const archiveAssetsReadyJS = `(async () => {
+	if (document.fonts && document.fonts.ready) await document.fonts.ready;
+	const pending = Array.from(document.images).filter((img) => !img.complete);
+	await Promise.all(pending.map((img) => new Promise((r) => {
+		img.addEventListener('load', r, { once: true });
+		img.addEventListener('error', r, { once: true });
+	})));
+	return true;
+})()`
+
+// archivePrepareActions 归档模式下导航完成后的额外等待：网络空闲 -> 懒加载滚动 -> 再次网络空闲 -> 字体/图片就绪。
+func archivePrepareActions(tracker *networkIdleTracker, budget *captureBudget) []chromedp.Action {
+	awaitPromise := func(p *runtime.EvaluateParams) *runtime.EvaluateParams { return p.WithAwaitPromise(true) }
+	var done bool
+	return []chromedp.Action{
+		budget.wait("network_idle", 0, tracker.waitAction(archiveNetworkIdle)),
+		budget.wait("lazy_load", 0, chromedp.Evaluate(fmt.Sprintf("%s(%d)", archiveLazyLoadJS, archiveLazyScrollMaxY), &done, awaitPromise)),
+		budget.wait("network_idle", 0, tracker.waitAction(archiveNetworkIdle)),
+		budget.wait("assets_ready", 0, chromedp.Evaluate(archiveAssetsReadyJS, &done, awaitPromise)),
+	}
+}
+
+// archivePageInfoJS 读取归档元数据中需要由页面提供的字段。
+const archivePageInfoJS = `({ final_url: location.href, title: document.title, user_agent: navigator.userAgent })`
+
+// captureMHTMLAction 以 MHTML 格式保存当前页面快照。
+func captureMHTMLAction(out *string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		data, err := page.CaptureSnapshot().WithFormat(page.CaptureSnapshotFormatMhtml).Do(ctx)
+		if err != nil {
+			return err
+		}
+		*out = data
+		return nil
+	})
+}
+
+// pngAddTextChunks 在 PNG 的 IEND 之前插入 iTXt 文本块（UTF-8，不压缩）。
+func pngAddTextChunks(img []byte, kv [][2]string) ([]byte, error) {
+	sig := []byte("\x89PNG\r\n\x1a\n")
+	if !bytes.HasPrefix(img, sig) {
+		return nil, errors.New("not a PNG image")
+	}
+	// 定位 IEND
+	pos := len(sig)
+	iend := -1
+	for pos+8 <= len(img) {
+		length := int(binary.BigEndian.Uint32(img[pos : pos+4]))
+		if string(img[pos+4:pos+8]) == "IEND" {
+			iend = pos
+			break
+		}
+		pos += 12 + length
+	}
+	if iend < 0 {
+		return nil, errors.New("PNG IEND chunk not found")
+	}
+
+	var chunks bytes.Buffer
+	for _, p := range kv {
+		var data bytes.Buffer
+		data.WriteString(p[0])      // keyword（ASCII，1-79 字节）
+		data.Write([]byte{0, 0, 0}) // 结束符、未压缩、压缩方法
+		data.WriteByte(0)           // language tag（空）
+		data.WriteByte(0)           // translated keyword（空）
+		data.WriteString(p[1])
+
+		var hdr [8]byte
+		binary.BigEndian.PutUint32(hdr[:4], uint32(data.Len()))
+		copy(hdr[4:], "iTXt")
+		crc := crc32.NewIEEE()
+		crc.Write(hdr[4:])
+		crc.Write(data.Bytes())
+		var sum [4]byte
+		binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+
+		chunks.Write(hdr[:])
+		chunks.Write(data.Bytes())
+		chunks.Write(sum[:])
+	}
+
+	out := make([]byte, 0, len(img)+chunks.Len())
+	out = append(out, img[:iend]...)
+	out = append(out, chunks.Bytes()...)
+	out = append(out, img[iend:]...)
+	return out, nil
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// buildArchiveZip 打包 screenshot.png（内嵌元数据）、page.mhtml 与 metadata.json。
+func buildArchiveZip(img []byte, mhtml string, meta *ArchiveMetadata) ([]byte, error) {
+	embedded, err := pngAddTextChunks(img, [][2]string{
+		{"Source", meta.URL},
+		{"Title", meta.Title},
+		{"Creation Time", meta.CapturedAt},
+		{"Software", "screenshot-server"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	meta.PNGSHA256 = sha256Hex(embedded)
+	meta.MHTMLSHA256 = sha256Hex([]byte(mhtml))
+	metaJSON, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{"screenshot.png", embedded},
+		{"page.mhtml", []byte(mhtml)},
+		{"metadata.json", metaJSON},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
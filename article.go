@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+// Article 为 /article 的提取结果。
+type Article struct {
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	Byline        string `json:"byline"`
+	Excerpt       string `json:"excerpt"`
+	SiteName      string `json:"site_name"`
+	PublishedTime string `json:"published_time"`
+	Lang          string `json:"lang"`
+	ContentHTML   string `json:"content_html"`
+	TextContent   string `json:"text_content"`
+	WordCount     int    `json:"word_count"`
+	HeroImage     string `json:"hero_image"`
+}
+
+// articleExtractJS 是 Readability 算法的简化实现：
+// 以段落为单位给父/祖父节点打分（长度、逗号数量、class/id 正负权重），选出得分最高的容器作为正文，
+// 清理脚本/表单/导航等噪声后输出正文 HTML（链接与图片地址转为绝对 URL）。
+const articleExtractJS = `(() => {
+	const meta = (sel) => {
+		const el = document.querySelector(sel);
+		return el ? (el.getAttribute('content') || el.textContent || '').trim() : '';
+	};
+	const positive = /article|body|content|entry|main|page|post|text|blog|story/i;
+	const negative = /comment|footer|footnote|sidebar|nav|menu|masthead|related|share|social|promo|sponsor|ad-|advert|banner|popup|cookie/i;
+	const classWeight = (el) => {
+		let w = 0;
+		const s = (el.className && typeof el.className === 'string' ? el.className : '') + ' ' + (el.id || '');
+		if (positive.test(s)) w += 25;
+		if (negative.test(s)) w -= 25;
+		return w;
+	};
+
+	const scores = new Map();
+	const addScore = (el, v) => {
+		if (!el || el === document.documentElement) return;
+		if (!scores.has(el)) scores.set(el, classWeight(el) + (el.tagName === 'ARTICLE' ? 30 : 0));
+		scores.set(el, scores.get(el) + v);
+	};
+	for (const p of document.querySelectorAll('p, pre, td, blockquote')) {
+		const text = (p.innerText || '').trim();
+		if (text.length < 25) continue;
+		const s = 1 + text.split(/[,，、]/).length + Math.min(Math.floor(text.length / 100), 3);
+		addScore(p.parentElement, s);
+		if (p.parentElement) addScore(p.parentElement.parentElement, s / 2);
+	}
+	let best = null;
+	let bestScore = -Infinity;
+	for (const [el, s] of scores) {
+		// 链接密度惩罚：导航列表类容器文字多为链接
+		const text = el.innerText || '';
+		let linkLen = 0;
+		for (const a of el.querySelectorAll('a')) linkLen += (a.innerText || '').length;
+		const adjusted = s * (1 - (text.length ? linkLen / text.length : 0));
+		if (adjusted > bestScore) {
+			bestScore = adjusted;
+			best = el;
+		}
+	}
+	if (!best) best = document.querySelector('article, main, [role=main]') || document.body;
+
+	const clone = best.cloneNode(true);
+	clone.querySelectorAll('script, style, noscript, form, nav, aside, iframe, button, input, select, textarea, template, svg').forEach((n) => n.remove());
+	clone.querySelectorAll('*').forEach((n) => {
+		const s = (n.className && typeof n.className === 'string' ? n.className : '') + ' ' + (n.id || '');
+		if (negative.test(s) && !positive.test(s)) n.remove();
+	});
+	clone.querySelectorAll('a[href]').forEach((a) => a.setAttribute('href', a.href));
+	clone.querySelectorAll('img').forEach((img) => {
+		const src = img.getAttribute('data-src') || img.getAttribute('src');
+		if (src) img.setAttribute('src', new URL(src, location.href).href);
+		img.removeAttribute('srcset');
+	});
+
+	const textContent = (clone.innerText || clone.textContent || '').replace(/\n{3,}/g, '\n\n').trim();
+	const cjk = (textContent.match(/[぀-ヿ㐀-鿿가-힯]/g) || []).length;
+	const latin = (textContent.replace(/[぀-ヿ㐀-鿿가-힯]/g, ' ').match(/[A-Za-z0-9À-ɏ]+(?:['’-][A-Za-z0-9À-ɏ]+)*/g) || []).length;
+
+	let hero = meta('meta[property="og:image"]') || meta('meta[name="twitter:image"]');
+	if (!hero) {
+		for (const img of best.querySelectorAll('img')) {
+			if ((img.naturalWidth || img.width) >= 200) {
+				hero = img.currentSrc || img.src;
+				break;
+			}
+		}
+	}
+	if (hero) {
+		try { hero = new URL(hero, location.href).href; } catch (e) {}
+	}
+
+	const h1 = document.querySelector('h1');
+	return {
+		url: location.href,
+		title: meta('meta[property="og:title"]') || (h1 ? h1.innerText.trim() : '') || document.title,
+		byline: meta('meta[name="author"]') || meta('[rel="author"]') || meta('[itemprop="author"]') || meta('.byline') || meta('.author'),
+		excerpt: meta('meta[property="og:description"]') || meta('meta[name="description"]'),
+		site_name: meta('meta[property="og:site_name"]'),
+		published_time: meta('meta[property="article:published_time"]') || ((document.querySelector('time[datetime]') || {}).dateTime || ''),
+		lang: document.documentElement.lang || '',
+		content_html: clone.innerHTML.trim(),
+		text_content: textContent,
+		word_count: cjk + latin,
+		hero_image: hero || '',
+	};
+})()`
+
+func articleHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := parseRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.applyDefaults()
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setEffectiveRequestHeader(c, &req)
+
+		viewportWidth, viewportHeight := req.viewportSize()
+
+		overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+
+		sess := openChromeSession(c, overallCtx, "articleHandler")
+		if sess == nil {
+			return
+		}
+		defer sess.cancel()
+
+		var article Article
+		budget := newCaptureBudget(time.Duration(req.Timeout)*time.Second, req.BestEffort)
+		actions := navigationActions(&req, viewportWidth, viewportHeight, budget)
+		actions = append(actions, chromedp.EvaluateAsDevTools(articleExtractJS, &article))
+
+		if err := chromedp.Run(sess.ctx, actions...); err != nil {
+			respondRunError(c, err, sess.wsURL, "article extraction timeout", "failed to extract article")
+			return
+		}
+
+		c.JSON(http.StatusOK, article)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	outputZip = "zip"
+	outputPDF = "pdf"
+)
+
+// assembledImage 为待合成进 PDF 的一张图片。
+type assembledImage struct {
+	contentType string
+	data        []byte
+}
+
+// assemblePDFAction 在当前 tab 中把图片按顺序排成“每页一张”（等比缩放至页面内容区域、居中），
+// 再按 printParams 的纸张与边距打印为 PDF。
+func assemblePDFAction(images []assembledImage, printParams *page.PrintToPDFParams, out *[]byte) chromedp.Action {
+	var sb strings.Builder
+	sb.WriteString(`<!doctype html><html><head><style>` +
+		`html,body{margin:0;padding:0}` +
+		`.page{height:100vh;overflow:hidden;display:flex;align-items:center;justify-content:center;break-after:page}` +
+		`.page:last-child{break-after:auto}` +
+		`img{display:block;max-width:100%;max-height:100vh;object-fit:contain}` +
+		`</style></head><body>`)
+	for _, img := range images {
+		fmt.Fprintf(&sb, `<div class="page"><img src="data:%s;base64,%s"></div>`, img.contentType, base64.StdEncoding.EncodeToString(img.data))
+	}
+	sb.WriteString(`</body></html>`)
+
+	var decoded bool
+	return chromedp.Tasks{
+		setDocumentContentAction(sb.String()),
+		chromedp.Evaluate(`Promise.all([...document.images].map(i => i.decode())).then(() => true, () => false)`, &decoded,
+			func(p *runtime.EvaluateParams) *runtime.EvaluateParams { return p.WithAwaitPromise(true) }),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			if !decoded {
+				return errors.New("failed to decode images for pdf assembly")
+			}
+			buf, _, err := printParams.Do(ctx)
+			if err != nil {
+				return err
+			}
+			*out = buf
+			return nil
+		}),
+	}
+}
+
+// assemblePDF 为批量截图等没有现成 tab 的场景单独打开一个会话合成 PDF；失败时已写入错误响应并返回 nil。
+func assemblePDF(c *gin.Context, images []assembledImage, printParams *page.PrintToPDFParams) []byte {
+	overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(defaultTimeoutSec)*time.Second)
+	defer cancel()
+
+	sess := openChromeSession(c, overallCtx, "assemblePDF")
+	if sess == nil {
+		return nil
+	}
+	defer sess.cancel()
+
+	var pdf []byte
+	if err := chromedp.Run(sess.ctx, assemblePDFAction(images, printParams, &pdf)); err != nil {
+		respondRunError(c, err, sess.wsURL, "pdf assembly timeout", "failed to assemble pdf")
+		return nil
+	}
+	return pdf
+}
+
+// respondAssembledPDF 返回合成的 PDF，X-Page-Count 为页数。
+func respondAssembledPDF(c *gin.Context, pdf []byte, pages int) {
+	c.Header("X-Page-Count", strconv.Itoa(pages))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
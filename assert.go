@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+const maxAssertions = 50
+
+// Assertion 为 /assert 的一条断言，三种类型中只能设置一个：
+//   - selector_visible：选择器命中至少一个可见元素（有尺寸、未被 display/visibility/opacity 隐藏）；
+//   - text_present：页面可见文本（document.body.innerText）包含该字符串（区分大小写）；
+//   - min_element_size：选择器命中的元素中至少有一个不小于 width × height（CSS px）。
+type Assertion struct {
+	SelectorVisible string          `json:"selector_visible,omitempty"`
+	TextPresent     string          `json:"text_present,omitempty"`
+	MinElementSize  *MinElementSize `json:"min_element_size,omitempty"`
+}
+
+type MinElementSize struct {
+	Selector string  `json:"selector"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+}
+
+// AssertionResult 为一条断言的结果；details 说明失败原因（not found / hidden / text not found）或实际尺寸。
+type AssertionResult struct {
+	Assertion
+	Passed  bool   `json:"passed"`
+	Details string `json:"details,omitempty"`
+}
+
+// AssertReport 为 /assert 的响应体；screenshot 仅在 screenshot_on_failure 且有断言失败时返回。
+type AssertReport struct {
+	Passed     bool              `json:"passed"`
+	URL        string            `json:"url"`
+	Results    []AssertionResult `json:"results"`
+	DurationMS int64             `json:"duration_ms"`
+	Screenshot *AssertScreenshot `json:"screenshot,omitempty"`
+}
+
+type AssertScreenshot struct {
+	ContentType string `json:"content_type"`
+	Base64      string `json:"base64"`
+}
+
+// validateAssertions 校验 /assert 的断言列表：1-50 条，每条恰好设置一种断言。
+func (r *ScreenshotRequest) validateAssertions() error {
+	if len(r.Assertions) == 0 {
+		return errors.New("assertions is required")
+	}
+	if len(r.Assertions) > maxAssertions {
+		return fmt.Errorf("assertions must contain at most %d items", maxAssertions)
+	}
+	for i := range r.Assertions {
+		a := &r.Assertions[i]
+		a.SelectorVisible = strings.TrimSpace(a.SelectorVisible)
+		n := 0
+		if a.SelectorVisible != "" {
+			n++
+		}
+		if a.TextPresent != "" {
+			n++
+		}
+		if a.MinElementSize != nil {
+			n++
+			a.MinElementSize.Selector = strings.TrimSpace(a.MinElementSize.Selector)
+			if a.MinElementSize.Selector == "" {
+				return fmt.Errorf("assertions[%d].min_element_size.selector is required", i)
+			}
+			if a.MinElementSize.Width < 0 || a.MinElementSize.Height < 0 {
+				return fmt.Errorf("assertions[%d].min_element_size width and height must be non-negative", i)
+			}
+		}
+		if n != 1 {
+			return fmt.Errorf("assertions[%d] must set exactly one of selector_visible, text_present, min_element_size", i)
+		}
+	}
+	return nil
+}
+
+// assertJS 在页面中逐条求值断言；无效选择器等异常记为失败。
+const assertJS = `(assertions) => {
+	const visible = (el) => {
+		const r = el.getBoundingClientRect();
+		if (r.width <= 0 || r.height <= 0) return false;
+		if (el.checkVisibility) return el.checkVisibility({ checkOpacity: true, checkVisibilityCSS: true });
+		const cs = getComputedStyle(el);
+		return cs.display !== 'none' && cs.visibility !== 'hidden' && +cs.opacity !== 0;
+	};
+	return assertions.map((a) => {
+		try {
+			if (a.selector_visible) {
+				const els = [...document.querySelectorAll(a.selector_visible)];
+				if (!els.length) return { passed: false, details: 'not found' };
+				return els.some(visible) ? { passed: true } : { passed: false, details: 'hidden' };
+			}
+			if (a.text_present) {
+				const text = document.body ? document.body.innerText : '';
+				return text.includes(a.text_present) ? { passed: true } : { passed: false, details: 'text not found' };
+			}
+			const m = a.min_element_size;
+			const els = [...document.querySelectorAll(m.selector)];
+			if (!els.length) return { passed: false, details: 'not found' };
+			let best = null;
+			for (const el of els) {
+				const r = el.getBoundingClientRect();
+				if (!best || r.width * r.height > best.width * best.height) best = r;
+				if (r.width >= m.width && r.height >= m.height) {
+					return { passed: true, details: Math.round(r.width) + 'x' + Math.round(r.height) };
+				}
+			}
+			return { passed: false, details: Math.round(best.width) + 'x' + Math.round(best.height) };
+		} catch (e) {
+			return { passed: false, details: String(e && e.message || e) };
+		}
+	});
+}`
+
+// assertHandler 处理 POST /assert：加载页面（导航、等待等参数与截图接口相同）后求值断言，只返回结论；
+// screenshot_on_failure 时在有断言失败的情况下附带截图（按 format / quality / full_page）。
+func assertHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := parseRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.applyDefaults()
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := req.validateAssertions(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setEffectiveRequestHeader(c, &req)
+
+		started := time.Now()
+		viewportWidth, viewportHeight := req.viewportSize()
+
+		overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+
+		sess := openChromeSession(c, overallCtx, "assertHandler")
+		if sess == nil {
+			return
+		}
+		defer sess.cancel()
+
+		args, err := json.Marshal(req.Assertions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode assertions"})
+			return
+		}
+		var outcomes []struct {
+			Passed  bool   `json:"passed"`
+			Details string `json:"details"`
+		}
+		var info pageInfo
+		var img []byte
+		budget := newCaptureBudget(time.Duration(req.Timeout)*time.Second, req.BestEffort)
+		actions := navigationActions(&req, viewportWidth, viewportHeight, budget)
+		actions = append(actions,
+			chromedp.EvaluateAsDevTools(fmt.Sprintf("(%s)(%s)", assertJS, args), &outcomes),
+			pageInfoAction(&info),
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				if !req.ScreenshotOnFailure {
+					return nil
+				}
+				failed := false
+				for _, o := range outcomes {
+					failed = failed || !o.Passed
+				}
+				if !failed {
+					return nil
+				}
+				cap := page.CaptureScreenshot().WithFromSurface(true).WithFormat(captureFormat(req.Format))
+				if req.Format == "jpeg" || req.Format == "webp" {
+					cap = cap.WithQuality(int64(req.Quality))
+				}
+				if req.FullPage {
+					clip, err := fullPageClip(ctx)
+					if err != nil {
+						return err
+					}
+					cap = cap.WithCaptureBeyondViewport(true).WithClip(clip)
+				}
+				buf, err := cap.Do(ctx)
+				if err != nil {
+					return err
+				}
+				img = normalizeColorProfile(buf)
+				return nil
+			}),
+		)
+
+		if err := chromedp.Run(sess.ctx, actions...); err != nil {
+			respondRunError(c, err, sess.wsURL, "assert timeout", "failed to evaluate assertions")
+			return
+		}
+		if len(outcomes) != len(req.Assertions) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to evaluate assertions"})
+			return
+		}
+
+		report := AssertReport{Passed: true, URL: info.URL, Results: make([]AssertionResult, len(outcomes))}
+		for i, o := range outcomes {
+			report.Results[i] = AssertionResult{Assertion: req.Assertions[i], Passed: o.Passed, Details: o.Details}
+			report.Passed = report.Passed && o.Passed
+		}
+		if img != nil {
+			report.Screenshot = &AssertScreenshot{ContentType: contentTypeForFormat(req.Format), Base64: base64.StdEncoding.EncodeToString(img)}
+		}
+		report.DurationMS = time.Since(started).Milliseconds()
+		if report.Passed {
+			c.Header("X-Assert-Result", "pass")
+		} else {
+			c.Header("X-Assert-Result", "fail")
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+// AssetRef 页面中发现的一个链接/资源。Status 为加载过程中观察到的 HTTP 状态码（未请求过则为 0），
+// 请求失败时 Error 为 Chrome 的错误文本（如 net::ERR_NAME_NOT_RESOLVED）。
+type AssetRef struct {
+	URL      string `json:"url"`
+	Text     string `json:"text,omitempty"`
+	Rel      string `json:"rel,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	Status   int64  `json:"status,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// AssetMap 为 /assets 的返回结果。
+type AssetMap struct {
+	URL         string     `json:"url"`
+	Links       []AssetRef `json:"links"`
+	Images      []AssetRef `json:"images"`
+	Scripts     []AssetRef `json:"scripts"`
+	Stylesheets []AssetRef `json:"stylesheets"`
+}
+
+// assetMapJS 收集渲染后 DOM 中的链接、图片、脚本与样式表（URL 由浏览器解析为绝对地址，按 URL 去重）。
+const assetMapJS = `(() => {
+	const collect = (list) => {
+		const seen = new Set();
+		const out = [];
+		for (const item of list) {
+			if (!item.url || seen.has(item.url) || !/^(https?|data|blob|file):/i.test(item.url)) continue;
+			seen.add(item.url);
+			out.push(item);
+		}
+		return out;
+	};
+	const clean = (t) => (t || '').replace(/\s+/g, ' ').trim().slice(0, 200);
+	const images = [];
+	for (const img of document.images) {
+		images.push({ url: img.currentSrc || img.src });
+		if (img.srcset) {
+			for (const part of img.srcset.split(',')) {
+				const u = part.trim().split(/\s+/)[0];
+				if (u) images.push({ url: new URL(u, document.baseURI).href });
+			}
+		}
+	}
+	for (const src of document.querySelectorAll('picture source[srcset]')) {
+		for (const part of src.srcset.split(',')) {
+			const u = part.trim().split(/\s+/)[0];
+			if (u) images.push({ url: new URL(u, document.baseURI).href });
+		}
+	}
+	return {
+		url: location.href,
+		links: collect(Array.from(document.querySelectorAll('a[href], area[href]'), (a) => ({ url: a.href, text: clean(a.innerText || a.getAttribute('alt') || a.title), rel: a.rel || '' }))),
+		images: collect(images),
+		scripts: collect(Array.from(document.scripts, (s) => ({ url: s.src }))),
+		stylesheets: collect(Array.from(document.querySelectorAll('link[rel~="stylesheet" i][href]'), (l) => ({ url: l.href }))),
+	};
+})()`
+
+// networkLog 记录页面加载过程中每个 URL 的响应状态/失败原因。
+type networkLog struct {
+	mu       sync.Mutex
+	urls     map[network.RequestID]string
+	statuses map[string]int64
+	mimes    map[string]string
+	errors   map[string]string
+}
+
+func newNetworkLog() *networkLog {
+	return &networkLog{
+		urls:     map[network.RequestID]string{},
+		statuses: map[string]int64{},
+		mimes:    map[string]string{},
+		errors:   map[string]string{},
+	}
+}
+
+func (l *networkLog) listen(ctx context.Context) {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		switch e := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			l.urls[e.RequestID] = e.Request.URL
+			// 重定向：上一跳的状态码记在原 URL 上
+			if e.RedirectResponse != nil {
+				l.statuses[e.RedirectResponse.URL] = e.RedirectResponse.Status
+			}
+		case *network.EventResponseReceived:
+			l.statuses[e.Response.URL] = e.Response.Status
+			l.mimes[e.Response.URL] = e.Response.MimeType
+		case *network.EventLoadingFailed:
+			if u, ok := l.urls[e.RequestID]; ok {
+				l.errors[u] = e.ErrorText
+			}
+		}
+	})
+}
+
+// annotate 为资源补充观察到的状态码、MIME 类型与错误（URL 忽略 #fragment 匹配）。
+func (l *networkLog) annotate(refs []AssetRef) []AssetRef {
+	if refs == nil {
+		return []AssetRef{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range refs {
+		u := refs[i].URL
+		if idx := strings.IndexByte(u, '#'); idx >= 0 {
+			u = u[:idx]
+		}
+		refs[i].Status = l.statuses[u]
+		refs[i].MimeType = l.mimes[u]
+		refs[i].Error = l.errors[u]
+	}
+	return refs
+}
+
+func assetsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := parseRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.applyDefaults()
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setEffectiveRequestHeader(c, &req)
+
+		viewportWidth, viewportHeight := req.viewportSize()
+
+		overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+
+		sess := openChromeSession(c, overallCtx, "assetsHandler")
+		if sess == nil {
+			return
+		}
+		defer sess.cancel()
+
+		netLog := newNetworkLog()
+		netLog.listen(sess.ctx)
+
+		var result AssetMap
+		budget := newCaptureBudget(time.Duration(req.Timeout)*time.Second, req.BestEffort)
+		actions := navigationActions(&req, viewportWidth, viewportHeight, budget)
+		actions = append(actions, chromedp.EvaluateAsDevTools(assetMapJS, &result))
+
+		if err := chromedp.Run(sess.ctx, actions...); err != nil {
+			respondRunError(c, err, sess.wsURL, "asset extraction timeout", "failed to extract assets")
+			return
+		}
+
+		result.Links = netLog.annotate(result.Links)
+		result.Images = netLog.annotate(result.Images)
+		result.Scripts = netLog.annotate(result.Scripts)
+		result.Stylesheets = netLog.annotate(result.Stylesheets)
+
+		if skipped := budget.skippedStages(); len(skipped) > 0 {
+			c.Header("X-Budget-Exceeded", strings.Join(skipped, ","))
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+// heightAuto 为 height=auto 的内部取值：截图前把视口高度调整为页面内容高度（可缩小也可扩大）。
+const heightAuto heightValue = -1
+
+// heightValue 为 height 参数：整数像素，或字符串 "auto"。
+type heightValue int
+
+func (h *heightValue) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte(`"auto"`)) {
+		*h = heightAuto
+		return nil
+	}
+	n, err := strconv.Atoi(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return errors.New(`height must be an integer or "auto"`)
+	}
+	*h = heightValue(n)
+	return nil
+}
+
+func (h heightValue) MarshalJSON() ([]byte, error) {
+	if h == heightAuto {
+		return []byte(`"auto"`), nil
+	}
+	return []byte(strconv.Itoa(int(h))), nil
+}
+
+// parseHeightQuery 解析 GET 的 height 参数；未提供时为 0。
+func parseHeightQuery(v string) (heightValue, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, nil
+	}
+	if strings.EqualFold(v, "auto") {
+		return heightAuto, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, errors.New(`height must be integer or "auto"`)
+	}
+	return heightValue(n), nil
+}
+
+// contentHeightJS 返回文档内容本身的高度。documentElement.scrollHeight 不小于视口高度，
+// 短页面会因此带上尾部空白，所以以 body / html 的 offsetHeight 为准，取不到时才退回 scrollHeight。
+const contentHeightJS = `(() => {
+	const de = document.documentElement;
+	const b = document.body;
+	const h = Math.max(b ? b.scrollHeight : 0, b ? b.offsetHeight : 0, de ? de.offsetHeight : 0);
+	return h > 0 ? h : (de ? de.scrollHeight : 0);
+})()`
+
+// fitViewportToContent 用于 height=auto：测量内容高度并把视口高度设为该值（上限 maxAutoViewportHeight），
+// 之后按视口截图即可得到恰好包含内容的图片。视口变化可能引起重排（如 vh 单位），因此最多再测量一次。
+func fitViewportToContent(req *ScreenshotRequest, viewportWidth int64, viewportHeight *int64) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for i := 0; i < 2; i++ {
+			var h float64
+			if err := chromedp.EvaluateAsDevTools(contentHeightJS, &h).Do(ctx); err != nil {
+				return err
+			}
+			if h <= 0 {
+				return fmt.Errorf("failed to determine page height")
+			}
+			desired := int64(math.Ceil(h))
+			if desired > maxAutoViewportHeight {
+				desired = maxAutoViewportHeight
+			}
+			if desired == *viewportHeight {
+				return nil
+			}
+			*viewportHeight = desired
+			if err := emulation.SetDeviceMetricsOverride(viewportWidth, desired, req.DeviceScale, req.Mobile).Do(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
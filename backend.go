@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	backendCDP        = "cdp"
+	backendPlaywright = "playwright"
+	backendBiDi       = "webdriver-bidi"
+
+	// simpleCaptureParams 为 captureBackend 支持的参数，用于错误提示。
+	simpleCaptureParams = "url, width, height, device_scale, format (png/jpeg), quality, full_page, clip, wait_until (load/domcontentloaded), wait_time and timeout"
+)
+
+// captureBackend 为 CDP 之外的截图后端（Firefox WebDriver BiDi、Playwright server）：每次截图在独立的连接上
+// 完成导航与截图，只支持 simpleCaptureOnly 中的参数；其余功能仍由 CDP 路径实现。
+type captureBackend interface {
+	// name 为响应头 X-Capture-Backend 的取值。
+	name() string
+	// engine 为该后端驱动的浏览器（chromium / firefox），即响应头 X-Capture-Engine。
+	engine() string
+	// acquire 取得一个上游地址（必要时排队），用完后调用 release。
+	acquire(ctx context.Context) (endpoint string, release func(), err error)
+	// capture 导航并截图；truncated 表示整页截图因像素预算被截断。
+	capture(ctx context.Context, endpoint string, req *ScreenshotRequest) (img []byte, truncated bool, err error)
+}
+
+// captureBackendName 为 CAPTURE_BACKEND：cdp（默认，经 browserless / CHROME_WS_ENDPOINT）或 playwright
+// （经 PLAYWRIGHT_WS_URL 的 Playwright server），按部署选择，只作用于截图接口。
+var captureBackendName = strings.ToLower(strings.TrimSpace(os.Getenv("CAPTURE_BACKEND")))
+
+// loadCaptureBackend 在启动时校验 CAPTURE_BACKEND 相关配置（需在 loadDomainPolicy 之后调用）。
+func loadCaptureBackend() error {
+	switch captureBackendName {
+	case "", backendCDP:
+		return nil
+	case backendPlaywright:
+	default:
+		return fmt.Errorf("CAPTURE_BACKEND must be cdp or playwright, got %q", captureBackendName)
+	}
+	if len(playwrightEndpoints) == 0 {
+		return errors.New("CAPTURE_BACKEND=playwright requires PLAYWRIGHT_WS_URL")
+	}
+	// 域名策略与 file: 路径限制依赖 CDP 拦截重定向与子资源
+	if hostPolicy != nil {
+		return errors.New("CAPTURE_BACKEND=playwright is not available when ALLOWED_DOMAINS or BLOCKED_DOMAINS is configured")
+	}
+	if allowFileURLs() {
+		return errors.New("CAPTURE_BACKEND=playwright is not available when ALLOW_FILE_URLS is enabled")
+	}
+	log.Printf("captureBackend: screenshots use playwright server %s", redactEndpointList(os.Getenv("PLAYWRIGHT_WS_URL")))
+	return nil
+}
+
+// captureBackendFor 返回处理该截图请求的后端；nil 表示使用 CDP。engine=firefox 优先使用 FIREFOX_BIDI_URL。
+func captureBackendFor(req *ScreenshotRequest) captureBackend {
+	switch {
+	case req.Engine == engineFirefox && firefoxEndpoints != nil:
+		return firefoxBackend{}
+	case captureBackendName == backendPlaywright:
+		browser := engineChromium
+		if req.Engine == engineFirefox {
+			browser = engineFirefox
+		}
+		return playwrightBackend{browser: browser}
+	}
+	return nil
+}
+
+// simpleCaptureOnly 报告请求是否只用到了 captureBackend 支持的参数（导航、视口与截图）。
+func (r *ScreenshotRequest) simpleCaptureOnly() bool {
+	return r.Height != heightAuto && r.Format != "webp" &&
+		(r.WaitUntil == "" || r.WaitUntil == waitUntilLoad || r.WaitUntil == waitUntilDOMContentLoaded) &&
+		r.usesOnly(func(dst, src *ScreenshotRequest) {
+			dst.Engine, dst.URL = src.Engine, src.URL
+			dst.Width, dst.Height, dst.DeviceScale = src.Width, src.Height, src.DeviceScale
+			dst.Format, dst.Quality, dst.FullPage, dst.Clip = src.Format, src.Quality, src.FullPage, src.Clip
+			dst.WaitUntil, dst.WaitTime, dst.Timeout = src.WaitUntil, src.WaitTime, src.Timeout
+			dst.Store, dst.Storage, dst.Tags, dst.ResponseType, dst.Strict = src.Store, src.Storage, src.Tags, src.ResponseType, src.Strict
+		})
+}
+
+var errBackendDial = errors.New("capture backend dial failed")
+
+// backendNavigationError 为目标页面导航失败，与后端连接/执行失败区分。
+type backendNavigationError struct{ err error }
+
+func (e *backendNavigationError) Error() string { return e.err.Error() }
+
+// status 把导航错误映射为与 CDP 路径相同的错误码：Chromium 的 net::ERR_* 与 Firefox 的 NS_ERROR_*。
+func (e *backendNavigationError) status() (status int, code, netCode string) {
+	if ne := classifyNavigationError(e.err); ne != nil {
+		return ne.status, ne.code, ne.netCode
+	}
+	msg := e.err.Error()
+	switch {
+	case strings.Contains(msg, "NS_ERROR_UNKNOWN_HOST"):
+		return http.StatusFailedDependency, "TARGET_DNS_FAILED", ""
+	case strings.Contains(msg, "NS_ERROR_CONNECTION_REFUSED"):
+		return http.StatusFailedDependency, "TARGET_CONNECTION_REFUSED", ""
+	case strings.Contains(msg, "NS_ERROR_NET_TIMEOUT"):
+		return http.StatusGatewayTimeout, "TARGET_TIMEOUT", ""
+	case strings.Contains(msg, "SSL_ERROR_") || strings.Contains(msg, "SEC_ERROR_"):
+		return http.StatusFailedDependency, "TARGET_TLS_ERROR", ""
+	}
+	return http.StatusFailedDependency, "TARGET_NAVIGATION_FAILED", ""
+}
+
+// captureWithBackend 通过 backend 截图，已写入响应（成功或错误）。
+func captureWithBackend(c *gin.Context, ctx context.Context, req *ScreenshotRequest, backend captureBackend, responseKey string) {
+	endpoint, release, err := backend.acquire(ctx)
+	if err != nil {
+		// 排队期间超时为 504，其余（如客户端断开）按实际原因返回
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": fmt.Sprintf("timed out waiting for a free %s endpoint", backend.name()), "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("failed to acquire %s endpoint", backend.name()), "details": err.Error()})
+		return
+	}
+	defer release()
+
+	img, truncated, err := backend.capture(ctx, endpoint, req)
+	if err != nil {
+		var navErr *backendNavigationError
+		switch {
+		case errors.As(err, &navErr):
+			status, code, netCode := navErr.status()
+			body := gin.H{"error": "navigation failed", "code": code, "details": err.Error()}
+			if netCode != "" {
+				body["net_error"] = netCode
+			}
+			c.JSON(status, body)
+		case ctx.Err() != nil || isTimeoutErr(err):
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "screenshot timeout", "details": err.Error()})
+		case errors.Is(err, errBackendDial):
+			log.Printf("%s: dial %s failed: %v", backend.name(), redactSensitiveURL(endpoint), err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("failed to connect %s endpoint", backend.name()), "details": redactURLsInString(err.Error())})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to screenshot", "details": err.Error()})
+		}
+		return
+	}
+
+	c.Header("X-Capture-Backend", backend.name())
+	c.Header("X-Capture-Engine", backend.engine())
+	if truncated {
+		c.Header("X-Capture-Truncated", "true")
+	}
+	setCaptureHeaders(c, req.output, img)
+	respondCapturedImage(c, req, img, responseKey, true)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"os"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultReadyMaxMemoryPercent = 80
+
+// readyzThresholds 读取 READYZ_MAX_QUEUE（默认为并发上限）与 READYZ_MAX_MEMORY_PERCENT（默认 80，0 不检查内存）。
+func readyzThresholds() (maxQueue, memoryPercent int) {
+	if captureSlots != nil {
+		maxQueue = cap(captureSlots.slots)
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("READYZ_MAX_QUEUE"))); err == nil && n >= 0 {
+		maxQueue = n
+	}
+	memoryPercent = defaultReadyMaxMemoryPercent
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("READYZ_MAX_MEMORY_PERCENT"))); err == nil && n >= 0 && n <= 100 {
+		memoryPercent = n
+	}
+	return maxQueue, memoryPercent
+}
+
+// processMemoryBytes 为 Go 运行时向系统申请且未归还的内存。
+func processMemoryBytes() int64 {
+	samples := []metrics.Sample{{Name: "/memory/classes/total:bytes"}, {Name: "/memory/classes/heap/released:bytes"}}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 || samples[1].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(samples[0].Value.Uint64() - samples[1].Value.Uint64())
+}
+
+// readyzHandler 处理 GET /readyz：供负载均衡的就绪探针使用，排队数或内存压力超过阈值时返回 503，
+// 让上游暂时把流量分给其它实例；恢复后自动重新就绪。内存检查只在检测到 cgroup 内存限制时进行。
+func readyzHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxQueue, memoryPercent := readyzThresholds()
+		payload := gin.H{}
+		var reasons []string
+
+		if captureSlots != nil {
+			stats := captureSlots.stats()
+			payload["capture_slots"] = stats
+			if maxQueue > 0 && captureSlots.waiting.Load() >= int64(maxQueue) {
+				reasons = append(reasons, "queue")
+			}
+		}
+		if limit := resourceLimits.memoryBytes; limit > 0 {
+			used := processMemoryBytes()
+			payload["memory"] = gin.H{"used_bytes": used, "limit_bytes": limit}
+			if memoryPercent > 0 && used*100 >= limit*int64(memoryPercent) {
+				reasons = append(reasons, "memory")
+			}
+		}
+
+		if len(reasons) == 0 {
+			payload["status"] = "ready"
+			c.JSON(http.StatusOK, payload)
+			return
+		}
+		payload["status"] = "overloaded"
+		payload["reasons"] = reasons
+		if captureSlots != nil {
+			secs := strconv.Itoa(max(1, int(math.Ceil(captureSlots.estimatedWait().Seconds()))))
+			c.Header("Retry-After", secs)
+			c.Header("X-Estimated-Wait", secs)
+		}
+		c.JSON(http.StatusServiceUnavailable, payload)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	balanceRoundRobin    = "round_robin"
+	balanceLeastInflight = "least_inflight"
+
+	upstreamBaseCooldown = 5 * time.Second
+	upstreamMaxCooldown  = 2 * time.Minute
+)
+
+var errBrowserlessNotConfigured = errors.New("browserless endpoint is not configured")
+
+// upstreams 为 BROWSERLESS_HTTP_URL（逗号分隔的多个地址）的负载均衡器；CHROME_WS_ENDPOINT 优先，不经过它。
+var upstreams = newUpstreamBalancer(splitEndpointList(getBrowserlessHTTPURL()), os.Getenv("BROWSERLESS_BALANCE"))
+
+// upstreamEndpoint 单个 browserless 地址及其健康/负载状态。
+type upstreamEndpoint struct {
+	base   string // BROWSERLESS_HTTP_URL 中的一项
+	wsHost string // 最近一次解析出的 ws host:port，用于把会话归属到该地址
+
+	inflight      int
+	requests      uint64
+	failures      int // 连续失败次数，成功后清零
+	totalFailures uint64
+	downUntil     time.Time
+	lastErr       string
+}
+
+func (e *upstreamEndpoint) down(now time.Time) bool {
+	return now.Before(e.downUntil)
+}
+
+// upstreamBalancer 在多个 browserless 之间分配请求（round_robin / least_inflight）。
+// 健康状态为被动检测：解析或 dial 失败后按连续失败次数指数退避（5s 起，最长 2m），期间优先选择其他地址；
+// 所有地址都处于退避期时仍会按退避结束时间依次尝试，而不是直接拒绝请求。
+type upstreamBalancer struct {
+	strategy string
+
+	mu        sync.Mutex
+	endpoints []*upstreamEndpoint
+	next      int
+}
+
+// splitEndpointList 拆分逗号分隔的地址列表，忽略空项。
+func splitEndpointList(raw string) []string {
+	var out []string
+	for _, p := range strings.Split(raw, ",") {
+		if p = cleanEndpointString(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// redactEndpointList 对逗号分隔的地址列表逐项脱敏。
+func redactEndpointList(raw string) string {
+	parts := splitEndpointList(raw)
+	for i, p := range parts {
+		parts[i] = redactSensitiveURL(p)
+	}
+	return strings.Join(parts, ",")
+}
+
+func newUpstreamBalancer(bases []string, strategy string) *upstreamBalancer {
+	strategy = strings.ToLower(strings.TrimSpace(strategy))
+	if strategy != balanceLeastInflight {
+		strategy = balanceRoundRobin
+	}
+	b := &upstreamBalancer{strategy: strategy}
+	for _, base := range bases {
+		b.endpoints = append(b.endpoints, &upstreamEndpoint{base: base})
+	}
+	return b
+}
+
+// order 返回本次尝试的顺序：健康的地址按策略排序在前，退避中的按退避结束时间排在后面。
+func (b *upstreamBalancer) order() []*upstreamEndpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(b.endpoints)
+	now := time.Now()
+	var healthy, down []*upstreamEndpoint
+	for i := 0; i < n; i++ {
+		e := b.endpoints[(b.next+i)%n]
+		if e.down(now) {
+			down = append(down, e)
+		} else {
+			healthy = append(healthy, e)
+		}
+	}
+	b.next++
+	if b.strategy == balanceLeastInflight {
+		sort.SliceStable(healthy, func(i, j int) bool { return healthy[i].inflight < healthy[j].inflight })
+	}
+	sort.SliceStable(down, func(i, j int) bool { return down[i].downUntil.Before(down[j].downUntil) })
+	return append(healthy, down...)
+}
+
+// resolve 按 order 依次解析各地址的 ws endpoint，返回第一个成功的结果。
+func (b *upstreamBalancer) resolve(ctx context.Context) (string, bool, error) {
+	if len(b.endpoints) == 0 {
+		return "", false, errBrowserlessNotConfigured
+	}
+	var lastErr error
+	for _, e := range b.order() {
+		httpBase, err := parseBrowserlessHTTPBase(e.base)
+		if err != nil {
+			b.markFailure(e, err)
+			lastErr = err
+			continue
+		}
+		resolved, err := resolveWSEndpointViaJSONVersion(ctx, httpBase)
+		if err != nil {
+			lastErr = err
+			// 调用方已取消/超时：不计入该地址的失败，也不再尝试其他地址
+			if ctx.Err() != nil {
+				break
+			}
+			b.markFailure(e, err)
+			log.Printf("resolveWSEndpoint: BROWSERLESS_HTTP_URL=%s failed: %s", redactSensitiveURL(e.base), redactURLsInString(err.Error()))
+			continue
+		}
+		resolved = normalizeWSEndpointForDial(resolved)
+		b.markResolved(e, resolved)
+		log.Printf("resolveWSEndpoint: BROWSERLESS_HTTP_URL=%s resolved via /json/version -> %s", e.base, resolved)
+		return resolved, true, nil
+	}
+	return "", true, lastErr
+}
+
+func (b *upstreamBalancer) markResolved(e *upstreamEndpoint, wsURL string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if u, err := url.Parse(wsURL); err == nil {
+		e.wsHost = u.Host
+	}
+	e.failures = 0
+	e.downUntil = time.Time{}
+}
+
+func (b *upstreamBalancer) markFailure(e *upstreamEndpoint, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e.failures++
+	e.totalFailures++
+	e.lastErr = redactURLsInString(err.Error())
+	cooldown := upstreamBaseCooldown << min(e.failures-1, 5)
+	if cooldown > upstreamMaxCooldown {
+		cooldown = upstreamMaxCooldown
+	}
+	e.downUntil = time.Now().Add(cooldown)
+}
+
+// lookup 根据 ws 地址的 host 找到所属的 browserless 地址（调用方需持有锁）。
+func (b *upstreamBalancer) lookup(wsURL string) *upstreamEndpoint {
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return nil
+	}
+	for _, e := range b.endpoints {
+		if e.wsHost != "" && e.wsHost == u.Host {
+			return e
+		}
+	}
+	return nil
+}
+
+// begin 记录一个使用 wsURL 的会话开始，返回会话结束时调用的函数。
+func (b *upstreamBalancer) begin(wsURL string) func() {
+	b.mu.Lock()
+	e := b.lookup(wsURL)
+	b.mu.Unlock()
+	if e == nil {
+		return func() {}
+	}
+	return b.track(e)
+}
+
+// track 记录 e 上的一个请求开始，返回请求结束时调用的函数。
+func (b *upstreamBalancer) track(e *upstreamEndpoint) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e.inflight++
+	e.requests++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			e.inflight--
+			b.mu.Unlock()
+		})
+	}
+}
+
+// markHealthy 清除 e 的连续失败计数与退避（请求不经过 ws 解析时使用，如 REST 直通）。
+func (b *upstreamBalancer) markHealthy(e *upstreamEndpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e.failures = 0
+	e.downUntil = time.Time{}
+}
+
+// allDown 报告是否所有 browserless 地址都处于退避期；只有一个地址（或只配置 CHROME_WS_ENDPOINT）时总为 true。
+func (b *upstreamBalancer) allDown() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.endpoints) <= 1 {
+		return true
+	}
+	now := time.Now()
+	for _, e := range b.endpoints {
+		if !e.down(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// reportFailure 记录 wsURL 所属地址连接失败（解析成功但 dial 失败）。
+func (b *upstreamBalancer) reportFailure(wsURL string, err error) {
+	b.mu.Lock()
+	e := b.lookup(wsURL)
+	b.mu.Unlock()
+	if e != nil {
+		b.markFailure(e, err)
+	}
+}
+
+// isDown 报告 wsURL 所属地址是否处于退避期；不属于任何 browserless 地址（如 CHROME_WS_ENDPOINT）时为 false。
+func (b *upstreamBalancer) isDown(wsURL string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e := b.lookup(wsURL)
+	return e != nil && e.down(time.Now())
+}
+
+// stats 返回各地址状态，用于 /health。
+func (b *upstreamBalancer) stats() map[string]interface{} {
+	warm, gpus := warmSessionsByHost(), gpuInfoByHost()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	list := make([]map[string]interface{}, 0, len(b.endpoints))
+	for _, e := range b.endpoints {
+		item := map[string]interface{}{
+			"url":            redactSensitiveURL(e.base),
+			"healthy":        !e.down(now),
+			"inflight":       e.inflight,
+			"requests":       e.requests,
+			"failures":       e.failures,
+			"total_failures": e.totalFailures,
+			"warm_sessions":  warm[e.wsHost],
+		}
+		if e.down(now) {
+			item["down_until"] = e.downUntil.UTC().Format(time.RFC3339)
+		}
+		if e.lastErr != "" {
+			item["last_error"] = e.lastErr
+		}
+		if gpu := gpus[e.wsHost]; gpu != nil {
+			item["gpu"] = gpu
+		}
+		list = append(list, item)
+	}
+	return map[string]interface{}{
+		"strategy":  b.strategy,
+		"endpoints": list,
+	}
+}
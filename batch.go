@@ -0,0 +1,299 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	batchOutputZip    = "zip"
+	batchOutputNDJSON = "ndjson"
+	batchOutputPDF    = "pdf"
+
+	defaultBatchMaxItems       = 50
+	defaultBatchMaxParallelism = 4
+	defaultBatchParallelism    = 2
+)
+
+// BatchRequest 为 /screenshots/batch 的请求体；items 中每一项与 POST /screenshot 的请求体相同。
+type BatchRequest struct {
+	Items       []json.RawMessage `json:"items"`
+	Parallelism int               `json:"parallelism"`
+	Output      string            `json:"output"`
+	// PDF 仅用于 output=pdf：合成 PDF 的纸张与边距等打印参数（同 /pdf）。
+	PDF *PDFOptions `json:"pdf"`
+}
+
+// batchItemResult 为单个截图任务的结果：成功时携带截图（ndjson 为 base64，zip 为文件名），失败时携带错误信息。
+type batchItemResult struct {
+	Index       int               `json:"index"`
+	URL         string            `json:"url,omitempty"`
+	Status      int               `json:"status"`
+	ContentType string            `json:"content_type,omitempty"`
+	File        string            `json:"file,omitempty"`
+	ImageBase64 string            `json:"image_base64,omitempty"`
+	JSON        json.RawMessage   `json:"json,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Code        string            `json:"code,omitempty"`
+	NetError    string            `json:"net_error,omitempty"`
+	Details     string            `json:"details,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+
+	body []byte
+}
+
+// batchLimits 读取 BATCH_MAX_ITEMS / BATCH_MAX_PARALLELISM（未配置或非法时使用默认值，并发默认值受 cgroup CPU 限制约束）。
+func batchLimits() (maxItems, maxParallelism int) {
+	maxItems, maxParallelism = defaultBatchMaxItems, resourceLimits.defaultBatchParallelism()
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("BATCH_MAX_ITEMS"))); err == nil && n > 0 {
+		maxItems = n
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("BATCH_MAX_PARALLELISM"))); err == nil && n > 0 {
+		maxParallelism = n
+	}
+	return maxItems, maxParallelism
+}
+
+// runBatchItem 在进程内以 POST /screenshot 的方式执行单个任务，复用截图接口的全部参数校验与错误映射；
+// ctx 携带调用方的限流维度，使每一项计入同一客户端。
+func runBatchItem(ctx context.Context, gate http.Handler, index int, raw json.RawMessage) batchItemResult {
+	res := batchItemResult{Index: index}
+	var head struct {
+		URL string `json:"url"`
+	}
+	_ = json.Unmarshal(raw, &head)
+	res.URL = head.URL
+
+	rec := httptest.NewRecorder()
+	ireq := httptest.NewRequest(http.MethodPost, "/screenshot", bytes.NewReader(raw)).WithContext(ctx)
+	ireq.Header.Set("Content-Type", "application/json")
+	gate.ServeHTTP(rec, ireq)
+
+	res.Status = rec.Code
+	res.ContentType = rec.Header().Get("Content-Type")
+	for k, v := range rec.Header() {
+		if strings.HasPrefix(k, "X-") && len(v) > 0 {
+			if res.Headers == nil {
+				res.Headers = map[string]string{}
+			}
+			res.Headers[k] = v[0]
+		}
+	}
+
+	if rec.Code != http.StatusOK {
+		var e struct {
+			Error    string `json:"error"`
+			Code     string `json:"code"`
+			NetError string `json:"net_error"`
+			Details  string `json:"details"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &e); err == nil {
+			res.Error, res.Code, res.NetError, res.Details = e.Error, e.Code, e.NetError, e.Details
+		} else {
+			res.Error = strings.TrimSpace(rec.Body.String())
+		}
+		res.ContentType = ""
+		return res
+	}
+	res.body = rec.Body.Bytes()
+	return res
+}
+
+// batchFileName 按序号与返回类型生成 ZIP 中的文件名（如 003.png）。
+func batchFileName(index int, contentType string) string {
+	return fmt.Sprintf("%03d%s", index, extensionForContentType(contentType))
+}
+
+// extensionForContentType 返回输出类型对应的扩展名（含 "."），未知类型为 .bin。
+func extensionForContentType(contentType string) string {
+	ext := ".bin"
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mt {
+		case "image/png":
+			ext = ".png"
+		case "image/jpeg":
+			ext = ".jpg"
+		case "image/webp":
+			ext = ".webp"
+		case "application/json":
+			ext = ".json"
+		case "application/zip":
+			ext = ".zip"
+		case "application/pdf":
+			ext = ".pdf"
+		}
+	}
+	return ext
+}
+
+func batchHandler() gin.HandlerFunc {
+	// 每一项像独立的 POST /screenshot 一样经过限流与并发限制，避免整批只占一个名额却同时进行多个截图
+	gate := gin.New()
+	// 与主路由的 gin.Default 一样恢复 panic：单项失败只返回该项的 500，不影响整批与进程
+	gate.Use(gin.CustomRecovery(func(c *gin.Context, _ any) {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}))
+	gate.POST("/screenshot", append(captureMiddlewares(), screenshotHandler())...)
+	return func(c *gin.Context) {
+		var req BatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body"})
+			return
+		}
+
+		maxItems, maxParallelism := batchLimits()
+		if len(req.Items) == 0 || len(req.Items) > maxItems {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("items must contain between 1 and %d requests", maxItems)})
+			return
+		}
+		if req.Parallelism == 0 {
+			req.Parallelism = defaultBatchParallelism
+		}
+		if req.Parallelism < 1 || req.Parallelism > maxParallelism {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("parallelism must be between 1 and %d", maxParallelism)})
+			return
+		}
+		req.Output = strings.ToLower(strings.TrimSpace(req.Output))
+		if req.Output == "" {
+			req.Output = batchOutputNDJSON
+		}
+		if req.Output != batchOutputZip && req.Output != batchOutputNDJSON && req.Output != batchOutputPDF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "output must be one of: zip, ndjson, pdf"})
+			return
+		}
+		var printParams *page.PrintToPDFParams
+		if req.Output == batchOutputPDF {
+			if req.PDF == nil {
+				req.PDF = &PDFOptions{}
+			}
+			var err error
+			if printParams, err = req.PDF.printParams(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		// 固定数量的 worker 执行任务，结果按完成顺序写出（index 对应 items 中的位置）。
+		itemCtx := withClientKey(c.Request.Context(), clientKey(c))
+		jobs := make(chan int)
+		results := make(chan batchItemResult)
+		var wg sync.WaitGroup
+		for w := 0; w < req.Parallelism; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					if c.Request.Context().Err() != nil {
+						// 客户端已断开：剩余任务不再执行
+						results <- batchItemResult{Index: i, Status: http.StatusRequestTimeout, Error: "batch cancelled"}
+						continue
+					}
+					results <- runBatchItem(itemCtx, gate, i, req.Items[i])
+				}
+			}()
+		}
+		go func() {
+			for i := range req.Items {
+				jobs <- i
+			}
+			close(jobs)
+			wg.Wait()
+			close(results)
+		}()
+
+		if req.Output == batchOutputPDF {
+			respondBatchPDF(c, results, len(req.Items), printParams)
+			return
+		}
+
+		if req.Output == batchOutputZip {
+			c.Header("Content-Type", "application/zip")
+			c.Header("Content-Disposition", `attachment; filename="screenshots.zip"`)
+			c.Status(http.StatusOK)
+			zw := zip.NewWriter(c.Writer)
+			manifest := make([]batchItemResult, len(req.Items))
+			for res := range results {
+				if res.body != nil {
+					res.File = batchFileName(res.Index, res.ContentType)
+					if f, err := zw.Create(res.File); err == nil {
+						_, _ = f.Write(res.body)
+					}
+				}
+				manifest[res.Index] = res
+			}
+			if f, err := zw.Create("manifest.json"); err == nil {
+				enc := json.NewEncoder(f)
+				enc.SetIndent("", "  ")
+				_ = enc.Encode(manifest)
+			}
+			_ = zw.Close()
+			return
+		}
+
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		enc := json.NewEncoder(c.Writer)
+		for res := range results {
+			if res.body != nil {
+				if strings.HasPrefix(res.ContentType, "application/json") {
+					res.JSON = res.body
+				} else {
+					res.ImageBase64 = base64.StdEncoding.EncodeToString(res.body)
+				}
+			}
+			_ = enc.Encode(res)
+			c.Writer.Flush()
+		}
+	}
+}
+
+// respondBatchPDF 等待全部任务完成后，把成功的图片结果按 items 顺序合成为一个 PDF（每页一张）；
+// 失败或非图片的任务不进入 PDF，其序号在 X-Batch-Failed 中列出。
+func respondBatchPDF(c *gin.Context, results <-chan batchItemResult, n int, printParams *page.PrintToPDFParams) {
+	ordered := make([]batchItemResult, n)
+	for res := range results {
+		ordered[res.Index] = res
+	}
+	var images []assembledImage
+	var failed []string
+	for _, res := range ordered {
+		if res.body == nil || !strings.HasPrefix(res.ContentType, "image/") {
+			failed = append(failed, strconv.Itoa(res.Index))
+			continue
+		}
+		images = append(images, assembledImage{contentType: res.ContentType, data: res.body})
+	}
+	if len(images) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "no successful image captures to assemble", "items": ordered})
+		return
+	}
+	if captureSlots != nil {
+		release, ok := captureSlots.hold(c)
+		if !ok {
+			return
+		}
+		defer release()
+	}
+	pdf := assemblePDF(c, images, printParams)
+	if pdf == nil {
+		return
+	}
+	if len(failed) > 0 {
+		c.Header("X-Batch-Failed", strings.Join(failed, ","))
+	}
+	respondAssembledPDF(c, pdf, len(images))
+}
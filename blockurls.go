@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	maxBlockURLs       = 100
+	maxBlockURLPattern = 1024
+)
+
+// validateBlockURLs 校验 block_urls：Chrome URL 通配模式（* 匹配任意字符），如 "*googletagmanager*"、"*.doubleclick.net/*"。
+func (r *ScreenshotRequest) validateBlockURLs() error {
+	if len(r.BlockURLs) > maxBlockURLs {
+		return fmt.Errorf("block_urls must contain at most %d patterns", maxBlockURLs)
+	}
+	for i, p := range r.BlockURLs {
+		r.BlockURLs[i] = strings.TrimSpace(p)
+		if r.BlockURLs[i] == "" {
+			return fmt.Errorf("block_urls[%d] is empty", i)
+		}
+		if len(r.BlockURLs[i]) > maxBlockURLPattern {
+			return fmt.Errorf("block_urls[%d] must be at most %d characters", i, maxBlockURLPattern)
+		}
+	}
+	if r.HTML == "" && len(r.Navigate) == 0 && urlMatchesAnyPattern(r.URL, r.BlockURLs) {
+		return errors.New("block_urls must not match the target url")
+	}
+	return nil
+}
+
+// blockedURLs 返回通过 Network.setBlockedURLs 屏蔽的模式：缩略图模式的重资源与 block_urls。
+func (r *ScreenshotRequest) blockedURLs() []string {
+	var out []string
+	if r.Mode == modeThumbnail {
+		out = append(out, thumbnailBlockedURLs...)
+	}
+	return append(out, r.BlockURLs...)
+}
+
+// urlMatchesAnyPattern 按 Network.setBlockedURLs 的规则（整串匹配，* 匹配任意字符，区分大小写）判断 u 是否被屏蔽。
+func urlMatchesAnyPattern(u string, patterns []string) bool {
+	for _, p := range patterns {
+		if wildcardMatch(p, u) {
+			return true
+		}
+	}
+	return false
+}
+
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// imageSampleSize 计算 BlurHash 与调色板前把图片缩小到的最长边（像素），两者都只需低频信息。
+	imageSampleSize = 64
+	// maxBlurhashBodyBytes 为 POST /blurhash 请求体上限。
+	maxBlurhashBodyBytes = 32 << 20
+)
+
+// blurhashEnabled 为每次图片截图计算 BlurHash 占位符，通过 X-Blurhash 响应头与 response_type=json 的 blurhash 字段返回；
+// BLURHASH=false 时关闭。
+var blurhashEnabled = func() bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("BLURHASH")))
+	return err != nil || v
+}()
+
+// rgbaSample 为缩小后的 RGBA 像素（每像素 4 字节）。
+type rgbaSample struct {
+	Width  int
+	Height int
+	Pix    []byte
+}
+
+// blurhashComponents 按宽高比选择分量数：长边 4 个分量，短边按比例（1-9）。
+func blurhashComponents(w, h int) (int, int) {
+	clamp := func(v float64) int { return max(1, min(9, int(math.Round(v)))) }
+	if w >= h {
+		return 4, clamp(4 * float64(h) / float64(w))
+	}
+	return clamp(4 * float64(w) / float64(h)), 4
+}
+
+// encodeBlurhash 按 BlurHash 规范（https://github.com/woltapp/blurhash）编码；alpha 通道被忽略。
+func encodeBlurhash(s rgbaSample) string {
+	xComp, yComp := blurhashComponents(s.Width, s.Height)
+	factors := make([][3]float64, 0, xComp*yComp)
+	for j := 0; j < yComp; j++ {
+		for i := 0; i < xComp; i++ {
+			var r, g, b float64
+			norm := 2.0
+			if i == 0 && j == 0 {
+				norm = 1
+			}
+			for y := 0; y < s.Height; y++ {
+				cy := math.Cos(math.Pi * float64(j) * float64(y) / float64(s.Height))
+				for x := 0; x < s.Width; x++ {
+					basis := norm * math.Cos(math.Pi*float64(i)*float64(x)/float64(s.Width)) * cy
+					p := (y*s.Width + x) * 4
+					r += basis * srgbToLinear(s.Pix[p])
+					g += basis * srgbToLinear(s.Pix[p+1])
+					b += basis * srgbToLinear(s.Pix[p+2])
+				}
+			}
+			scale := 1 / float64(s.Width*s.Height)
+			factors = append(factors, [3]float64{r * scale, g * scale, b * scale})
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(base83((xComp-1)+(yComp-1)*9, 1))
+	dc, ac := factors[0], factors[1:]
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantised := max(0, min(82, int(math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantised+1) / 166
+		sb.WriteString(base83(quantised, 1))
+	} else {
+		sb.WriteString(base83(0, 1))
+	}
+	sb.WriteString(base83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, f := range ac {
+		q := func(v float64) int {
+			return max(0, min(18, int(math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		sb.WriteString(base83(q(f[0])*19*19+q(f[1])*19+q(f[2]), 2))
+	}
+	return sb.String()
+}
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+func base83(v, length int) string {
+	out := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (v / int(math.Pow(83, float64(length-i)))) % 83
+		out[i-1] = base83Chars[digit]
+	}
+	return string(out)
+}
+
+func srgbToLinear(v byte) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+// sampleImage 把解码后的图片按最近邻缩小到最长边不超过 size。
+func sampleImage(img image.Image, size int) rgbaSample {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	sw, sh := w, h
+	if w > size || h > size {
+		scale := float64(size) / float64(max(w, h))
+		sw, sh = max(1, int(math.Round(float64(w)*scale))), max(1, int(math.Round(float64(h)*scale)))
+	}
+	pix := make([]byte, 0, sw*sh*4)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			r, g, bl, a := img.At(b.Min.X+x*w/sw, b.Min.Y+y*h/sh).RGBA()
+			pix = append(pix, byte(r>>8), byte(g>>8), byte(bl>>8), byte(a>>8))
+		}
+	}
+	return rgbaSample{Width: sw, Height: sh, Pix: pix}
+}
+
+// sampleImageJS 在页面中解码截图并缩小到最长边 size，返回 RGBA 像素（base64）。
+const sampleImageJS = `async (b64, type, size) => {
+	const bin = atob(b64);
+	const bytes = new Uint8Array(bin.length);
+	for (let i = 0; i < bin.length; i++) bytes[i] = bin.charCodeAt(i);
+	const bmp = await createImageBitmap(new Blob([bytes], { type }));
+	const scale = Math.min(1, size / Math.max(bmp.width, bmp.height));
+	const w = Math.max(1, Math.round(bmp.width * scale)), h = Math.max(1, Math.round(bmp.height * scale));
+	const cv = new OffscreenCanvas(w, h);
+	const g = cv.getContext('2d', { willReadFrequently: true });
+	g.drawImage(bmp, 0, 0, w, h);
+	const d = g.getImageData(0, 0, w, h).data;
+	let s = '';
+	for (let i = 0; i < d.length; i += 0x8000) s += String.fromCharCode.apply(null, d.subarray(i, i + 0x8000));
+	return { width: w, height: h, pix: btoa(s) };
+}`
+
+// sampleImageAction 在当前 tab 中解码并缩小截图（支持 png/jpeg/webp），写入 *out，供 BlurHash 与调色板使用；
+// 两者只是附加信息，失败时留空而不影响截图本身。
+func sampleImageAction(req *ScreenshotRequest, img *[]byte, out *rgbaSample) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if len(*img) == 0 {
+			return nil
+		}
+		args, err := json.Marshal([]interface{}{
+			base64.StdEncoding.EncodeToString(*img), contentTypeForFormat(req.Format), imageSampleSize,
+		})
+		if err != nil {
+			return err
+		}
+		var res struct {
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+			Pix    string `json:"pix"`
+		}
+		expr := fmt.Sprintf("(%s)(...%s)", sampleImageJS, args)
+		if err := chromedp.EvaluateAsDevTools(expr, &res, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}).Do(ctx); err != nil {
+			return nil
+		}
+		pix, err := base64.StdEncoding.DecodeString(res.Pix)
+		if err != nil || len(pix) == 0 || len(pix) != res.Width*res.Height*4 {
+			return nil
+		}
+		*out = rgbaSample{Width: res.Width, Height: res.Height, Pix: pix}
+		return nil
+	})
+}
+
+// blurhashHandler 处理 POST /blurhash：请求体为之前返回的截图（PNG / JPEG），返回 BlurHash 与图片尺寸。
+// 本服务不能在 Go 中解码 WebP，WebP 返回 415。
+func blurhashHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBlurhashBodyBytes+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		if len(body) > maxBlurhashBodyBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image too large"})
+			return
+		}
+		if _, _, ok := webpDimensions(body); ok {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "webp is not supported, use png or jpeg", "code": "UNSUPPORTED_FORMAT"})
+			return
+		}
+		img, _, err := image.Decode(bytes.NewReader(body))
+		if err != nil {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "request body must be a png or jpeg image", "code": "UNSUPPORTED_FORMAT"})
+			return
+		}
+		b := img.Bounds()
+		c.JSON(http.StatusOK, gin.H{
+			"blurhash": encodeBlurhash(sampleImage(img, imageSampleSize)),
+			"width":    b.Dx(),
+			"height":   b.Dy(),
+		})
+	}
+}
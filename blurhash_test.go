@@ -0,0 +1,77 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// 参考值由按 woltapp/blurhash 的 TypeScript 编码器（encode.ts）移植的独立实现计算；全黑图片的结果与其他实现一致。
+func TestEncodeBlurhashReference(t *testing.T) {
+	tests := []struct {
+		name string
+		w, h int
+		at   func(x, y int) color.RGBA
+		want string
+	}{
+		{
+			name: "black",
+			w:    32, h: 24,
+			at:   func(x, y int) color.RGBA { return color.RGBA{A: 255} },
+			want: "L00000fQfQfQfQfQfQfQfQfQfQfQ",
+		},
+		{
+			name: "gradient",
+			w:    32, h: 24,
+			at: func(x, y int) color.RGBA {
+				return color.RGBA{R: uint8(x * 8), G: uint8(y * 10), B: uint8(255 - x*4 - y*3), A: 255}
+			},
+			want: "LxH2812yw#XAmLWZjuf8gLfkfQfk",
+		},
+		{
+			name: "portrait checkerboard",
+			w:    20, h: 40,
+			at: func(x, y int) color.RGBA {
+				var r uint8
+				if (x/5+y/5)%2 == 1 {
+					r = 255
+				}
+				return color.RGBA{R: r, G: 128, B: uint8(x * 12), A: 255}
+			},
+			want: "SnLoKax5s:SLfQfQs:SL",
+		},
+	}
+	for _, tt := range tests {
+		img := image.NewRGBA(image.Rect(0, 0, tt.w, tt.h))
+		for y := 0; y < tt.h; y++ {
+			for x := 0; x < tt.w; x++ {
+				img.SetRGBA(x, y, tt.at(x, y))
+			}
+		}
+		if got := encodeBlurhash(sampleImage(img, imageSampleSize)); got != tt.want {
+			t.Errorf("%s: blurhash = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBlurhashComponents(t *testing.T) {
+	tests := []struct{ w, h, x, y int }{
+		{1280, 720, 4, 2},
+		{800, 600, 4, 3},
+		{100, 100, 4, 4},
+		{20, 40, 2, 4},
+		{1280, 20000, 1, 4},
+	}
+	for _, tt := range tests {
+		if x, y := blurhashComponents(tt.w, tt.h); x != tt.x || y != tt.y {
+			t.Errorf("blurhashComponents(%d, %d) = %d, %d, want %d, %d", tt.w, tt.h, x, y, tt.x, tt.y)
+		}
+	}
+}
+
+func TestSampleImageDownscales(t *testing.T) {
+	s := sampleImage(image.NewRGBA(image.Rect(0, 0, 1280, 720)), imageSampleSize)
+	if s.Width != 64 || s.Height != 36 || len(s.Pix) != 64*36*4 {
+		t.Errorf("sample = %dx%d (%d bytes), want 64x36", s.Width, s.Height, len(s.Pix))
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultCircuitBreakerCooldown = 30 * time.Second
+	circuitProbeTimeout           = 10 * time.Second
+)
+
+// upstreamBreaker 为上游 Chrome 的熔断器；未配置 CIRCUIT_BREAKER_THRESHOLD（或为 0）时为 nil（不启用）。
+var upstreamBreaker = newCircuitBreakerFromEnv()
+
+// circuitBreaker 在连续 threshold 次解析/连接上游失败后断开：之后的请求直接返回 503（带 Retry-After），
+// 不再逐个请求去 dial 已经不可用的上游；后台每隔 cooldown 探测一次，探测成功后恢复。
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int // 连续失败次数
+	open      bool
+	openedAt  time.Time
+	nextProbe time.Time
+	lastErr   string
+	trips     uint64
+	rejected  uint64
+}
+
+// newCircuitBreakerFromEnv 读取 CIRCUIT_BREAKER_THRESHOLD（默认 0 关闭）/ CIRCUIT_BREAKER_COOLDOWN（Go duration，默认 30s）。
+func newCircuitBreakerFromEnv() *circuitBreaker {
+	threshold, err := strconv.Atoi(strings.TrimSpace(os.Getenv("CIRCUIT_BREAKER_THRESHOLD")))
+	if err != nil || threshold <= 0 {
+		return nil
+	}
+	cooldown := envDuration("CIRCUIT_BREAKER_COOLDOWN", defaultCircuitBreakerCooldown)
+	if cooldown <= 0 {
+		cooldown = defaultCircuitBreakerCooldown
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow 熔断打开时返回 false 以及建议的重试等待时间。
+func (cb *circuitBreaker) allow() (time.Duration, bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if !cb.open {
+		return 0, true
+	}
+	cb.rejected++
+	return time.Until(cb.nextProbe), false
+}
+
+func (cb *circuitBreaker) isOpen() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.open
+}
+
+func (cb *circuitBreaker) success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures = 0
+}
+
+// failure 记录一次上游失败；达到阈值时打开熔断并启动后台探测。
+func (cb *circuitBreaker) failure(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures++
+	cb.lastErr = redactURLsInString(err.Error())
+	if cb.open || cb.failures < cb.threshold {
+		return
+	}
+	cb.open = true
+	cb.openedAt = time.Now()
+	cb.nextProbe = cb.openedAt.Add(cb.cooldown)
+	cb.trips++
+	log.Printf("circuitBreaker: open after %d consecutive upstream failures: %s", cb.failures, cb.lastErr)
+	go cb.probe()
+}
+
+// probe 每隔 cooldown 尝试解析 endpoint 并完成一次 dial，成功即关闭熔断。
+func (cb *circuitBreaker) probe() {
+	for {
+		cb.mu.Lock()
+		wait := time.Until(cb.nextProbe)
+		cb.mu.Unlock()
+		time.Sleep(wait)
+
+		err := probeUpstream()
+		cb.mu.Lock()
+		if err == nil {
+			cb.open = false
+			cb.failures = 0
+			cb.mu.Unlock()
+			log.Printf("circuitBreaker: upstream recovered, closed")
+			return
+		}
+		cb.lastErr = redactURLsInString(err.Error())
+		cb.nextProbe = time.Now().Add(cb.cooldown)
+		cb.mu.Unlock()
+		log.Printf("circuitBreaker: probe failed, still open: %s", cb.lastErr)
+	}
+}
+
+func probeUpstream() error {
+	ctx, cancel := context.WithTimeout(context.Background(), circuitProbeTimeout)
+	defer cancel()
+	wsURL, configured, err := resolveWSEndpoint(ctx)
+	if !configured {
+		return errBrowserlessNotConfigured
+	}
+	if err != nil {
+		return err
+	}
+	b, err := dialPooledBrowser(wsURL)
+	if err != nil {
+		upstreams.reportFailure(wsURL, err)
+		return err
+	}
+	b.cancel()
+	return nil
+}
+
+// recordUpstreamResult 记录一次解析/连接上游的结果（未启用熔断时忽略），需在 upstreams 记录该地址的失败之后调用。
+// ctx 为请求的 context：它已结束（请求 timeout 过短、客户端断开）时的失败不说明上游不可用，不计入熔断；
+// 配置多个 browserless 地址时，单个地址的失败由负载均衡退避处理，只有所有地址都在退避期时才计入熔断。
+func recordUpstreamResult(ctx context.Context, err error) {
+	if upstreamBreaker == nil {
+		return
+	}
+	if err != nil {
+		if ctx.Err() != nil || !upstreams.allDown() {
+			return
+		}
+		upstreamBreaker.failure(err)
+		return
+	}
+	upstreamBreaker.success()
+}
+
+// respondCircuitOpen 熔断打开时快速失败：503 + Retry-After（秒）。
+func respondCircuitOpen(c *gin.Context, retryAfter time.Duration) {
+	secs := int(math.Ceil(retryAfter.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(secs))
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error":   "upstream chrome is unavailable",
+		"code":    "CIRCUIT_OPEN",
+		"details": fmt.Sprintf("circuit breaker is open after repeated upstream failures, retry after %ds", secs),
+	})
+}
+
+// stats 返回熔断器状态，用于 /health。
+func (cb *circuitBreaker) stats() map[string]interface{} {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	state := "closed"
+	if cb.open {
+		state = "open"
+	}
+	out := map[string]interface{}{
+		"state":     state,
+		"failures":  cb.failures,
+		"threshold": cb.threshold,
+		"trips":     cb.trips,
+		"rejected":  cb.rejected,
+	}
+	if cb.open {
+		out["opened_at"] = cb.openedAt.UTC().Format(time.RFC3339)
+		out["next_probe"] = cb.nextProbe.UTC().Format(time.RFC3339)
+	}
+	if cb.lastErr != "" {
+		out["last_error"] = cb.lastErr
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// captureBudgetReserveMax 为截图本身预留的最长时间。导航/等待阶段最多只能用到
+// “请求 deadline - 预留时间”，避免 context 在 CaptureScreenshot 的 CDP 调用中途过期，
+// 导致返回难以理解的 websocket/context 错误。
+const captureBudgetReserveMax = 3 * time.Second
+
+// errBudgetExceeded 表示剩余时间预算不足以完成某个阶段（BUDGET_EXCEEDED）。
+type errBudgetExceeded struct {
+	stage string
+}
+
+func (e *errBudgetExceeded) Error() string {
+	return fmt.Sprintf("time budget exceeded during %s", e.stage)
+}
+
+// captureBudget 按请求 deadline 约束导航与各类等待。
+// bestEffort 为 true 时，预算耗尽后跳过剩余等待并继续截图，而不是返回错误。
+type captureBudget struct {
+	reserve    time.Duration
+	bestEffort bool
+
+	mu       sync.Mutex
+	exceeded bool
+	skipped  []string
+}
+
+func newCaptureBudget(timeout time.Duration, bestEffort bool) *captureBudget {
+	reserve := timeout / 3
+	if reserve > captureBudgetReserveMax {
+		reserve = captureBudgetReserveMax
+	}
+	return &captureBudget{reserve: reserve, bestEffort: bestEffort}
+}
+
+// skippedStages 返回 best-effort 模式下因预算不足而被跳过/中断的阶段。
+func (b *captureBudget) skippedStages() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.skipped...)
+}
+
+func (b *captureBudget) exceed(stage string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.bestEffort {
+		return &errBudgetExceeded{stage: stage}
+	}
+	b.exceeded = true
+	b.skipped = append(b.skipped, stage)
+	return nil
+}
+
+// wait 在剩余预算内执行等待类动作。minNeeded > 0 时（如固定 sleep），若剩余预算不足则不再开始等待。
+func (b *captureBudget) wait(stage string, minNeeded time.Duration, a chromedp.Action) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		b.mu.Lock()
+		exceeded := b.exceeded
+		b.mu.Unlock()
+		if exceeded {
+			// best-effort：预算已耗尽，后续等待一律跳过
+			return b.exceed(stage)
+		}
+
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return a.Do(ctx)
+		}
+		remaining := time.Until(deadline) - b.reserve
+		if remaining <= 0 || remaining < minNeeded {
+			return b.exceed(stage)
+		}
+
+		wctx, cancel := context.WithTimeout(ctx, remaining)
+		defer cancel()
+		err := a.Do(wctx)
+		if err != nil && errors.Is(wctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return b.exceed(stage)
+		}
+		return err
+	})
+}
+
+// budgetExceededStage 返回 BUDGET_EXCEEDED 错误的阶段名（非该类错误返回空串）。
+func budgetExceededStage(err error) string {
+	var be *errBudgetExceeded
+	if errors.As(err, &be) {
+		return be.stage
+	}
+	return ""
+}
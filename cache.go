@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultResponseCacheMaxEntries = 1024
+
+// cachedHeaders 随截图一起缓存的结果类响应头（命中时原样返回）。
+var cachedHeaders = []string{"X-Element-Info", "X-Font-Report", "X-Trim", "X-Filter-Skipped", "X-Capture-Truncated", "X-Final-URL", "X-Page-Title", "X-Page-Status", "X-Image-Width", "X-Image-Height", "X-Blurhash", "X-Dominant-Color", "X-Palette"}
+
+// cachedResponse 为一次截图的缓存内容。
+type cachedResponse struct {
+	ContentType string            `json:"content_type"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        []byte            `json:"body"`
+	Expires     time.Time         `json:"expires"`
+}
+
+// responseCacheStore 为相同请求（应用默认值与校验后的参数完全一致）的截图结果缓存：
+// 默认进程内，配置 RESPONSE_CACHE_REDIS_URL 时改用 Redis（多实例共享）。
+type responseCacheStore struct {
+	ttl   time.Duration
+	local *ttlCache
+	redis *redisClient
+}
+
+// responseCache 未配置 RESPONSE_CACHE_TTL 时为 nil（不缓存）。
+var responseCache = newResponseCacheFromEnv()
+
+// newResponseCacheFromEnv 读取 RESPONSE_CACHE_TTL / RESPONSE_CACHE_MAX_ENTRIES / RESPONSE_CACHE_REDIS_URL。
+func newResponseCacheFromEnv() *responseCacheStore {
+	ttl := envDuration("RESPONSE_CACHE_TTL", 0)
+	if ttl <= 0 {
+		return nil
+	}
+	store := &responseCacheStore{ttl: ttl}
+	if raw := strings.TrimSpace(os.Getenv("RESPONSE_CACHE_REDIS_URL")); raw != "" {
+		rc, err := newRedisClient(raw)
+		if err != nil {
+			log.Printf("response cache: invalid RESPONSE_CACHE_REDIS_URL, falling back to in-process cache: %v", err)
+		} else {
+			store.redis = rc
+			return store
+		}
+	}
+	maxEntries, err := strconv.Atoi(strings.TrimSpace(os.Getenv("RESPONSE_CACHE_MAX_ENTRIES")))
+	if err != nil || maxEntries <= 0 {
+		maxEntries = resourceLimits.defaultResponseCacheEntries()
+	}
+	store.local = newTTLCache(maxEntries)
+	return store
+}
+
+// cacheable 仅缓存普通图片结果：trace、归档、分页 ZIP（含翻页截图）、已预热会话（页面状态不可复现）、缩略图（已有独立缓存）、
+// response_type=json（包含耗时等每次请求的信息）与 POST 导航（非幂等）除外。
+func (s *responseCacheStore) cacheable(req *ScreenshotRequest) bool {
+	return !req.Trace && req.Mode == "" && req.SessionID == "" && req.PaginateHeight == 0 && req.Paginate == nil && !req.Store && !req.RecordCDP && req.ResponseType != responseTypeJSON && req.Method == ""
+}
+
+// key 基于请求指纹；headers 等敏感参数只以摘要形式出现在键中。
+func (s *responseCacheStore) key(req *ScreenshotRequest) string {
+	return "screenshot-server:cache:" + requestFingerprint(req)
+}
+
+// get 缓存读取失败（如 Redis 不可用）时按未命中处理，不影响截图本身。
+func (s *responseCacheStore) get(key string) (*cachedResponse, time.Duration, bool) {
+	var raw []byte
+	if s.redis != nil {
+		v, err := s.redis.get(key)
+		if err != nil {
+			log.Printf("response cache: redis get failed: %v", err)
+			return nil, 0, false
+		}
+		raw = v
+	} else {
+		v, _, ok := s.local.get(key)
+		if !ok {
+			return nil, 0, false
+		}
+		raw = v
+	}
+	if raw == nil {
+		return nil, 0, false
+	}
+	var resp cachedResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, 0, false
+	}
+	remaining := time.Until(resp.Expires)
+	if remaining <= 0 {
+		return nil, 0, false
+	}
+	return &resp, remaining, true
+}
+
+func (s *responseCacheStore) put(key string, resp *cachedResponse) {
+	resp.Expires = time.Now().Add(s.ttl)
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	if s.redis != nil {
+		if err := s.redis.set(key, raw, s.ttl); err != nil {
+			log.Printf("response cache: redis set failed: %v", err)
+		}
+		return
+	}
+	s.local.put(key, raw, s.ttl)
+}
+
+// bypass 客户端发送 Cache-Control: no-cache（或 no-store）时跳过缓存读取，结果仍会写入缓存。
+func (s *responseCacheStore) bypass(c *gin.Context) bool {
+	cc := strings.ToLower(c.GetHeader("Cache-Control"))
+	return strings.Contains(cc, "no-cache") || strings.Contains(cc, "no-store")
+}
+
+func (s *responseCacheStore) stats() gin.H {
+	backend := "memory"
+	if s.redis != nil {
+		backend = "redis"
+	}
+	return gin.H{"backend": backend, "ttl": s.ttl.String()}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// canvasPreserveBufferScript 强制 WebGL 上下文使用 preserveDrawingBuffer，
+// 否则合成后的绘制缓冲会被清空，截图/读回时得到黑色或透明矩形。
+const canvasPreserveBufferScript = `(() => {
+	const realGetContext = HTMLCanvasElement.prototype.getContext;
+	HTMLCanvasElement.prototype.getContext = function (type, attrs) {
+		if (type === 'webgl' || type === 'webgl2' || type === 'experimental-webgl') {
+			attrs = Object.assign({}, attrs, { preserveDrawingBuffer: true });
+		}
+		return realGetContext.call(this, type, attrs);
+	};
+})()`
+
+// canvasNonBlankPredicate 将 canvas 缩放绘制到 32x32 的临时 2D canvas 上采样，
+// 只要存在与第一个像素不同的像素即认为已有内容（纯色/全黑/全透明视为空白）。
+// 跨域污染的 canvas 无法读回像素，此时直接视为就绪。
+const canvasNonBlankPredicate = `(selector) => {
+	const canvases = Array.from(document.querySelectorAll(selector)).filter((c) => c instanceof HTMLCanvasElement);
+	if (canvases.length === 0) return false;
+	for (const c of canvases) {
+		if (c.width === 0 || c.height === 0) return false;
+		const probe = document.createElement('canvas');
+		probe.width = 32;
+		probe.height = 32;
+		const ctx = probe.getContext('2d');
+		let data;
+		try {
+			ctx.drawImage(c, 0, 0, 32, 32);
+			data = ctx.getImageData(0, 0, 32, 32).data;
+		} catch (e) {
+			continue;
+		}
+		let blank = true;
+		for (let i = 4; i < data.length; i += 4) {
+			if (data[i] !== data[0] || data[i + 1] !== data[1] || data[i + 2] !== data[2] || data[i + 3] !== data[3]) {
+				blank = false;
+				break;
+			}
+		}
+		if (blank) return false;
+	}
+	return true;
+}`
+
+func canvasPreserveBufferAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		_, err := page.AddScriptToEvaluateOnNewDocument(canvasPreserveBufferScript).Do(ctx)
+		return err
+	})
+}
+
+// waitForCanvasAction 轮询直到 selector 匹配的所有 canvas 都有非空白内容；超时由请求整体 timeout 控制。
+func waitForCanvasAction(selector string) chromedp.Action {
+	var ok bool
+	return chromedp.PollFunction(canvasNonBlankPredicate, &ok,
+		chromedp.WithPollingArgs(selector),
+		chromedp.WithPollingInterval(100*time.Millisecond),
+		chromedp.WithPollingTimeout(0),
+	)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+// maxPageTitleHeader 为 X-Page-Title 中标题的最大字符数（编码前）。
+const maxPageTitleHeader = 256
+
+// outputInfo 为截图过程中收集的页面信息，用于元数据响应头（X-Final-URL 等）与 response_type=json。
+type outputInfo struct {
+	started time.Time
+	page    pageInfo
+	status  atomic.Int64 // 主框架最后一次文档响应的 HTTP 状态码（html 渲染时为 0）
+	// blurhash 为最终图片的 BlurHash 占位符（BLURHASH=false 或非图片输出时为空）
+	blurhash string
+	// palette 为 palette=true 时的主色与调色板
+	palette *ColorPalette
+}
+
+type pageInfo struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// listen 记录主框架文档响应的状态码；重定向的中间响应不会触发 responseReceived，因此记录的是最终页面的状态码。
+func (o *outputInfo) listen(ctx context.Context) {
+	c := chromedp.FromContext(ctx)
+	if c == nil || c.Target == nil {
+		return
+	}
+	// page 类型 target 的主框架 ID 与 target ID 相同
+	mainFrame := cdp.FrameID(c.Target.TargetID)
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		e, ok := ev.(*network.EventResponseReceived)
+		if !ok || e.Type != network.ResourceTypeDocument || e.FrameID != mainFrame {
+			return
+		}
+		o.status.Store(e.Response.Status)
+	})
+}
+
+// pageInfoAction 读取最终地址（跟随重定向后）与页面标题。
+func pageInfoAction(info *pageInfo) chromedp.Action {
+	return chromedp.Evaluate(`({url: location.href, title: document.title})`, info)
+}
+
+// setCaptureHeaders 设置截图元数据响应头，使客户端无需再次请求即可发现重定向与软错误（如 200 的错误页）：
+// X-Final-URL、X-Page-Title（UTF-8 百分号编码）、X-Page-Status、X-Capture-Duration-Ms，图片输出时另有 X-Image-Width/Height、X-Blurhash，palette=true 时另有 X-Dominant-Color / X-Palette。
+func setCaptureHeaders(c *gin.Context, info *outputInfo, img []byte) {
+	if info.page.URL != "" {
+		c.Header("X-Final-URL", info.page.URL)
+	}
+	if title := []rune(info.page.Title); len(title) > 0 {
+		if len(title) > maxPageTitleHeader {
+			title = title[:maxPageTitleHeader]
+		}
+		c.Header("X-Page-Title", url.PathEscape(string(title)))
+	}
+	if status := info.status.Load(); status > 0 {
+		c.Header("X-Page-Status", strconv.FormatInt(status, 10))
+	}
+	c.Header("X-Capture-Duration-Ms", strconv.FormatInt(time.Since(info.started).Milliseconds(), 10))
+	if w, h := imageDimensions(img); w > 0 {
+		c.Header("X-Image-Width", strconv.Itoa(w))
+		c.Header("X-Image-Height", strconv.Itoa(h))
+		if info.blurhash != "" {
+			c.Header("X-Blurhash", info.blurhash)
+		}
+		if info.palette != nil {
+			c.Header("X-Dominant-Color", info.palette.Dominant)
+			c.Header("X-Palette", info.palette.paletteHeader())
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultCaptureQueueTimeout = 30 * time.Second
+	// defaultCaptureDuration 为还没有完成任何截图时用于估算等待时间的单次耗时。
+	defaultCaptureDuration = 5 * time.Second
+)
+
+// captureSlots 限制整个实例同时进行的截图数（MAX_CONCURRENT_CAPTURES，默认按 cgroup 限制推导）；
+// 为 nil 时不限制。与按客户端的 RATE_LIMIT_CONCURRENCY 不同，它保护的是本进程的内存。
+var captureSlots = newCaptureLimiterFromEnv()
+
+// captureLimiter 为带等待队列的信号量：名额用完时请求排队，队列已满（CAPTURE_MAX_QUEUE）时立即返回 429，
+// 超过 CAPTURE_QUEUE_TIMEOUT 仍未轮到也返回 429。429 带 X-Estimated-Wait（按近期平均耗时估算的排队秒数）。
+type captureLimiter struct {
+	slots    chan struct{}
+	maxWait  time.Duration
+	maxQueue int
+	waiting  atomic.Int64
+	avgNanos atomic.Int64 // 近期单次截图耗时的指数移动平均
+}
+
+// newCaptureLimiterFromEnv 读取 MAX_CONCURRENT_CAPTURES（0 不限制）、CAPTURE_QUEUE_TIMEOUT（Go duration）
+// 与 CAPTURE_MAX_QUEUE（默认为并发上限的 4 倍）。
+func newCaptureLimiterFromEnv() *captureLimiter {
+	limit := resourceLimits.defaultMaxConcurrentCaptures()
+	if v := strings.TrimSpace(os.Getenv("MAX_CONCURRENT_CAPTURES")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			limit = n
+		}
+	}
+	if limit == 0 {
+		return nil
+	}
+	maxQueue := limit * 4
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("CAPTURE_MAX_QUEUE"))); err == nil && n >= 0 {
+		maxQueue = n
+	}
+	l := &captureLimiter{
+		slots:    make(chan struct{}, limit),
+		maxWait:  envDuration("CAPTURE_QUEUE_TIMEOUT", defaultCaptureQueueTimeout),
+		maxQueue: maxQueue,
+	}
+	l.avgNanos.Store(int64(defaultCaptureDuration))
+	return l
+}
+
+func (l *captureLimiter) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		release, ok := l.hold(c)
+		if !ok {
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
+
+// hold 占用一个名额（必要时排队），供不经过中间件的渲染（如批量任务的 PDF 合成）使用；
+// 返回 false 时已写入 429 或客户端已断开。
+func (l *captureLimiter) hold(c *gin.Context) (release func(), ok bool) {
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		if !l.wait(c) {
+			return nil, false
+		}
+	}
+	started := time.Now()
+	return func() {
+		<-l.slots
+		l.observe(time.Since(started))
+	}, true
+}
+
+// observe 以 0.2 的权重更新平均耗时。
+func (l *captureLimiter) observe(d time.Duration) {
+	for {
+		old := l.avgNanos.Load()
+		if l.avgNanos.CompareAndSwap(old, old+(int64(d)-old)/5) {
+			return
+		}
+	}
+}
+
+// estimatedWait 估算新请求需要排队的时间：前面的请求按并发上限分批，每批耗时为近期平均耗时。
+func (l *captureLimiter) estimatedWait() time.Duration {
+	ahead := int(l.waiting.Load()) + len(l.slots) - cap(l.slots) + 1
+	if ahead <= 0 {
+		return 0
+	}
+	rounds := (ahead + cap(l.slots) - 1) / cap(l.slots)
+	return time.Duration(rounds) * time.Duration(l.avgNanos.Load())
+}
+
+// reject 返回 429，Retry-After 与 X-Estimated-Wait 为估算的排队秒数（至少 1）。
+func (l *captureLimiter) reject(c *gin.Context) {
+	secs := strconv.Itoa(max(1, int(math.Ceil(l.estimatedWait().Seconds()))))
+	c.Header("Retry-After", secs)
+	c.Header("X-Estimated-Wait", secs)
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error": "server busy",
+		"code":  "CAPACITY_EXCEEDED",
+	})
+}
+
+// wait 排队等待名额；超时返回 429，客户端断开时直接放弃。
+func (l *captureLimiter) wait(c *gin.Context) bool {
+	if int(l.waiting.Load()) >= l.maxQueue {
+		l.reject(c)
+		return false
+	}
+	l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+	timer := time.NewTimer(l.maxWait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		l.reject(c)
+	case <-c.Request.Context().Done():
+		c.Abort()
+	}
+	return false
+}
+
+func (l *captureLimiter) stats() gin.H {
+	return gin.H{
+		"limit":             cap(l.slots),
+		"active":            len(l.slots),
+		"waiting":           l.waiting.Load(),
+		"max_queue":         l.maxQueue,
+		"avg_duration_ms":   time.Duration(l.avgNanos.Load()).Milliseconds(),
+		"estimated_wait_ms": l.estimatedWait().Milliseconds(),
+	}
+}
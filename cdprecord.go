@@ -0,0 +1,588 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+const (
+	cdpRecorderKey    = "cdpRecorder"
+	cdpReplayKey      = "cdpReplay"
+	cdpRecordingExt   = ".cdp.jsonl"
+	cdpRecordingRoute = "/debug/cdp"
+)
+
+var cdpRecordingIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// cdpRecordingDir 读取 CDP_RECORDING_DIR；为空时不允许 record_cdp，也不注册回放接口。
+func cdpRecordingDir() string {
+	return strings.TrimSpace(os.Getenv("CDP_RECORDING_DIR"))
+}
+
+// cdpRecordingRoutesEnabled 读取 CDP_RECORDING_ROUTES；录制文件包含页面内容与请求参数，
+// 下载与回放接口默认不注册，开启后与截图接口一样按客户端限流并占用并发名额。
+var cdpRecordingRoutesEnabled = func() bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("CDP_RECORDING_ROUTES")))
+	return err == nil && v
+}()
+
+// cdpRecordingHeader 为录制文件的第一行：回放时按其中的请求重新执行截图流程。
+type cdpRecordingHeader struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Path       string            `json:"path"`
+	RecordedAt string            `json:"recorded_at"`
+	Request    ScreenshotRequest `json:"request"`
+}
+
+// cdpRecordedMessage 为录制文件中的一条 CDP 消息：dir 为 send（发往浏览器）或 recv，t 为相对开始的毫秒数。
+type cdpRecordedMessage struct {
+	T   int64           `json:"t"`
+	Dir string          `json:"dir"`
+	Msg json.RawMessage `json:"msg"`
+}
+
+// cdpRecorder 通过 chromedp 的 debugf 钩子收集单个请求的 CDP 消息（脱敏后），请求结束时写入文件。
+type cdpRecorder struct {
+	id    string
+	start time.Time
+
+	// secrets 把请求中的凭据（cookie、web storage、POST body 等）在消息中出现的位置替换为 REDACTED
+	secrets *strings.Replacer
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// startCDPRecording 为 record_cdp 请求创建录制器并通过 X-CDP-Recording 返回录制 ID；调用方需 defer finish。
+func startCDPRecording(c *gin.Context, req *ScreenshotRequest) *cdpRecorder {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	rec := &cdpRecorder{id: hex.EncodeToString(id), start: time.Now()}
+
+	header := cdpRecordingHeader{
+		Type:       "header",
+		ID:         rec.id,
+		Path:       c.FullPath(),
+		RecordedAt: rec.start.UTC().Format(time.RFC3339),
+		Request:    *req,
+	}
+	header.Request.RecordCDP = false
+	var secrets []string
+	if len(req.Headers) > 0 {
+		header.Request.Headers = make(map[string]string, len(req.Headers))
+		for k, v := range req.Headers {
+			if _, ok := sensitiveHeaderNames[strings.ToLower(k)]; ok {
+				secrets = append(secrets, v)
+				v = "REDACTED"
+			}
+			header.Request.Headers[k] = v
+		}
+	}
+	if len(req.Cookies) > 0 {
+		header.Request.Cookies = make([]Cookie, len(req.Cookies))
+		for i, ck := range req.Cookies {
+			secrets = append(secrets, ck.Value)
+			ck.Value = "REDACTED"
+			header.Request.Cookies[i] = ck
+		}
+	}
+	for _, m := range []map[string]string{req.LocalStorage, req.SessionStorage} {
+		for _, v := range m {
+			secrets = append(secrets, v)
+		}
+	}
+	header.Request.LocalStorage = redactedValues(req.LocalStorage)
+	header.Request.SessionStorage = redactedValues(req.SessionStorage)
+	if req.Body != "" {
+		secrets = append(secrets, req.Body)
+		header.Request.Body = "REDACTED"
+	}
+	var pw string
+	header.Request.URL, pw = redactURLPassword(req.URL)
+	secrets = append(secrets, pw)
+	header.Request.Referer, pw = redactURLPassword(req.Referer)
+	secrets = append(secrets, pw)
+	rec.secrets = secretReplacer(secrets)
+
+	line, _ := json.Marshal(header)
+	rec.buf.Write(line)
+	rec.buf.WriteByte('\n')
+
+	c.Set(cdpRecorderKey, rec)
+	c.Header("X-CDP-Recording", rec.id)
+	return rec
+}
+
+func cdpRecorderFrom(c *gin.Context) *cdpRecorder {
+	v, _ := c.Get(cdpRecorderKey)
+	rec, _ := v.(*cdpRecorder)
+	return rec
+}
+
+// contextOptions 返回挂载录制钩子的 chromedp 选项；rec 为 nil 时为空。
+func (r *cdpRecorder) contextOptions() []chromedp.ContextOption {
+	if r == nil {
+		return nil
+	}
+	return []chromedp.ContextOption{chromedp.WithDebugf(r.debugf)}
+}
+
+// debugf 接收 chromedp 连接层的 "-> %s"（发送）与 "<- %s"（接收）日志，其余调试信息忽略。
+func (r *cdpRecorder) debugf(format string, args ...any) {
+	var dir string
+	switch format {
+	case "-> %s":
+		dir = "send"
+	case "<- %s":
+		dir = "recv"
+	default:
+		return
+	}
+	if len(args) != 1 {
+		return
+	}
+	raw, ok := args[0].([]byte)
+	if !ok {
+		return
+	}
+	line, err := json.Marshal(cdpRecordedMessage{
+		T:   time.Since(r.start).Milliseconds(),
+		Dir: dir,
+		Msg: sanitizeCDPMessage(raw, r.secrets),
+	})
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	r.buf.Write(line)
+	r.buf.WriteByte('\n')
+	r.mu.Unlock()
+}
+
+// finish 把录制写入 CDP_RECORDING_DIR/<id>.cdp.jsonl。
+func (r *cdpRecorder) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := filepath.Join(cdpRecordingDir(), r.id+cdpRecordingExt)
+	if err := os.WriteFile(path, r.buf.Bytes(), 0o600); err != nil {
+		log.Printf("cdp recording %s: write failed: %v", r.id, err)
+		return
+	}
+	log.Printf("cdp recording %s: saved %d bytes to %s", r.id, r.buf.Len(), path)
+}
+
+// redactURLPassword 把 URL userinfo 中的密码替换为 REDACTED，返回新 URL 与原密码（无密码时为空）。
+func redactURLPassword(raw string) (string, string) {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw, ""
+	}
+	pw, ok := u.User.Password()
+	if !ok {
+		return raw, ""
+	}
+	u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	return u.String(), pw
+}
+
+// minCDPSecretLen 以下的值不做全文替换，避免把消息中无关的短字符串一并替换。
+const minCDPSecretLen = 4
+
+// secretReplacer 返回把 secrets 原文及其 JSON 转义形式（如 web storage 注入脚本中的取值）替换为 REDACTED 的 Replacer。
+func secretReplacer(secrets []string) *strings.Replacer {
+	var pairs []string
+	seen := map[string]bool{}
+	add := func(s string) {
+		if len(s) >= minCDPSecretLen && !seen[s] {
+			seen[s] = true
+			pairs = append(pairs, s, "REDACTED")
+		}
+	}
+	for _, s := range secrets {
+		add(s)
+		if b, err := json.Marshal(s); err == nil {
+			add(string(b[1 : len(b)-1]))
+		}
+		add(url.QueryEscape(s))
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// sanitizeCDPMessage 隐藏消息中的敏感请求/响应头（headers 对象或 {name,value} 数组）与 cookie 取值，
+// 并用 secrets（可为 nil）替换字符串中出现的请求凭据。
+func sanitizeCDPMessage(raw []byte, secrets *strings.Replacer) json.RawMessage {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		text := string(raw)
+		if secrets != nil {
+			text = secrets.Replace(text)
+		}
+		return json.RawMessage(strconv.Quote(text))
+	}
+	out, err := json.Marshal(sanitizeCDPValue(v, "", secrets))
+	if err != nil {
+		return json.RawMessage(`null`)
+	}
+	return out
+}
+
+func sanitizeCDPValue(v any, key string, secrets *strings.Replacer) any {
+	switch t := v.(type) {
+	case map[string]any:
+		if name, ok := t["name"].(string); ok && isSensitiveCDPHeader(name) {
+			if _, ok := t["value"]; ok {
+				t["value"] = "REDACTED"
+			}
+		}
+		for k, child := range t {
+			if key == "headers" && isSensitiveCDPHeader(k) {
+				t[k] = "REDACTED"
+				continue
+			}
+			t[k] = sanitizeCDPValue(child, k, secrets)
+		}
+		return t
+	case []any:
+		for i, child := range t {
+			if obj, ok := child.(map[string]any); ok && key == "cookies" {
+				if _, ok := obj["value"]; ok {
+					obj["value"] = "REDACTED"
+				}
+			}
+			t[i] = sanitizeCDPValue(child, key, secrets)
+		}
+		return t
+	case string:
+		if secrets != nil {
+			return secrets.Replace(t)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func isSensitiveCDPHeader(name string) bool {
+	name = strings.ToLower(name)
+	if name == "set-cookie" {
+		return true
+	}
+	_, ok := sensitiveHeaderNames[name]
+	return ok
+}
+
+// loadCDPRecording 读取录制文件：返回请求头与消息列表。
+func loadCDPRecording(id string) (*cdpRecordingHeader, []cdpRecordedMessage, error) {
+	if !cdpRecordingIDPattern.MatchString(id) {
+		return nil, nil, errors.New("invalid recording id")
+	}
+	f, err := os.Open(filepath.Join(cdpRecordingDir(), id+cdpRecordingExt))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 1<<20), 256<<20)
+	var header *cdpRecordingHeader
+	var msgs []cdpRecordedMessage
+	for sc.Scan() {
+		if header == nil {
+			header = &cdpRecordingHeader{}
+			if err := json.Unmarshal(sc.Bytes(), header); err != nil || header.Type != "header" {
+				return nil, nil, errors.New("invalid recording header")
+			}
+			continue
+		}
+		var m cdpRecordedMessage
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			return nil, nil, fmt.Errorf("invalid recording line: %w", err)
+		}
+		msgs = append(msgs, m)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, err
+	}
+	if header == nil {
+		return nil, nil, errors.New("empty recording")
+	}
+	return header, msgs, nil
+}
+
+// cdpFrame 为 CDP 消息中回放需要的字段。
+type cdpFrame struct {
+	ID        int64  `json:"id"`
+	Method    string `json:"method"`
+	SessionID string `json:"sessionId"`
+}
+
+type cdpReplayRecv struct {
+	raw   json.RawMessage
+	frame cdpFrame
+	owner int // 之前最近一条 send 的下标，-1 表示在任何 send 之前
+}
+
+// cdpReplayer 为进程内的假 CDP 服务器：按方法与 sessionId 依次匹配录制中的命令，
+// 返回录制的响应（替换为客户端的 id），并按录制顺序推送匹配命令之后的事件。不模拟时序。
+type cdpReplayer struct {
+	wsURL string
+
+	mu          sync.Mutex
+	sends       []cdpFrame
+	used        []bool
+	recvs       []cdpReplayRecv
+	emitted     []bool
+	cursor      int
+	idMap       map[int64]int64 // 录制 id -> 客户端 id
+	divergences int
+}
+
+func newCDPReplayer(msgs []cdpRecordedMessage) *cdpReplayer {
+	r := &cdpReplayer{idMap: map[int64]int64{}}
+	for _, m := range msgs {
+		var f cdpFrame
+		if err := json.Unmarshal(m.Msg, &f); err != nil {
+			continue
+		}
+		if m.Dir == "send" {
+			r.sends = append(r.sends, f)
+			continue
+		}
+		r.recvs = append(r.recvs, cdpReplayRecv{raw: m.Msg, frame: f, owner: len(r.sends) - 1})
+	}
+	r.used = make([]bool, len(r.sends))
+	r.emitted = make([]bool, len(r.recvs))
+	return r
+}
+
+// handle 处理一条客户端命令，返回需要按顺序写回客户端的消息。
+func (r *cdpReplayer) handle(data []byte) [][]byte {
+	var f cdpFrame
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	match := -1
+	for pass := 0; pass < 2 && match < 0; pass++ {
+		for i, s := range r.sends {
+			// 第二轮放宽 sessionId（同一 target 的 session 可能不同）
+			if !r.used[i] && s.Method == f.Method && (pass == 1 || s.SessionID == f.SessionID) {
+				match = i
+				break
+			}
+		}
+	}
+	if match < 0 {
+		r.divergences++
+		resp := map[string]any{
+			"id":    f.ID,
+			"error": map[string]any{"code": -32000, "message": "replay: no recorded call for " + f.Method},
+		}
+		if f.SessionID != "" {
+			resp["sessionId"] = f.SessionID
+		}
+		out, _ := json.Marshal(resp)
+		return [][]byte{out}
+	}
+	r.used[match] = true
+	r.idMap[r.sends[match].ID] = f.ID
+
+	var out [][]byte
+	// 按录制顺序推进：遇到尚未匹配命令的响应或事件时停止
+	for ; r.cursor < len(r.recvs); r.cursor++ {
+		rv := r.recvs[r.cursor]
+		if r.emitted[r.cursor] {
+			continue
+		}
+		if rv.frame.ID != 0 {
+			if _, ok := r.idMap[rv.frame.ID]; !ok {
+				break
+			}
+		} else if rv.owner >= 0 && !r.used[rv.owner] {
+			break
+		}
+		out = append(out, r.rewrite(rv))
+		r.emitted[r.cursor] = true
+	}
+	// 当前命令的响应若被阻塞在后面，提前发送，避免客户端等待
+	for i := r.cursor; i < len(r.recvs); i++ {
+		if !r.emitted[i] && r.recvs[i].frame.ID == r.sends[match].ID && r.recvs[i].frame.Method == "" {
+			out = append(out, r.rewrite(r.recvs[i]))
+			r.emitted[i] = true
+			break
+		}
+	}
+	return out
+}
+
+// rewrite 把响应中的录制 id 替换为客户端 id；事件原样返回。
+func (r *cdpReplayer) rewrite(rv cdpReplayRecv) []byte {
+	if rv.frame.ID == 0 {
+		return rv.raw
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(rv.raw, &m); err != nil {
+		return rv.raw
+	}
+	m["id"] = json.RawMessage(strconv.FormatInt(r.idMap[rv.frame.ID], 10))
+	out, _ := json.Marshal(m)
+	return out
+}
+
+// serve 在回环地址上启动 websocket 服务器，返回关闭函数。
+func (r *cdpReplayer) serve() (func(), error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, _, _, err := ws.UpgradeHTTP(req, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			data, op, err := wsutil.ReadClientData(conn)
+			if err != nil {
+				return
+			}
+			if op != ws.OpText {
+				continue
+			}
+			for _, msg := range r.handle(data) {
+				if err := wsutil.WriteServerMessage(conn, ws.OpText, msg); err != nil {
+					return
+				}
+			}
+		}
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	r.wsURL = "ws://" + ln.Addr().String() + "/devtools/browser/replay"
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}, nil
+}
+
+func cdpReplayFrom(c *gin.Context) *cdpReplayer {
+	v, _ := c.Get(cdpReplayKey)
+	r, _ := v.(*cdpReplayer)
+	return r
+}
+
+// registerCDPRecordingRoutes 在配置 CDP_RECORDING_DIR 且开启 CDP_RECORDING_ROUTES 时注册（r 为经过 captureMiddlewares 的路由组）：
+// GET  /debug/cdp/:id         下载录制文件（NDJSON）
+// POST /debug/cdp/:id/replay  按录制的请求重新执行截图流程，浏览器由录制回放代替；X-CDP-Replay-Divergences 为未能匹配的命令数
+func registerCDPRecordingRoutes(r gin.IRoutes) {
+	if cdpRecordingDir() == "" || !cdpRecordingRoutesEnabled {
+		return
+	}
+	r.GET(cdpRecordingRoute+"/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		if !cdpRecordingIDPattern.MatchString(id) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+			return
+		}
+		path := filepath.Join(cdpRecordingDir(), id+cdpRecordingExt)
+		if _, err := os.Stat(path); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+			return
+		}
+		c.Header("Content-Type", "application/x-ndjson")
+		c.File(path)
+	})
+	r.POST(cdpRecordingRoute+"/:id/replay", func(c *gin.Context) {
+		header, msgs, err := loadCDPRecording(c.Param("id"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recording", "details": err.Error()})
+			return
+		}
+		replay := newCDPReplayer(msgs)
+		stop, err := replay.serve()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start replay server", "details": err.Error()})
+			return
+		}
+		defer stop()
+
+		// 回放不上传、不使用预热会话；其余参数与录制时一致
+		req := header.Request
+		req.Store, req.Storage, req.SessionID = false, "", ""
+		c.Set(cdpReplayKey, replay)
+		c.Header("X-CDP-Replay-Of", header.ID)
+		w := &replayDivergenceWriter{ResponseWriter: c.Writer, replay: replay}
+		c.Writer = w
+		captureScreenshot(c, req, "", 0, "")
+	})
+}
+
+// replayDivergenceWriter 在写出响应头时附加 X-CDP-Replay-Divergences。
+type replayDivergenceWriter struct {
+	gin.ResponseWriter
+	replay *cdpReplayer
+	once   sync.Once
+}
+
+func (w *replayDivergenceWriter) setHeader() {
+	w.once.Do(func() {
+		w.replay.mu.Lock()
+		n := w.replay.divergences
+		w.replay.mu.Unlock()
+		w.Header().Set("X-CDP-Replay-Divergences", strconv.Itoa(n))
+	})
+}
+
+func (w *replayDivergenceWriter) WriteHeader(code int) {
+	w.setHeader()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *replayDivergenceWriter) WriteHeaderNow() {
+	w.setHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *replayDivergenceWriter) Write(b []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *replayDivergenceWriter) WriteString(s string) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.WriteString(s)
+}
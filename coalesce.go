@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
+)
+
+// captureGroup 合并并发的相同截图请求：同一指纹同时只执行一次渲染，其余请求共享结果。
+// COALESCE_REQUESTS=false 时为 nil（不合并）。
+var captureGroup = func() *singleflight.Group {
+	if v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("COALESCE_REQUESTS"))); err == nil && !v {
+		return nil
+	}
+	return &singleflight.Group{}
+}()
+
+// coalesceWindow 为渲染完成后结果仍可被相同（或仅在可忽略参数上不同的）请求复用的时间（COALESCE_WINDOW，默认 0：
+// 只合并同时进行中的请求）。只复用 2xx 响应。
+var coalesceWindow = envDuration("COALESCE_WINDOW", 0)
+
+// coalesceIgnoreParams 为计算合并键时从目标 URL 中去掉的查询参数（COALESCE_IGNORE_PARAMS，逗号分隔，支持 * 通配），
+// 用于忽略 cb、_、utm_* 等防缓存或统计参数。
+var coalesceIgnoreParams = func() []string {
+	var out []string
+	for _, p := range strings.Split(os.Getenv("COALESCE_IGNORE_PARAMS"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}()
+
+// coalesceQualityStep 为 jpeg/webp 质量的合并粒度（COALESCE_QUALITY_STEP，默认 1：质量必须相同）；
+// 例如 10 时质量 80-89 的请求共享同一次渲染。
+var coalesceQualityStep = func() int {
+	n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("COALESCE_QUALITY_STEP")))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return min(n, 100)
+}()
+
+// recentCaptures 保存 coalesceWindow 内完成的渲染结果。
+var recentCaptures = struct {
+	sync.Mutex
+	entries map[string]recentCapture
+}{entries: map[string]recentCapture{}}
+
+type recentCapture struct {
+	resp    *recordedResponse
+	expires time.Time
+}
+
+// coalesceKey 为合并键：在请求指纹的基础上去掉可忽略的 URL 查询参数，并按 coalesceQualityStep 对质量取整。
+// 合并的请求收到的是首个请求的渲染结果（其 URL 与质量）。
+func coalesceKey(req ScreenshotRequest) string {
+	if len(coalesceIgnoreParams) > 0 && req.URL != "" {
+		if u, err := url.Parse(req.URL); err == nil && u.RawQuery != "" {
+			q := u.Query()
+			for name := range q {
+				for _, p := range coalesceIgnoreParams {
+					if wildcardMatch(p, name) {
+						q.Del(name)
+						break
+					}
+				}
+			}
+			u.RawQuery = q.Encode()
+			req.URL = u.String()
+		}
+	}
+	if req.Format == "jpeg" || req.Format == "webp" {
+		req.Quality -= req.Quality % coalesceQualityStep
+	}
+	return requestFingerprint(&req)
+}
+
+// requestFingerprint 为规范化请求（应用默认值、站点配置与校验后的参数 JSON）的 SHA-256。
+func requestFingerprint(req *ScreenshotRequest) string {
+	b, _ := json.Marshal(req)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordedResponse 为一次渲染的完整响应，供合并的请求重放。
+type recordedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// coalesceCapture 以 singleflight 执行 run：首个请求在独立的 recorder 中渲染，同时到达的相同请求等待并重放其响应
+// （X-Coalesced: true）；配置 COALESCE_WINDOW 时，渲染完成后窗口内到达的请求同样复用。
+// If-None-Match 按各自的请求判断，渲染本身不带条件头。
+func coalesceCapture(c *gin.Context, key string, run func(ic *gin.Context)) {
+	resp := lookupRecentCapture(key)
+	reused := resp != nil
+	if resp == nil {
+		leader := false
+		v, _, shared := captureGroup.Do(key, func() (interface{}, error) {
+			leader = true
+			rec := httptest.NewRecorder()
+			ic, _ := gin.CreateTestContext(rec)
+			ic.Request = c.Request.Clone(c.Request.Context())
+			ic.Request.Header.Del("If-None-Match")
+			run(ic)
+			resp := &recordedResponse{status: rec.Code, header: rec.Header().Clone(), body: rec.Body.Bytes()}
+			rememberCapture(key, resp)
+			return resp, nil
+		})
+		resp, reused = v.(*recordedResponse), shared && !leader
+	}
+
+	for k, vs := range resp.header {
+		c.Writer.Header()[k] = vs
+	}
+	if reused {
+		c.Header("X-Coalesced", "true")
+	}
+	if etag := resp.header.Get("ETag"); resp.status == http.StatusOK && etag != "" {
+		if inm := c.GetHeader("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	c.Status(resp.status)
+	_, _ = c.Writer.Write(resp.body)
+}
+
+// rememberCapture 在 coalesceWindow 内保存成功的渲染结果，并顺带清理过期条目。
+func rememberCapture(key string, resp *recordedResponse) {
+	if coalesceWindow <= 0 || resp.status/100 != 2 {
+		return
+	}
+	now := time.Now()
+	recentCaptures.Lock()
+	defer recentCaptures.Unlock()
+	for k, e := range recentCaptures.entries {
+		if now.After(e.expires) {
+			delete(recentCaptures.entries, k)
+		}
+	}
+	recentCaptures.entries[key] = recentCapture{resp: resp, expires: now.Add(coalesceWindow)}
+}
+
+func lookupRecentCapture(key string) *recordedResponse {
+	if coalesceWindow <= 0 {
+		return nil
+	}
+	recentCaptures.Lock()
+	defer recentCaptures.Unlock()
+	e, ok := recentCaptures.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil
+	}
+	return e.resp
+}
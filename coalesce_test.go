@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestCoalesceKey(t *testing.T) {
+	defer func(params []string, step int) { coalesceIgnoreParams, coalesceQualityStep = params, step }(coalesceIgnoreParams, coalesceQualityStep)
+	coalesceIgnoreParams, coalesceQualityStep = []string{"cb", "utm_*"}, 10
+
+	base := ScreenshotRequest{URL: "https://example.com/?page=1", Format: "jpeg", Quality: 80}
+	same := func(mod func(r *ScreenshotRequest)) bool {
+		r := base
+		mod(&r)
+		return coalesceKey(r) == coalesceKey(base)
+	}
+	tests := []struct {
+		name string
+		mod  func(r *ScreenshotRequest)
+		want bool
+	}{
+		{"identical", func(r *ScreenshotRequest) {}, true},
+		{"ignored params", func(r *ScreenshotRequest) { r.URL = "https://example.com/?cb=123&page=1&utm_source=x" }, true},
+		{"param order", func(r *ScreenshotRequest) { r.URL = "https://example.com/?utm_medium=y&page=1" }, true},
+		{"other param", func(r *ScreenshotRequest) { r.URL = "https://example.com/?page=2" }, false},
+		{"quality in step", func(r *ScreenshotRequest) { r.Quality = 89 }, true},
+		{"quality out of step", func(r *ScreenshotRequest) { r.Quality = 90 }, false},
+		{"png ignores step", func(r *ScreenshotRequest) { r.Format = "png" }, false},
+		{"width", func(r *ScreenshotRequest) { r.Width = 800 }, false},
+	}
+	for _, tt := range tests {
+		if got := same(tt.mod); got != tt.want {
+			t.Errorf("%s: same key = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// png 的质量不参与取整
+	a, b := base, base
+	a.Format, b.Format = "png", "png"
+	a.Quality, b.Quality = 81, 82
+	if coalesceKey(a) == coalesceKey(b) {
+		t.Error("png requests with different quality share a key")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strings"
+)
+
+const (
+	colorProfileSRGB  = "srgb"
+	colorProfileStrip = "strip"
+	colorProfileKeep  = "keep"
+)
+
+// colorProfileMode 为输出图片的色彩配置处理方式（COLOR_PROFILE）：
+//   - srgb（默认）：移除内嵌 ICC 与色彩相关的块，PNG 标记为 sRGB；JPEG / WebP 移除 ICC 后按惯例视为 sRGB；
+//   - strip：只移除内嵌 ICC 与色彩相关的块，不添加标记；
+//   - keep：保留上游 Chrome 的原始输出。
+//
+// 只改写容器元数据，不做像素级色彩转换；像素本身在 sRGB 中渲染需要上游以 --force-color-profile=srgb 启动。
+var colorProfileMode = colorProfileSRGB
+
+func loadColorProfile() error {
+	v := strings.ToLower(strings.TrimSpace(os.Getenv("COLOR_PROFILE")))
+	switch v {
+	case "":
+		colorProfileMode = colorProfileSRGB
+	case colorProfileSRGB, colorProfileStrip, colorProfileKeep:
+		colorProfileMode = v
+	default:
+		return fmt.Errorf("COLOR_PROFILE must be one of: srgb, strip, keep")
+	}
+	return nil
+}
+
+// normalizeColorProfile 按 COLOR_PROFILE 处理 PNG / JPEG / WebP 的色彩元数据；无法识别或解析失败时原样返回。
+func normalizeColorProfile(img []byte) []byte {
+	if colorProfileMode == colorProfileKeep {
+		return img
+	}
+	var out []byte
+	var ok bool
+	switch {
+	case bytes.HasPrefix(img, pngSignature):
+		out, ok = pngNormalizeColor(img, colorProfileMode == colorProfileSRGB)
+	case bytes.HasPrefix(img, []byte{0xFF, 0xD8}):
+		out, ok = jpegStripICC(img)
+	case len(img) >= 12 && string(img[0:4]) == "RIFF" && string(img[8:12]) == "WEBP":
+		out, ok = webpStripICC(img)
+	}
+	if !ok {
+		return img
+	}
+	return out
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngColorChunks 为 PNG 中描述色彩空间的块。
+var pngColorChunks = map[string]bool{"iCCP": true, "sRGB": true, "gAMA": true, "cHRM": true, "cICP": true}
+
+// pngNormalizeColor 移除色彩相关的块；tagSRGB 时在 IHDR 之后写入 sRGB 块（渲染意图：perceptual）。
+func pngNormalizeColor(img []byte, tagSRGB bool) ([]byte, bool) {
+	out := make([]byte, 0, len(img)+13)
+	out = append(out, pngSignature...)
+	pos := len(pngSignature)
+	for pos+12 <= len(img) {
+		length := int(binary.BigEndian.Uint32(img[pos : pos+4]))
+		end := pos + 12 + length
+		if length < 0 || end > len(img) {
+			return nil, false
+		}
+		typ := string(img[pos+4 : pos+8])
+		if !pngColorChunks[typ] {
+			out = append(out, img[pos:end]...)
+		}
+		if typ == "IHDR" && tagSRGB {
+			out = appendPNGChunk(out, "sRGB", []byte{0})
+		}
+		pos = end
+		if typ == "IEND" {
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+func appendPNGChunk(dst []byte, typ string, data []byte) []byte {
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(data)))
+	copy(hdr[4:], typ)
+	crc := crc32.NewIEEE()
+	crc.Write(hdr[4:])
+	crc.Write(data)
+	dst = append(dst, hdr[:]...)
+	dst = append(dst, data...)
+	return binary.BigEndian.AppendUint32(dst, crc.Sum32())
+}
+
+// jpegStripICC 移除 APP2 ICC_PROFILE 段（未标记的 JPEG 按惯例视为 sRGB）。
+func jpegStripICC(img []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(img))
+	out = append(out, img[:2]...)
+	pos := 2
+	for pos+4 <= len(img) {
+		if img[pos] != 0xFF {
+			return nil, false
+		}
+		marker := img[pos+1]
+		// SOS 之后为熵编码数据，原样保留
+		if marker == 0xDA {
+			return append(out, img[pos:]...), true
+		}
+		length := int(binary.BigEndian.Uint16(img[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(img) {
+			return nil, false
+		}
+		if !(marker == 0xE2 && bytes.HasPrefix(img[pos+4:end], []byte("ICC_PROFILE\x00"))) {
+			out = append(out, img[pos:end]...)
+		}
+		pos = end
+	}
+	return nil, false
+}
+
+// webpStripICC 移除扩展格式（VP8X）中的 ICCP 块并清除 ICC 标志位。
+func webpStripICC(img []byte) ([]byte, bool) {
+	if len(img) < 30 || string(img[12:16]) != "VP8X" || img[20]&0x20 == 0 {
+		return img, true
+	}
+	out := make([]byte, 0, len(img))
+	out = append(out, img[:12]...)
+	pos := 12
+	for pos+8 <= len(img) {
+		size := int(binary.LittleEndian.Uint32(img[pos+4 : pos+8]))
+		end := pos + 8 + size + size%2
+		if size < 0 || end > len(img) {
+			return nil, false
+		}
+		if string(img[pos:pos+4]) != "ICCP" {
+			out = append(out, img[pos:end]...)
+		}
+		pos = end
+	}
+	out[20] &^= 0x20
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, true
+}
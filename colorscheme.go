@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	colorSchemeDark  = "dark"
+	colorSchemeLight = "light"
+)
+
+// validateColorScheme 校验 color_scheme：为空（不模拟）、dark 或 light。
+func (r *ScreenshotRequest) validateColorScheme() error {
+	switch r.ColorScheme {
+	case "", colorSchemeDark, colorSchemeLight:
+		return nil
+	}
+	return errors.New("color_scheme must be dark or light")
+}
+
+// emulatedMediaAction 合并 print 媒体类型与 prefers-color-scheme 为一次 Emulation.setEmulatedMedia
+// （分开调用时后一次会覆盖前一次）；两者都不需要时返回 nil。
+func emulatedMediaAction(req *ScreenshotRequest) chromedp.Action {
+	if req.Mode != modePrint && req.ColorScheme == "" {
+		return nil
+	}
+	p := emulation.SetEmulatedMedia()
+	if req.Mode == modePrint {
+		p = p.WithMedia("print")
+	}
+	if req.ColorScheme != "" {
+		p = p.WithFeatures([]*emulation.MediaFeature{{Name: "prefers-color-scheme", Value: req.ColorScheme}})
+	}
+	return p
+}
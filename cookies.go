@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+const maxCookies = 100
+
+// Cookie 为导航前写入浏览器的 cookie（字段名与 Puppeteer / Playwright 一致）。
+// Domain 为空时按截图目标地址设置（host-only cookie）；Expires 为 Unix 秒，0 表示会话 cookie。
+type Cookie struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain"`
+	Path     string  `json:"path"`
+	Secure   bool    `json:"secure"`
+	HTTPOnly bool    `json:"httpOnly"`
+	Expires  float64 `json:"expires"`
+}
+
+// validateCookies 校验 cookies；未指定 domain 的 cookie 需要 url（html 渲染的文档为 about:blank，无法关联）。
+func (r *ScreenshotRequest) validateCookies() error {
+	if len(r.Cookies) > maxCookies {
+		return fmt.Errorf("cookies must contain at most %d entries", maxCookies)
+	}
+	for i := range r.Cookies {
+		ck := &r.Cookies[i]
+		ck.Name = strings.TrimSpace(ck.Name)
+		ck.Domain = strings.TrimSpace(ck.Domain)
+		if ck.Name == "" {
+			return fmt.Errorf("cookies[%d].name is required", i)
+		}
+		if strings.ContainsAny(ck.Name, "=; \t\r\n") || strings.ContainsAny(ck.Value, ";\r\n") {
+			return fmt.Errorf("cookies[%d] contains invalid characters", i)
+		}
+		if ck.Domain == "" && r.HTML != "" {
+			return fmt.Errorf("cookies[%d].domain is required with html", i)
+		}
+		if ck.Path != "" && !strings.HasPrefix(ck.Path, "/") {
+			return fmt.Errorf("cookies[%d].path must start with /", i)
+		}
+		if ck.Expires < 0 {
+			return fmt.Errorf("cookies[%d].expires must be a Unix timestamp in seconds", i)
+		}
+	}
+	return nil
+}
+
+// setCookiesAction 在导航前通过 Network.setCookies 写入 cookies。
+func setCookiesAction(req *ScreenshotRequest) chromedp.Action {
+	params := make([]*network.CookieParam, 0, len(req.Cookies))
+	for _, ck := range req.Cookies {
+		p := &network.CookieParam{
+			Name:     ck.Name,
+			Value:    ck.Value,
+			Domain:   ck.Domain,
+			Path:     ck.Path,
+			Secure:   ck.Secure,
+			HTTPOnly: ck.HTTPOnly,
+		}
+		if ck.Domain == "" {
+			p.URL = req.URL
+		}
+		if ck.Expires > 0 {
+			sec := int64(ck.Expires)
+			t := cdp.TimeSinceEpoch(time.Unix(sec, int64((ck.Expires-float64(sec))*1e9)))
+			p.Expires = &t
+		}
+		params = append(params, p)
+	}
+	return network.SetCookies(params)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/css"
+	"github.com/chromedp/cdproto/debugger"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/profiler"
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+// CoverageEntry 单个 JS/CSS 资源的使用情况。
+type CoverageEntry struct {
+	URL         string `json:"url"`
+	TotalBytes  int    `json:"total_bytes"`
+	UsedBytes   int    `json:"used_bytes"`
+	UnusedBytes int    `json:"unused_bytes"`
+}
+
+type coverageSummary struct {
+	JSTotalBytes  int `json:"js_total_bytes"`
+	JSUsedBytes   int `json:"js_used_bytes"`
+	CSSTotalBytes int `json:"css_total_bytes"`
+	CSSUsedBytes  int `json:"css_used_bytes"`
+}
+
+type coverageRange struct {
+	start, end int
+}
+
+// mergeCoverageRanges 合并重叠/相邻区间并返回覆盖的总字节数。
+func mergeCoverageRanges(ranges []coverageRange) int {
+	if len(ranges) == 0 {
+		return 0
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	total := 0
+	cur := ranges[0]
+	for _, r := range ranges[1:] {
+		if r.start <= cur.end {
+			if r.end > cur.end {
+				cur.end = r.end
+			}
+			continue
+		}
+		total += cur.end - cur.start
+		cur = r
+	}
+	total += cur.end - cur.start
+	return total
+}
+
+// jsUsedRanges 把 V8 block coverage（外层函数区间 + 内层 count=0 的块）转换为“实际执行过”的不相交区间。
+// 算法与 Puppeteer 的 convertToDisjointRanges 一致：按偏移扫描，栈顶 count>0 的片段视为已使用。
+func jsUsedRanges(fns []*profiler.FunctionCoverage) []coverageRange {
+	type point struct {
+		offset int
+		isEnd  bool
+		length int
+		count  int64
+	}
+	var points []point
+	for _, fn := range fns {
+		for _, r := range fn.Ranges {
+			l := int(r.EndOffset - r.StartOffset)
+			points = append(points,
+				point{offset: int(r.StartOffset), isEnd: false, length: l, count: r.Count},
+				point{offset: int(r.EndOffset), isEnd: true, length: l, count: r.Count},
+			)
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		a, b := points[i], points[j]
+		if a.offset != b.offset {
+			return a.offset < b.offset
+		}
+		// 同一偏移：先结束后开始；开始时长区间在前，结束时短区间在前
+		if a.isEnd != b.isEnd {
+			return a.isEnd
+		}
+		if !a.isEnd {
+			return a.length > b.length
+		}
+		return a.length < b.length
+	})
+
+	var stack []int64
+	var out []coverageRange
+	last := 0
+	for _, p := range points {
+		if len(stack) > 0 && last < p.offset && stack[len(stack)-1] > 0 {
+			if n := len(out); n > 0 && out[n-1].end == last {
+				out[n-1].end = p.offset
+			} else {
+				out = append(out, coverageRange{start: last, end: p.offset})
+			}
+		}
+		last = p.offset
+		if p.isEnd {
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		} else {
+			stack = append(stack, p.count)
+		}
+	}
+	return out
+}
+
+func coverageHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := parseRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.applyDefaults()
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setEffectiveRequestHeader(c, &req)
+
+		viewportWidth, viewportHeight := req.viewportSize()
+
+		overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+
+		sess := openChromeSession(c, overallCtx, "coverageHandler")
+		if sess == nil {
+			return
+		}
+		defer sess.cancel()
+
+		// styleSheetAdded 事件携带样式表来源 URL（内联样式为文档 URL）。
+		var mu sync.Mutex
+		sheetURLs := map[css.StyleSheetID]string{}
+		chromedp.ListenTarget(sess.ctx, func(ev interface{}) {
+			if e, ok := ev.(*css.EventStyleSheetAdded); ok && e.Header != nil {
+				mu.Lock()
+				sheetURLs[e.Header.StyleSheetID] = e.Header.SourceURL
+				mu.Unlock()
+			}
+		})
+
+		jsEntries, cssEntries := []CoverageEntry{}, []CoverageEntry{}
+		actions := []chromedp.Action{
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				if err := profiler.Enable().Do(ctx); err != nil {
+					return err
+				}
+				if _, err := debugger.Enable().Do(ctx); err != nil {
+					return err
+				}
+				_, err := profiler.StartPreciseCoverage().WithCallCount(false).WithDetailed(true).Do(ctx)
+				return err
+			}),
+			dom.Enable(),
+			css.Enable(),
+			css.StartRuleUsageTracking(),
+		}
+		budget := newCaptureBudget(time.Duration(req.Timeout)*time.Second, req.BestEffort)
+		actions = append(actions, navigationActions(&req, viewportWidth, viewportHeight, budget)...)
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			scripts, _, err := profiler.TakePreciseCoverage().Do(ctx)
+			if err != nil {
+				return err
+			}
+			for _, sc := range scripts {
+				// 与 DevTools Coverage 面板一致：忽略无 URL 的匿名脚本（eval 等）。
+				if sc.URL == "" {
+					continue
+				}
+				src, _, err := debugger.GetScriptSource(sc.ScriptID).Do(ctx)
+				if err != nil {
+					continue
+				}
+				used := mergeCoverageRanges(jsUsedRanges(sc.Functions))
+				jsEntries = append(jsEntries, CoverageEntry{URL: sc.URL, TotalBytes: len(src), UsedBytes: used, UnusedBytes: len(src) - used})
+			}
+
+			rules, err := css.StopRuleUsageTracking().Do(ctx)
+			if err != nil {
+				return err
+			}
+			usedBySheet := map[css.StyleSheetID][]coverageRange{}
+			for _, r := range rules {
+				if !r.Used {
+					if _, ok := usedBySheet[r.StyleSheetID]; !ok {
+						usedBySheet[r.StyleSheetID] = nil
+					}
+					continue
+				}
+				usedBySheet[r.StyleSheetID] = append(usedBySheet[r.StyleSheetID], coverageRange{start: int(r.StartOffset), end: int(r.EndOffset)})
+			}
+			mu.Lock()
+			for id := range sheetURLs {
+				if _, ok := usedBySheet[id]; !ok {
+					usedBySheet[id] = nil
+				}
+			}
+			mu.Unlock()
+			for id, ranges := range usedBySheet {
+				text, err := css.GetStyleSheetText(id).Do(ctx)
+				if err != nil {
+					continue
+				}
+				mu.Lock()
+				u := sheetURLs[id]
+				mu.Unlock()
+				used := mergeCoverageRanges(ranges)
+				cssEntries = append(cssEntries, CoverageEntry{URL: u, TotalBytes: len(text), UsedBytes: used, UnusedBytes: len(text) - used})
+			}
+			return nil
+		}))
+
+		if err := chromedp.Run(sess.ctx, actions...); err != nil {
+			respondRunError(c, err, sess.wsURL, "coverage timeout", "failed to collect coverage")
+			return
+		}
+
+		sort.Slice(jsEntries, func(i, j int) bool { return jsEntries[i].URL < jsEntries[j].URL })
+		sort.Slice(cssEntries, func(i, j int) bool { return cssEntries[i].URL < cssEntries[j].URL })
+		var sum coverageSummary
+		for _, e := range jsEntries {
+			sum.JSTotalBytes += e.TotalBytes
+			sum.JSUsedBytes += e.UsedBytes
+		}
+		for _, e := range cssEntries {
+			sum.CSSTotalBytes += e.TotalBytes
+			sum.CSSUsedBytes += e.UsedBytes
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"url":     req.URL,
+			"js":      jsEntries,
+			"css":     cssEntries,
+			"summary": sum,
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// deterministicInitScript 在页面任何脚本执行前注入：
+// - 固定 Date / Date.now（2024-01-01T00:00:00Z）
+// - 用固定种子的 LCG 替换 Math.random
+// - 记录 setInterval，便于截图前统一清除（轮播图/计时器）
+// - 禁止媒体自动播放
+// 截图前再调用 window.__screenshotFreeze() 冻结动画、定时器与媒体。
+const deterministicInitScript = `(() => {
+	const FIXED_NOW = 1704067200000;
+	const RealDate = Date;
+	function FakeDate(...args) {
+		if (!(this instanceof FakeDate)) return new RealDate(FIXED_NOW).toString();
+		return args.length ? new RealDate(...args) : new RealDate(FIXED_NOW);
+	}
+	FakeDate.prototype = RealDate.prototype;
+	FakeDate.now = () => FIXED_NOW;
+	FakeDate.parse = RealDate.parse;
+	FakeDate.UTC = RealDate.UTC;
+	window.Date = FakeDate;
+
+	let seed = 42;
+	Math.random = () => {
+		seed = (seed * 1664525 + 1013904223) % 4294967296;
+		return seed / 4294967296;
+	};
+
+	const intervals = new Set();
+	const realSetInterval = window.setInterval;
+	const realClearInterval = window.clearInterval;
+	window.setInterval = function (...args) {
+		const id = realSetInterval.apply(this, args);
+		intervals.add(id);
+		return id;
+	};
+	window.clearInterval = function (id) {
+		intervals.delete(id);
+		return realClearInterval.call(this, id);
+	};
+
+	HTMLMediaElement.prototype.play = function () {
+		return Promise.resolve();
+	};
+
+	Object.defineProperty(window, '__screenshotFreeze', {
+		configurable: true,
+		value: () => {
+			for (const id of intervals) realClearInterval(id);
+			intervals.clear();
+			window.setInterval = () => 0;
+			window.setTimeout = () => 0;
+			window.requestAnimationFrame = () => 0;
+			if (document.getAnimations) {
+				for (const a of document.getAnimations()) {
+					try { a.pause(); a.currentTime = 0; } catch (e) {}
+				}
+			}
+			for (const m of document.querySelectorAll('video, audio')) {
+				try { m.autoplay = false; m.pause(); m.currentTime = 0; } catch (e) {}
+			}
+			const s = document.createElement('style');
+			s.textContent = '*, *::before, *::after { animation-play-state: paused !important; transition: none !important; caret-color: transparent !important; }';
+			(document.head || document.documentElement).appendChild(s);
+		},
+	});
+})()`
+
+// deterministicInitAction 注册 deterministicInitScript，需在导航前执行。
+func deterministicInitAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		_, err := page.AddScriptToEvaluateOnNewDocument(deterministicInitScript).Do(ctx)
+		return err
+	})
+}
+
+// deterministicFreezeAction 在截图前冻结动画、定时器与媒体播放。
+func deterministicFreezeAction() chromedp.Action {
+	return chromedp.EvaluateAsDevTools(`window.__screenshotFreeze && window.__screenshotFreeze()`, nil)
+}
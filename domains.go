@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// domainPolicy 限制可渲染的目标主机：命中 BLOCKED_DOMAINS 的主机一律拒绝；配置了 ALLOWED_DOMAINS 时只允许命中其一的主机。
+type domainPolicy struct {
+	allowed []*regexp.Regexp
+	blocked []*regexp.Regexp
+}
+
+// hostPolicy 未配置 ALLOWED_DOMAINS 与 BLOCKED_DOMAINS 时为 nil（不限制）。
+var hostPolicy *domainPolicy
+
+// loadDomainPolicy 读取 ALLOWED_DOMAINS / BLOCKED_DOMAINS（逗号分隔）。每一项为 glob（"*" 匹配任意字符，
+// 如 example.com、*.example.com），或以 "/" 包裹的正则（如 /^cdn[0-9]+\.example\.com$/）；均不区分大小写，匹配完整主机名。
+func loadDomainPolicy() error {
+	allowed, err := parseDomainPatterns("ALLOWED_DOMAINS")
+	if err != nil {
+		return err
+	}
+	blocked, err := parseDomainPatterns("BLOCKED_DOMAINS")
+	if err != nil {
+		return err
+	}
+	if len(allowed) == 0 && len(blocked) == 0 {
+		return nil
+	}
+	hostPolicy = &domainPolicy{allowed: allowed, blocked: blocked}
+	return nil
+}
+
+func parseDomainPatterns(key string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, p := range strings.Split(os.Getenv(key), ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		var expr string
+		if len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			expr = "(?i)" + p[1:len(p)-1]
+		} else {
+			expr = "(?i)^" + strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(p)), `\*`, ".*") + "$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %w", key, p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func (p *domainPolicy) allows(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, re := range p.blocked {
+		if re.MatchString(host) {
+			return false
+		}
+	}
+	if len(p.allowed) == 0 {
+		return true
+	}
+	for _, re := range p.allowed {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkURL 校验 http/https URL 的主机名；其他 scheme（data:、file:、about: 等）不受主机名策略约束。
+func (p *domainPolicy) checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	if !p.allows(u.Hostname()) {
+		return fmt.Errorf("host %q is not allowed by the domain policy", u.Hostname())
+	}
+	return nil
+}
+
+// requestGuardEnabled 报告是否需要在每个 tab 上拦截请求：配置了域名策略，或开启了 ALLOW_FILE_URLS
+// （页面中的 file: 子资源、iframe 与跳转同样需要位于 FILE_URL_ALLOWED_DIRS 内，而不只是请求的 url）。
+func requestGuardEnabled() bool {
+	return hostPolicy != nil || allowFileURLs()
+}
+
+// requestGuardAction 在 tab 上拦截所有文档请求（主框架导航、重定向的每一跳与 iframe）以及全部 file: 请求，
+// 主机名不被域名策略允许（返回 403 TARGET_BLOCKED）或 file: 路径不在 FILE_URL_ALLOWED_DIRS 内时以
+// net::ERR_BLOCKED_BY_CLIENT 失败，使导航中途跳转到受限主机或页面引用其他本地文件也会被拒绝。
+func requestGuardAction() chromedp.Action {
+	patterns := []*fetch.RequestPattern{
+		{URLPattern: "*", ResourceType: network.ResourceTypeDocument, RequestStage: fetch.RequestStageRequest},
+	}
+	if allowFileURLs() {
+		patterns = append(patterns, &fetch.RequestPattern{URLPattern: "file:*", RequestStage: fetch.RequestStageRequest})
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		chromedp.ListenTarget(ctx, func(ev interface{}) {
+			if e, ok := ev.(*fetch.EventRequestPaused); ok {
+				// 事件回调中不能同步执行 CDP 命令
+				go handlePausedRequest(ctx, e)
+			}
+		})
+		return fetch.Enable().WithPatterns(patterns).Do(ctx)
+	})
+}
+
+// handlePausedRequest 处理被 Fetch 暂停的请求：依次应用 file: 路径限制、域名策略（仅文档请求）与 block_ads 过滤，
+// 放行时由 continuePausedRequest 应用 method=POST 的导航改写。
+func handlePausedRequest(ctx context.Context, e *fetch.EventRequestPaused) {
+	if urlScheme(e.Request.URL) == "file" {
+		if err := validateFileURL(e.Request.URL); err != nil {
+			log.Printf("file url policy: blocked %s: %v", e.Request.URL, err)
+			_ = fetch.FailRequest(e.RequestID, network.ErrorReasonBlockedByClient).Do(ctx)
+			return
+		}
+	}
+	if hostPolicy != nil && e.ResourceType == network.ResourceTypeDocument {
+		if err := hostPolicy.checkURL(e.Request.URL); err != nil {
+			log.Printf("domain policy: blocked %s", redactSensitiveURL(e.Request.URL))
+			_ = fetch.FailRequest(e.RequestID, network.ErrorReasonBlockedByClient).Do(ctx)
+			return
+		}
+	}
+	if blockAd(ctx, e) {
+		_ = fetch.FailRequest(e.RequestID, network.ErrorReasonBlockedByClient).Do(ctx)
+		return
+	}
+	_ = continuePausedRequest(ctx, e)
+}
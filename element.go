@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ElementInfo 描述 selector 命中的元素，便于调用方确认裁剪的是预期节点。
+type ElementInfo struct {
+	Tag        string            `json:"tag"`
+	Attributes map[string]string `json:"attributes"`
+	Box        Clip              `json:"box"`
+	Visible    bool              `json:"visible"`
+	Display    string            `json:"display"`
+	Visibility string            `json:"visibility"`
+	Opacity    string            `json:"opacity"`
+	InViewport bool              `json:"in_viewport"`
+	Matches    int               `json:"matches"`
+}
+
+// elementInfoAction 读取 selector 命中的第一个元素的元信息（box 为文档坐标）。
+func elementInfoAction(selector string, out *ElementInfo) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		js := fmt.Sprintf(`(() => {
+			const el = document.querySelector(%q);
+			if (!el) return null;
+			const r = el.getBoundingClientRect();
+			const cs = getComputedStyle(el);
+			const attrs = {};
+			// 属性值做长度保护，避免响应头过大
+			for (const a of el.attributes) attrs[a.name] = a.value.length > 256 ? a.value.slice(0, 256) + '…' : a.value;
+			return {
+				tag: el.tagName.toLowerCase(),
+				attributes: attrs,
+				box: { x: r.x + window.scrollX, y: r.y + window.scrollY, width: r.width, height: r.height },
+				visible: r.width > 0 && r.height > 0 && cs.display !== 'none' && cs.visibility !== 'hidden' && cs.opacity !== '0',
+				display: cs.display,
+				visibility: cs.visibility,
+				opacity: cs.opacity,
+				in_viewport: r.bottom > 0 && r.right > 0 && r.top < window.innerHeight && r.left < window.innerWidth,
+				matches: document.querySelectorAll(%q).length,
+			};
+		})()`, selector, selector)
+		var info *ElementInfo
+		if err := chromedp.EvaluateAsDevTools(js, &info).Do(ctx); err != nil {
+			return err
+		}
+		if info == nil {
+			return fmt.Errorf("selector not found: %s", selector)
+		}
+		*out = *info
+		return nil
+	})
+}
+
+// headerJSON 将 v 编码为只含 ASCII 的 JSON，可安全放入 HTTP 响应头。
+func headerJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, r := range string(b) {
+		if r < 0x80 {
+			sb.WriteRune(r)
+			continue
+		}
+		if r > 0xFFFF {
+			r -= 0x10000
+			fmt.Fprintf(&sb, `\u%04x\u%04x`, 0xD800+(r>>10), 0xDC00+(r&0x3FF))
+			continue
+		}
+		fmt.Fprintf(&sb, `\u%04x`, r)
+	}
+	return sb.String(), nil
+}
+
+// overlapInflatePx 检测遮挡时把目标元素的 bounding box 向外扩展的像素数，
+// 用于覆盖“贴边”的 sticky 栏/阴影等。
+const overlapInflatePx = 8
+
+// hideOverlappingAction 隐藏与 selector 目标元素（扩展后的）bounding box 相交的浮层元素
+// （position 为 fixed/sticky，或 absolute 且 z-index > 0，例如弹窗、toast、吸顶栏）。
+// 目标元素自身、其祖先与后代不会被隐藏。
+func hideOverlappingAction(selector string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		js := fmt.Sprintf(`(() => {
+			const target = document.querySelector(%q);
+			if (!target) return 0;
+			const m = %d;
+			const t = target.getBoundingClientRect();
+			const box = { left: t.left - m, top: t.top - m, right: t.right + m, bottom: t.bottom + m };
+			let hidden = 0;
+			for (const el of document.querySelectorAll('body *')) {
+				if (el === target || el.contains(target) || target.contains(el)) continue;
+				const cs = getComputedStyle(el);
+				const pos = cs.position;
+				const z = parseInt(cs.zIndex, 10);
+				if (!(pos === 'fixed' || pos === 'sticky' || (pos === 'absolute' && z > 0))) continue;
+				if (cs.display === 'none' || cs.visibility === 'hidden') continue;
+				const r = el.getBoundingClientRect();
+				if (r.width <= 0 || r.height <= 0) continue;
+				if (r.right <= box.left || r.left >= box.right || r.bottom <= box.top || r.top >= box.bottom) continue;
+				el.style.setProperty('visibility', 'hidden', 'important');
+				hidden++;
+			}
+			return hidden;
+		})()`, selector, overlapInflatePx)
+		return chromedp.EvaluateAsDevTools(js, nil).Do(ctx)
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	emojiNative  = "native"
+	emojiTwemoji = "twemoji"
+
+	// twemojiMaxIcons 限制单页替换的不同 emoji 数，避免内联的 data URI 过大。
+	twemojiMaxIcons = 500
+)
+
+// twemojiDir 为本地 Twemoji 资源目录（TWEMOJI_DIR）：twemoji.min.js 与 svg/<codepoints>.svg。
+// 镜像构建时已下载固定版本（见 Dockerfile），渲染时不再访问第三方 CDN。
+var twemojiDir = strings.TrimSpace(os.Getenv("TWEMOJI_DIR"))
+
+// twemojiIconPattern 为 Twemoji 资源文件名（如 1f600、1f468-200d-1f4bb），同时防止路径穿越。
+var twemojiIconPattern = regexp.MustCompile(`^[0-9a-f]+(-[0-9a-f]+)*$`)
+
+var errTwemojiUnavailable = errors.New("emoji=twemoji requires TWEMOJI_DIR")
+
+func isValidEmojiMode(v string) bool {
+	return v == "" || v == emojiNative || v == emojiTwemoji
+}
+
+// loadTwemojiScript 读取本地 Twemoji 脚本（只读一次），随后通过 CDP 直接执行，不受目标页面 CSP 的 script-src 限制。
+var loadTwemojiScript = sync.OnceValues(func() (string, error) {
+	if twemojiDir == "" {
+		return "", errTwemojiUnavailable
+	}
+	b, err := os.ReadFile(filepath.Join(twemojiDir, "twemoji.min.js"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read twemoji script: %w", err)
+	}
+	return string(b), nil
+})
+
+// twemojiDataURIs 为页面中出现的 emoji 读取本地 SVG 并转为 data URI；没有对应资源的 emoji 保持原样。
+func twemojiDataURIs(icons []string) map[string]string {
+	out := make(map[string]string, len(icons))
+	for _, icon := range icons {
+		if len(out) >= twemojiMaxIcons || !twemojiIconPattern.MatchString(icon) {
+			continue
+		}
+		svg, err := os.ReadFile(filepath.Join(twemojiDir, "svg", icon+".svg"))
+		if err != nil {
+			continue
+		}
+		out[icon] = "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(svg)
+	}
+	return out
+}
+
+// twemojiAction 将页面中的原生 emoji 替换为 Twemoji SVG：先收集页面用到的 emoji，再以 data URI 内联对应图片，
+// 上游 Chrome 无需访问 CDN 或本服务；最后等待图片解码完成（最多 3s）。
+func twemojiAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		src, err := loadTwemojiScript()
+		if err != nil {
+			return err
+		}
+		if err := chromedp.Evaluate(src+"\n;void 0;", nil).Do(ctx); err != nil {
+			return err
+		}
+		var icons []string
+		if err := chromedp.Evaluate(`(() => {
+			if (!window.twemoji || !document.body) return [];
+			const icons = new Set();
+			twemoji.parse(document.body, { callback: (icon) => { icons.add(icon); return false; } });
+			return [...icons];
+		})()`, &icons).Do(ctx); err != nil {
+			return err
+		}
+		if len(icons) == 0 {
+			return nil
+		}
+		urls, err := json.Marshal(twemojiDataURIs(icons))
+		if err != nil {
+			return err
+		}
+		js := fmt.Sprintf(`(async (urls) => {
+			twemoji.parse(document.body, { callback: (icon) => urls[icon] || false, className: 'ss-emoji' });
+			const s = document.createElement('style');
+			s.textContent = 'img.ss-emoji { height: 1em; width: 1em; margin: 0 .05em 0 .1em; vertical-align: -0.1em; }';
+			(document.head || document.documentElement).appendChild(s);
+			const imgs = Array.from(document.querySelectorAll('img.ss-emoji'));
+			const loaded = Promise.all(imgs.map((i) => i.decode().catch(() => {})));
+			await Promise.race([loaded, new Promise((r) => setTimeout(r, 3000))]);
+			return true;
+		})(%s)`, urls)
+		return chromedp.Evaluate(js, nil, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}).Do(ctx)
+	})
+}
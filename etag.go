@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// imageETag 为图片内容的强 ETag（SHA-256 前 128 位）。
+func imageETag(body []byte) string {
+	return `"` + sha256Hex(body)[:32] + `"`
+}
+
+// etagMatches 判断 If-None-Match 是否包含 etag（"*" 或逗号分隔列表，按弱比较忽略 W/ 前缀）。
+func etagMatches(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// respondImage 返回图片并附带 ETag；客户端 If-None-Match 命中时返回 304（不含响应体）。
+func respondImage(c *gin.Context, contentType string, body []byte) {
+	etag := imageETag(body)
+	c.Header("ETag", etag)
+	if inm := c.GetHeader("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
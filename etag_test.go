@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`*`, true},
+		{`"x", "abc"`, true},
+		{`"x",W/"abc"`, true},
+		{`"abcd"`, false},
+		{`abc`, false},
+		{`"x", "y"`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+	if imageETag([]byte("a")) == imageETag([]byte("b")) {
+		t.Error("different bodies share an ETag")
+	}
+}
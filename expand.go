@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	maxExpandSelectors = 20
+	maxExpandElements  = 100
+	defaultExpandWait  = 300
+	maxExpandWait      = 10000
+)
+
+// validateExpand 校验 expand_selectors（展开折叠内容）与 expand_wait（每个选择器点击后的稳定等待，毫秒）。
+func (r *ScreenshotRequest) validateExpand() error {
+	if len(r.ExpandSelectors) == 0 {
+		if r.ExpandWait != 0 {
+			return errors.New("expand_wait requires expand_selectors")
+		}
+		return nil
+	}
+	if len(r.ExpandSelectors) > maxExpandSelectors {
+		return fmt.Errorf("expand_selectors must contain at most %d selectors", maxExpandSelectors)
+	}
+	for i, sel := range r.ExpandSelectors {
+		r.ExpandSelectors[i] = strings.TrimSpace(sel)
+		if r.ExpandSelectors[i] == "" {
+			return fmt.Errorf("expand_selectors[%d] is empty", i)
+		}
+	}
+	if r.ExpandWait == 0 {
+		r.ExpandWait = defaultExpandWait
+	}
+	if r.ExpandWait < 0 || r.ExpandWait > maxExpandWait {
+		return fmt.Errorf("expand_wait must be between 0 and %d", maxExpandWait)
+	}
+	return nil
+}
+
+// expandJS 展开选择器命中的元素（最多 100 个）：<details> 直接设置 open，已展开（aria-expanded="true"）的跳过，
+// 其余依次点击。返回实际展开的数量；选择器无效时抛出异常。
+const expandJS = `((sel, max) => {
+  const els = Array.from(document.querySelectorAll(sel)).slice(0, max);
+  let n = 0;
+  for (const el of els) {
+    if (el.tagName === 'DETAILS') {
+      if (!el.open) { el.open = true; n++; }
+      continue;
+    }
+    if (el.tagName === 'SUMMARY' && el.parentElement && el.parentElement.tagName === 'DETAILS') {
+      if (!el.parentElement.open) { el.parentElement.open = true; n++; }
+      continue;
+    }
+    if (el.getAttribute('aria-expanded') === 'true') continue;
+    el.click();
+    n++;
+  }
+  return n;
+})(%s, %d)`
+
+// expandAction 依次处理 expand_selectors：展开命中的元素后等待 expand_wait 毫秒，让动画与懒加载内容稳定。
+// 选择器没有命中任何元素不视为错误。
+func expandAction(req *ScreenshotRequest) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		wait := time.Duration(req.ExpandWait) * time.Millisecond
+		for _, sel := range req.ExpandSelectors {
+			quoted, _ := json.Marshal(sel)
+			var n int
+			if err := chromedp.Evaluate(fmt.Sprintf(expandJS, quoted, maxExpandElements), &n).Do(ctx); err != nil {
+				return fmt.Errorf("expand_selectors %q: %w", sel, err)
+			}
+			if n == 0 {
+				continue
+			}
+			if err := chromedp.Sleep(wait).Do(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	faultHeader             = "X-Fault-Inject"
+	defaultFaultNavError    = "ERR_CONNECTION_REFUSED"
+	defaultFaultSlowCapture = 5 * time.Second
+)
+
+// faultInjectionEnabled 读取 FAULT_INJECTION；仅用于测试环境，为 true 时才解析 X-Fault-Inject 请求头。
+var faultInjectionEnabled = func() bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("FAULT_INJECTION")))
+	if err == nil && v {
+		log.Printf("WARNING: FAULT_INJECTION is enabled, requests may force failures via the %s header", faultHeader)
+	}
+	return err == nil && v
+}()
+
+// faultSpec 为单个请求要求注入的故障（X-Fault-Inject，逗号分隔，可组合）：
+//
+//	dial_timeout / dial_error      连接上游前失败（不会真正连接 browserless，也不计入熔断）
+//	nav_error[=ERR_XXX]            导航失败，返回对应的 net::ERR_*（默认 ERR_CONNECTION_REFUSED）
+//	slow_capture[=毫秒]            导航前额外等待（默认 5000），用于触发超时/预算逻辑
+//	capture_error                  页面加载完成后失败（500）
+type faultSpec struct {
+	dial         string
+	navError     string
+	slowCapture  time.Duration
+	captureError bool
+}
+
+type faultContextKey struct{}
+
+// injectedFault 解析请求的 X-Fault-Inject；未启用 FAULT_INJECTION 或未带该头时返回 nil。
+func injectedFault(c *gin.Context) (*faultSpec, error) {
+	raw := strings.TrimSpace(c.GetHeader(faultHeader))
+	if !faultInjectionEnabled || raw == "" {
+		return nil, nil
+	}
+	f := &faultSpec{}
+	for _, item := range strings.Split(raw, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+		switch strings.ToLower(name) {
+		case "dial_timeout", "dial_error":
+			f.dial = strings.ToLower(name)
+		case "nav_error":
+			f.navError = defaultFaultNavError
+			if value != "" {
+				f.navError = strings.ToUpper(strings.TrimPrefix(value, "net::"))
+			}
+		case "slow_capture":
+			f.slowCapture = defaultFaultSlowCapture
+			if value != "" {
+				ms, err := strconv.Atoi(value)
+				if err != nil || ms < 0 {
+					return nil, errors.New("slow_capture must be a non-negative number of milliseconds")
+				}
+				f.slowCapture = time.Duration(ms) * time.Millisecond
+			}
+		case "capture_error":
+			f.captureError = true
+		default:
+			return nil, fmt.Errorf("unknown fault %q", name)
+		}
+	}
+	return f, nil
+}
+
+// dialFault 模拟连接上游失败：dial_timeout 先等待连接超时时长（受请求 timeout 约束）再失败。
+func (f *faultSpec) dialFault(ctx context.Context) error {
+	if f.dial == "dial_error" {
+		return errors.New("injected fault: websocket dial failed: connection refused")
+	}
+	select {
+	case <-time.After(remoteChromeDialTimeout):
+	case <-ctx.Done():
+	}
+	return fmt.Errorf("injected fault: chrome dial: %w", context.DeadlineExceeded)
+}
+
+// attach 把故障挂到会话 ctx 上，由 navigationActions 中的动作读取。
+func (f *faultSpec) attach(sess *chromeSession) *chromeSession {
+	if f == nil || sess == nil {
+		return sess
+	}
+	sess.ctx = context.WithValue(sess.ctx, faultContextKey{}, f)
+	return sess
+}
+
+// faultBeforeNavigateAction 在导航前注入 slow_capture 与 nav_error。
+func faultBeforeNavigateAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		f, _ := ctx.Value(faultContextKey{}).(*faultSpec)
+		if f == nil {
+			return nil
+		}
+		if f.slowCapture > 0 {
+			if err := chromedp.Sleep(f.slowCapture).Do(ctx); err != nil {
+				return err
+			}
+		}
+		if f.navError != "" {
+			return fmt.Errorf("injected fault: page load error net::%s", f.navError)
+		}
+		return nil
+	})
+}
+
+// faultAfterLoadAction 在页面加载与等待完成后注入 capture_error。
+func faultAfterLoadAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if f, _ := ctx.Value(faultContextKey{}).(*faultSpec); f != nil && f.captureError {
+			return errors.New("injected fault: capture failed")
+		}
+		return nil
+	})
+}
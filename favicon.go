@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+// errNoFavicon 表示页面没有可用的图标（全部候选拉取失败）。
+type errNoFavicon struct {
+	cause error
+}
+
+func (e *errNoFavicon) Error() string {
+	return fmt.Sprintf("no usable favicon: %v", e.cause)
+}
+
+// rasterizeIconAction 在当前 tab 中以 <img> 渲染图标（Chrome 负责解码 SVG/ICO/PNG 等格式），
+// 缩放为 size x size（保持比例居中）并以透明背景 PNG 截图。
+func rasterizeIconAction(contentType string, data []byte, size int, out *[]byte) chromedp.Action {
+	html := fmt.Sprintf(`<!doctype html><html><head><style>html,body{margin:0;padding:0;background:transparent}`+
+		`img{display:block;width:%dpx;height:%dpx;object-fit:contain}</style></head>`+
+		`<body><img id="icon" src="data:%s;base64,%s"></body></html>`,
+		size, size, contentType, base64.StdEncoding.EncodeToString(data))
+	var decoded bool
+	return chromedp.Tasks{
+		emulation.SetDeviceMetricsOverride(int64(size), int64(size), 1, false),
+		emulation.SetDefaultBackgroundColorOverride().WithColor(&cdp.RGBA{R: 0, G: 0, B: 0, A: 0}),
+		setDocumentContentAction(html),
+		chromedp.Evaluate(`document.getElementById('icon').decode().then(() => true, () => false)`, &decoded,
+			func(p *runtime.EvaluateParams) *runtime.EvaluateParams { return p.WithAwaitPromise(true) }),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			if !decoded {
+				return errors.New("failed to decode favicon image")
+			}
+			buf, err := page.CaptureScreenshot().WithFormat(page.CaptureScreenshotFormatPng).
+				WithClip(&page.Viewport{X: 0, Y: 0, Width: float64(size), Height: float64(size), Scale: 1}).Do(ctx)
+			if err != nil {
+				return err
+			}
+			*out = buf
+			return nil
+		}),
+	}
+}
+
+func faviconHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := parseRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.applyDefaults()
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// size 为 0 时直接返回原始图标，不做栅格化/缩放
+		if req.IconSize != 0 && (req.IconSize < 16 || req.IconSize > 512) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size must be between 16 and 512"})
+			return
+		}
+		setEffectiveRequestHeader(c, &req)
+
+		viewportWidth, viewportHeight := req.viewportSize()
+
+		overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+
+		sess := openChromeSession(c, overallCtx, "faviconHandler")
+		if sess == nil {
+			return
+		}
+		defer sess.cancel()
+
+		var meta PageMetadata
+		var icon *FaviconData
+		var iconBytes, out []byte
+		budget := newCaptureBudget(time.Duration(req.Timeout)*time.Second, req.BestEffort)
+		actions := navigationActions(&req, viewportWidth, viewportHeight, budget)
+		actions = append(actions,
+			chromedp.EvaluateAsDevTools(pageMetadataJS, &meta),
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				data, body, err := bestFavicon(ctx, &req, meta.Favicons, req.IconSize)
+				if err != nil {
+					return &errNoFavicon{cause: err}
+				}
+				icon, iconBytes = data, body
+				if req.IconSize == 0 {
+					return nil
+				}
+				return rasterizeIconAction(icon.ContentType, iconBytes, req.IconSize, &out).Do(ctx)
+			}),
+		)
+
+		if err := chromedp.Run(sess.ctx, actions...); err != nil {
+			var nf *errNoFavicon
+			if errors.As(err, &nf) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "favicon not found", "code": "FAVICON_NOT_FOUND", "details": nf.cause.Error()})
+				return
+			}
+			respondRunError(c, err, sess.wsURL, "favicon timeout", "failed to capture favicon")
+			return
+		}
+
+		c.Header("X-Favicon-URL", icon.URL)
+		if req.IconSize == 0 {
+			c.Data(http.StatusOK, icon.ContentType, iconBytes)
+			return
+		}
+		c.Data(http.StatusOK, "image/png", out)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// validateFilters 校验颜色滤镜参数：brightness / contrast 为倍数（1 为原图，0 表示不调整），sepia 为强度（0-1）。
+func (r *ScreenshotRequest) validateFilters() error {
+	if r.Brightness != 0 && (r.Brightness < 0.1 || r.Brightness > 3) {
+		return errors.New("brightness must be between 0.1 and 3")
+	}
+	if r.Contrast != 0 && (r.Contrast < 0.1 || r.Contrast > 3) {
+		return errors.New("contrast must be between 0.1 and 3")
+	}
+	if r.Sepia < 0 || r.Sepia > 1 {
+		return errors.New("sepia must be between 0 and 1")
+	}
+	if r.imageFilter() != "" && (r.PaginateHeight != 0 || r.Paginate != nil) {
+		return errors.New("grayscale, brightness, contrast and sepia cannot be combined with paginate_height or paginate")
+	}
+	return nil
+}
+
+// imageFilter 返回 canvas filter 字符串；未设置任何滤镜时为空。
+func (r *ScreenshotRequest) imageFilter() string {
+	var parts []string
+	if r.Grayscale {
+		parts = append(parts, "grayscale(1)")
+	}
+	if r.Sepia > 0 {
+		parts = append(parts, "sepia("+strconv.FormatFloat(r.Sepia, 'f', -1, 64)+")")
+	}
+	if r.Brightness != 0 && r.Brightness != 1 {
+		parts = append(parts, "brightness("+strconv.FormatFloat(r.Brightness, 'f', -1, 64)+")")
+	}
+	if r.Contrast != 0 && r.Contrast != 1 {
+		parts = append(parts, "contrast("+strconv.FormatFloat(r.Contrast, 'f', -1, 64)+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// filterImageJS 在页面中解码截图，按 canvas filter 重绘后以原格式重新编码（保留透明度）。
+const filterImageJS = `async (b64, type, quality, filter, maxPixels) => {
+	const bin = atob(b64);
+	const bytes = new Uint8Array(bin.length);
+	for (let i = 0; i < bin.length; i++) bytes[i] = bin.charCodeAt(i);
+	const bmp = await createImageBitmap(new Blob([bytes], { type }));
+	if (bmp.width * bmp.height > maxPixels) return { skipped: true };
+
+	const cv = new OffscreenCanvas(bmp.width, bmp.height);
+	const g = cv.getContext('2d');
+	g.filter = filter;
+	g.drawImage(bmp, 0, 0);
+	const blob = await cv.convertToBlob({ type, quality: quality / 100 });
+	const ob = new Uint8Array(await blob.arrayBuffer());
+	let s = '';
+	for (let i = 0; i < ob.length; i += 0x8000) s += String.fromCharCode.apply(null, ob.subarray(i, i + 0x8000));
+	return { data: btoa(s) };
+}`
+
+// filterImageAction 对 *img 应用灰度与颜色滤镜（在当前 tab 中由 Chrome 解码/编码，支持 png/jpeg/webp），结果写回 *img；
+// 超过 maxTrimPixels 的图片不处理，通过 X-Filter-Skipped 标记。
+func filterImageAction(req *ScreenshotRequest, img *[]byte, skipped *bool) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if len(*img) == 0 {
+			return nil
+		}
+		args, err := json.Marshal([]interface{}{
+			base64.StdEncoding.EncodeToString(*img), contentTypeForFormat(req.Format),
+			req.Quality, req.imageFilter(), maxTrimPixels,
+		})
+		if err != nil {
+			return err
+		}
+		var out struct {
+			Skipped bool   `json:"skipped"`
+			Data    string `json:"data"`
+		}
+		expr := fmt.Sprintf("(%s)(...%s)", filterImageJS, args)
+		if err := chromedp.EvaluateAsDevTools(expr, &out, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}).Do(ctx); err != nil {
+			return fmt.Errorf("filter: %w", err)
+		}
+		*skipped = out.Skipped
+		if out.Data == "" {
+			return nil
+		}
+		buf, err := base64.StdEncoding.DecodeString(out.Data)
+		if err != nil {
+			return fmt.Errorf("filter: %w", err)
+		}
+		*img = buf
+		return nil
+	})
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+const (
+	engineChromium = "chromium"
+	engineFirefox  = "firefox"
+)
+
+// firefoxEndpoints 为 FIREFOX_BIDI_URL（逗号分隔）中空闲的 Firefox WebDriver BiDi 地址；未配置时为 nil。
+// Firefox 同一时间只允许一个 WebDriver 会话，因此每个地址同时只处理一个请求，取用即占用，用完放回。
+var firefoxEndpoints = func() chan string {
+	list := splitEndpointList(os.Getenv("FIREFOX_BIDI_URL"))
+	if len(list) == 0 {
+		return nil
+	}
+	ch := make(chan string, len(list))
+	for _, u := range list {
+		ch <- u
+	}
+	return ch
+}()
+
+// validateEngine 校验 engine：为空或 chromium（默认）、firefox。firefox 为实验性支持（FIREFOX_BIDI_URL 的 WebDriver BiDi，
+// 或 CAPTURE_BACKEND=playwright 时由 Playwright server 启动 Firefox），只实现了导航、视口与截图，
+// 使用其他参数时返回错误而不是静默忽略。
+func (r *ScreenshotRequest) validateEngine() error {
+	switch r.Engine {
+	case "", engineChromium:
+		return nil
+	case engineFirefox:
+	default:
+		return errors.New("engine must be chromium or firefox")
+	}
+	if firefoxEndpoints == nil && captureBackendName != backendPlaywright {
+		return errors.New("engine=firefox requires FIREFOX_BIDI_URL or CAPTURE_BACKEND=playwright")
+	}
+	// 域名策略依赖 CDP 拦截重定向，Firefox 下无法保证
+	if hostPolicy != nil {
+		return errors.New("engine=firefox is not available when ALLOWED_DOMAINS or BLOCKED_DOMAINS is configured")
+	}
+	// 同理无法限制页面引用的其他本地文件
+	if urlScheme(r.URL) == "file" {
+		return errors.New("engine=firefox does not support file: urls")
+	}
+	if !r.simpleCaptureOnly() {
+		return errors.New("engine=firefox supports only " + simpleCaptureParams)
+	}
+	return nil
+}
+
+// firefoxBackend 经 FIREFOX_BIDI_URL 的 WebDriver BiDi 在 Firefox 中截图。
+type firefoxBackend struct{}
+
+func (firefoxBackend) name() string   { return backendBiDi }
+func (firefoxBackend) engine() string { return engineFirefox }
+
+// acquire 等待空闲的 Firefox 地址（每个地址同时只处理一个请求）。
+func (firefoxBackend) acquire(ctx context.Context) (string, func(), error) {
+	select {
+	case wsURL := <-firefoxEndpoints:
+		return wsURL, func() { firefoxEndpoints <- wsURL }, nil
+	case <-ctx.Done():
+		return "", nil, context.Cause(ctx)
+	}
+}
+
+func (firefoxBackend) capture(ctx context.Context, wsURL string, req *ScreenshotRequest) ([]byte, bool, error) {
+	return firefoxCapture(ctx, wsURL, req)
+}
+
+// bidiClient 为 WebDriver BiDi 的最小客户端：命令按顺序同步执行，期间收到的事件直接丢弃。
+type bidiClient struct {
+	conn   net.Conn
+	rw     io.ReadWriter
+	nextID int64
+}
+
+// bidiError 为 BiDi 命令返回的错误（error 为错误码，如 unknown error / invalid argument）。
+type bidiError struct {
+	method  string
+	code    string
+	message string
+}
+
+func (e *bidiError) Error() string {
+	return fmt.Sprintf("firefox %s: %s: %s", e.method, e.code, e.message)
+}
+
+func dialBiDi(ctx context.Context, wsURL string) (*bidiClient, error) {
+	conn, br, _, err := ws.Dial(ctx, wsURL)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	var r io.Reader = conn
+	if br != nil {
+		r = io.MultiReader(br, conn)
+	}
+	return &bidiClient{conn: conn, rw: struct {
+		io.Reader
+		io.Writer
+	}{r, conn}}, nil
+}
+
+// call 发送命令并等待对应 id 的响应；result 为 nil 时忽略返回值。
+func (b *bidiClient) call(method string, params, result interface{}) error {
+	b.nextID++
+	id := b.nextID
+	msg, err := json.Marshal(map[string]interface{}{"id": id, "method": method, "params": params})
+	if err != nil {
+		return err
+	}
+	if err := wsutil.WriteClientText(b.rw, msg); err != nil {
+		return err
+	}
+	for {
+		data, err := wsutil.ReadServerText(b.rw)
+		if err != nil {
+			return err
+		}
+		var resp struct {
+			Type    string          `json:"type"`
+			ID      int64           `json:"id"`
+			Result  json.RawMessage `json:"result"`
+			Error   string          `json:"error"`
+			Message string          `json:"message"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return err
+		}
+		if resp.Type == "event" || resp.ID != id {
+			continue
+		}
+		if resp.Type == "error" {
+			return &bidiError{method: method, code: resp.Error, message: resp.Message}
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	}
+}
+
+// evaluateString 在 context 中求值返回字符串的表达式。
+func (b *bidiClient) evaluateString(browsingContext, expr string) (string, error) {
+	var res struct {
+		Type   string `json:"type"`
+		Result struct {
+			Value string `json:"value"`
+		} `json:"result"`
+		ExceptionDetails struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	err := b.call("script.evaluate", map[string]interface{}{
+		"expression":   expr,
+		"target":       map[string]string{"context": browsingContext},
+		"awaitPromise": false,
+	}, &res)
+	if err != nil {
+		return "", err
+	}
+	if res.Type == "exception" {
+		return "", fmt.Errorf("firefox script.evaluate: %s", res.ExceptionDetails.Text)
+	}
+	return res.Result.Value, nil
+}
+
+// firefoxCapture 在新的 BiDi 会话中打开 tab、设置视口、导航并截图；full_page 时按文档尺寸截取，超出像素预算时截断高度。
+func firefoxCapture(ctx context.Context, wsURL string, req *ScreenshotRequest) (img []byte, truncated bool, err error) {
+	b, err := dialBiDi(ctx, wsURL)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", errBackendDial, err)
+	}
+	defer b.conn.Close()
+	// 超时后关闭连接，使阻塞中的读写立即返回
+	stop := context.AfterFunc(ctx, func() { b.conn.Close() })
+	defer stop()
+
+	if err := b.call("session.new", map[string]interface{}{"capabilities": map[string]interface{}{}}, nil); err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = b.call("session.end", map[string]interface{}{}, nil) }()
+
+	var created struct {
+		Context string `json:"context"`
+	}
+	if err := b.call("browsingContext.create", map[string]string{"type": "tab"}, &created); err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = b.call("browsingContext.close", map[string]string{"context": created.Context}, nil) }()
+
+	w, h := req.viewportSize()
+	if err := b.call("browsingContext.setViewport", map[string]interface{}{
+		"context":          created.Context,
+		"viewport":         map[string]int64{"width": w, "height": h},
+		"devicePixelRatio": req.DeviceScale,
+	}, nil); err != nil {
+		return nil, false, err
+	}
+
+	wait := "complete"
+	if req.WaitUntil == waitUntilDOMContentLoaded {
+		wait = "interactive"
+	}
+	if err := b.call("browsingContext.navigate", map[string]string{"context": created.Context, "url": req.URL, "wait": wait}, nil); err != nil {
+		var be *bidiError
+		if errors.As(err, &be) {
+			return nil, false, &backendNavigationError{err: err}
+		}
+		return nil, false, err
+	}
+	if req.WaitTime > 0 {
+		select {
+		case <-time.After(time.Duration(req.WaitTime) * time.Millisecond):
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+
+	shot := map[string]interface{}{"context": created.Context, "origin": "viewport"}
+	if req.Format == "jpeg" {
+		shot["format"] = map[string]interface{}{"type": "image/jpeg", "quality": float64(req.Quality) / 100}
+	}
+	var clip *page.Viewport
+	if req.Clip != nil {
+		clip = &page.Viewport{X: req.Clip.X, Y: req.Clip.Y, Width: req.Clip.Width, Height: req.Clip.Height}
+		shot["origin"] = "document"
+	} else if req.FullPage {
+		size, err := b.evaluateString(created.Context, `JSON.stringify([
+			Math.max(document.documentElement.scrollWidth, document.body ? document.body.scrollWidth : 0),
+			Math.max(document.documentElement.scrollHeight, document.body ? document.body.scrollHeight : 0)])`)
+		if err != nil {
+			return nil, false, err
+		}
+		var dims [2]float64
+		if err := json.Unmarshal([]byte(size), &dims); err != nil {
+			return nil, false, err
+		}
+		clip = &page.Viewport{Width: math.Max(dims[0], float64(w)), Height: math.Max(dims[1], float64(h))}
+		truncated = limitCapturePixels(clip, req.DeviceScale)
+		shot["origin"] = "document"
+	}
+	if clip != nil {
+		shot["clip"] = map[string]interface{}{"type": "box", "x": clip.X, "y": clip.Y, "width": clip.Width, "height": clip.Height}
+	}
+	var captured struct {
+		Data string `json:"data"`
+	}
+	if err := b.call("browsingContext.captureScreenshot", shot, &captured); err != nil {
+		return nil, false, err
+	}
+	img, err = base64.StdEncoding.DecodeString(captured.Data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 页面信息只用于响应头，读取失败不影响结果
+	if info, err := b.evaluateString(created.Context, `JSON.stringify({url: location.href, title: document.title})`); err == nil {
+		_ = json.Unmarshal([]byte(info), &req.output.page)
+	}
+	return img, truncated, nil
+}
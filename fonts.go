@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/css"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+// fontReportMaxNodes 限制字体报告遍历的文本元素数量，避免超大页面产生过多 CDP 调用。
+const fontReportMaxNodes = 300
+
+// registeredFont 是 FONTS_DIR 中的一个字体文件；family 取文件名（不含扩展名）。
+type registeredFont struct {
+	Family string `json:"family"`
+	File   string `json:"file"`
+	Format string `json:"format"`
+	Size   int    `json:"size"`
+	data   []byte
+}
+
+var fontRegistry struct {
+	once   sync.Once
+	fonts  []registeredFont
+	script string
+}
+
+func fontFormatForExt(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".woff2":
+		return "woff2"
+	case ".woff":
+		return "woff"
+	case ".ttf":
+		return "truetype"
+	case ".otf":
+		return "opentype"
+	default:
+		return ""
+	}
+}
+
+func fontMIMEForFormat(format string) string {
+	switch format {
+	case "woff2":
+		return "font/woff2"
+	case "woff":
+		return "font/woff"
+	case "truetype":
+		return "font/ttf"
+	default:
+		return "font/otf"
+	}
+}
+
+// getFontsDir 返回额外字体目录（FONTS_DIR），为空表示未启用。
+func getFontsDir() string {
+	return strings.TrimSpace(os.Getenv("FONTS_DIR"))
+}
+
+// getFontsBaseURL 返回上游 Chrome 可访问的字体 URL 前缀（FONTS_BASE_URL，例如 http://screenshot-server:8080/fonts）。
+// 未配置时字体以 data: URL 内联注入，不要求上游 Chrome 能访问本服务。
+func getFontsBaseURL() string {
+	return strings.TrimRight(strings.TrimSpace(os.Getenv("FONTS_BASE_URL")), "/")
+}
+
+// registeredFonts 首次调用时加载 FONTS_DIR 下的字体文件。
+func registeredFonts() []registeredFont {
+	fontRegistry.once.Do(func() {
+		dir := getFontsDir()
+		if dir == "" {
+			return
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			log.Printf("fonts: failed to read FONTS_DIR %q: %v", dir, err)
+			return
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			ext := filepath.Ext(e.Name())
+			format := fontFormatForExt(ext)
+			if format == "" {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				log.Printf("fonts: failed to read %q: %v", e.Name(), err)
+				continue
+			}
+			fontRegistry.fonts = append(fontRegistry.fonts, registeredFont{
+				Family: strings.TrimSuffix(e.Name(), ext),
+				File:   e.Name(),
+				Format: format,
+				Size:   len(data),
+				data:   data,
+			})
+		}
+		sort.Slice(fontRegistry.fonts, func(i, j int) bool { return fontRegistry.fonts[i].File < fontRegistry.fonts[j].File })
+		log.Printf("fonts: registered %d font(s) from %s", len(fontRegistry.fonts), dir)
+
+		// 用 FontFace API 注册，不依赖 document.head 是否已存在（new document 脚本执行时 DOM 可能为空）。
+		base := getFontsBaseURL()
+		var sb strings.Builder
+		sb.WriteString("(() => {\n")
+		for _, f := range fontRegistry.fonts {
+			src := base + "/" + f.File
+			if base == "" {
+				src = "data:" + fontMIMEForFormat(f.Format) + ";base64," + base64.StdEncoding.EncodeToString(f.data)
+			}
+			family, _ := json.Marshal(f.Family)
+			source, _ := json.Marshal(fmt.Sprintf("url(%q) format(%q)", src, f.Format))
+			fmt.Fprintf(&sb, "\ttry { document.fonts.add(new FontFace(%s, %s)); } catch (e) {}\n", family, source)
+		}
+		sb.WriteString("})()")
+		fontRegistry.script = sb.String()
+	})
+	return fontRegistry.fonts
+}
+
+// injectFontsAction 在导航前注册 FONTS_DIR 中的字体（页面 CSS 以文件名作为 font-family 使用）。
+func injectFontsAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if len(registeredFonts()) == 0 {
+			return nil
+		}
+		_, err := page.AddScriptToEvaluateOnNewDocument(fontRegistry.script).Do(ctx)
+		return err
+	})
+}
+
+// FontUsage 汇总页面实际渲染使用的字体；Fallback 表示该字体不在元素声明的 font-family 中（由回退/通用族解析得到）。
+type FontUsage struct {
+	Family         string `json:"family"`
+	PostScriptName string `json:"postscript_name"`
+	Custom         bool   `json:"custom"`
+	GlyphCount     int    `json:"glyph_count"`
+	Fallback       bool   `json:"fallback"`
+}
+
+// fontReportAction 遍历含文本的元素，调用 CSS.getPlatformFontsForNode 汇总实际使用的字体。
+func fontReportAction(out *[]FontUsage) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := dom.Enable().Do(ctx); err != nil {
+			return err
+		}
+		if err := css.Enable().Do(ctx); err != nil {
+			return err
+		}
+		root, err := dom.GetDocument().WithDepth(-1).Do(ctx)
+		if err != nil {
+			return err
+		}
+
+		var textNodes []*cdp.Node
+		var walk func(n *cdp.Node)
+		walk = func(n *cdp.Node) {
+			if len(textNodes) >= fontReportMaxNodes {
+				return
+			}
+			if n.NodeType == cdp.NodeTypeElement && n.LocalName != "script" && n.LocalName != "style" {
+				for _, ch := range n.Children {
+					if ch.NodeType == cdp.NodeTypeText && strings.TrimSpace(ch.NodeValue) != "" {
+						textNodes = append(textNodes, n)
+						break
+					}
+				}
+			}
+			for _, ch := range n.Children {
+				walk(ch)
+			}
+		}
+		walk(root)
+
+		usage := map[string]*FontUsage{}
+		for _, n := range textNodes {
+			fonts, err := css.GetPlatformFontsForNode(n.NodeID).Do(ctx)
+			if err != nil {
+				continue
+			}
+			declared := map[string]bool{}
+			if computed, err := css.GetComputedStyleForNode(n.NodeID).Do(ctx); err == nil {
+				for _, p := range computed {
+					if p.Name != "font-family" {
+						continue
+					}
+					for _, fam := range strings.Split(p.Value, ",") {
+						declared[strings.ToLower(strings.Trim(strings.TrimSpace(fam), `"'`))] = true
+					}
+				}
+			}
+			for _, f := range fonts {
+				key := f.PostScriptName + "|" + f.FamilyName
+				u, ok := usage[key]
+				if !ok {
+					u = &FontUsage{Family: f.FamilyName, PostScriptName: f.PostScriptName, Custom: f.IsCustomFont}
+					usage[key] = u
+				}
+				u.GlyphCount += int(f.GlyphCount)
+				if !declared[strings.ToLower(f.FamilyName)] {
+					u.Fallback = true
+				}
+			}
+		}
+
+		res := make([]FontUsage, 0, len(usage))
+		for _, u := range usage {
+			res = append(res, *u)
+		}
+		sort.Slice(res, func(i, j int) bool { return res[i].GlyphCount > res[j].GlyphCount })
+		*out = res
+		return nil
+	})
+}
+
+// registerFontRoutes 提供已注册字体的列表与文件下载（FONTS_BASE_URL 模式下上游 Chrome 从这里拉取字体）。
+func registerFontRoutes(r *gin.Engine) {
+	r.GET("/fonts", func(c *gin.Context) {
+		fonts := registeredFonts()
+		if fonts == nil {
+			fonts = []registeredFont{}
+		}
+		c.JSON(http.StatusOK, gin.H{"fonts": fonts})
+	})
+	r.GET("/fonts/:file", func(c *gin.Context) {
+		name := c.Param("file")
+		for _, f := range registeredFonts() {
+			if f.File == name {
+				c.Header("Cache-Control", "public, max-age=86400")
+				c.Header("Access-Control-Allow-Origin", "*")
+				c.Data(http.StatusOK, fontMIMEForFormat(f.Format), f.data)
+				return
+			}
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "font not found"})
+	})
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	gcsUploadEndpoint = "https://storage.googleapis.com/upload/storage/v1"
+	gcsPublicEndpoint = "https://storage.googleapis.com"
+	gcsScope          = "https://www.googleapis.com/auth/devstorage.read_write"
+	gceTokenURL       = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	defaultTokenURI   = "https://oauth2.googleapis.com/token"
+)
+
+// gcsStore 使用 JSON API 的 media 上传（uploadType=media）把截图写入 Google Cloud Storage。
+type gcsStore struct {
+	bucket        string
+	publicBaseURL string
+	tokens        *googleTokenSource
+	client        *http.Client
+}
+
+// newGCSStoreFromEnv 读取 GCS_BUCKET / GCS_PUBLIC_BASE_URL；凭证按 ADC（Application Default Credentials）解析。
+// 未配置 GCS_BUCKET 时返回 nil。
+func newGCSStoreFromEnv() (*gcsStore, error) {
+	bucket := strings.TrimSpace(os.Getenv("GCS_BUCKET"))
+	if bucket == "" {
+		return nil, nil
+	}
+	client := &http.Client{Timeout: storageUploadTimeout}
+	tokens, err := newGoogleTokenSource(client)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsStore{
+		bucket:        bucket,
+		publicBaseURL: strings.TrimRight(strings.TrimSpace(os.Getenv("GCS_PUBLIC_BASE_URL")), "/"),
+		tokens:        tokens,
+		client:        client,
+	}, nil
+}
+
+func (s *gcsStore) put(ctx context.Context, key, contentType string, body []byte, meta map[string]string) (string, error) {
+	token, err := s.tokens.token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("gcs credentials: %w", err)
+	}
+	uploadType, payload := "media", body
+	if len(meta) > 0 {
+		// 带自定义元数据时改用 multipart 上传：第一部分为对象资源 JSON，第二部分为内容
+		var mp []byte
+		if mp, contentType, err = gcsMultipartBody(key, contentType, body, meta); err != nil {
+			return "", err
+		}
+		uploadType, payload = "multipart", mp
+	}
+	u := gcsUploadEndpoint + "/b/" + url.PathEscape(s.bucket) + "/o?" + url.Values{
+		"uploadType": {uploadType},
+		"name":       {key},
+	}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return "", fmt.Errorf("gcs upload %s: status %d: %s", key, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	base := s.publicBaseURL
+	if base == "" {
+		base = gcsPublicEndpoint + "/" + s3EscapePath(s.bucket)
+	}
+	return base + "/" + s3EscapePath(key), nil
+}
+
+// gcsMultipartBody 构造 multipart/related 上传请求体，返回请求体与对应的 Content-Type。
+func gcsMultipartBody(key, contentType string, body []byte, meta map[string]string) ([]byte, string, error) {
+	resource, err := json.Marshal(map[string]interface{}{
+		"name":        key,
+		"contentType": contentType,
+		"metadata":    meta,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, part := range []struct {
+		contentType string
+		data        []byte
+	}{{"application/json; charset=UTF-8", resource}, {contentType, body}} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := pw.Write(part.data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "multipart/related; boundary=" + w.Boundary(), nil
+}
+
+func (s *gcsStore) describe() gin.H {
+	return gin.H{"type": "gcs", "bucket": s.bucket, "credentials": s.tokens.kind}
+}
+
+// presign 生成 V4 签名地址（GOOG4-RSA-SHA256，GET，有效期 ttl）；仅 service account 凭证可用。
+func (s *gcsStore) presign(key string, ttl time.Duration, now time.Time) (string, error) {
+	if !s.canPresign() {
+		return "", errors.New("gcs presigned URLs require service account credentials")
+	}
+	creds := s.tokens.credentials()
+	now = now.UTC()
+	datetime := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/auto/storage/goog4_request"
+	u, _ := url.Parse(gcsPublicEndpoint)
+	escapedPath := "/" + s3EscapePath(s.bucket) + "/" + s3EscapePath(key)
+	q := url.Values{
+		"X-Goog-Algorithm":     {"GOOG4-RSA-SHA256"},
+		"X-Goog-Credential":    {creds.ClientEmail + "/" + scope},
+		"X-Goog-Date":          {datetime},
+		"X-Goog-Expires":       {strconv.Itoa(int(ttl.Seconds()))},
+		"X-Goog-SignedHeaders": {"host"},
+	}
+	canonicalQuery := strings.ReplaceAll(q.Encode(), "+", "%20")
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		escapedPath,
+		canonicalQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	stringToSign := "GOOG4-RSA-SHA256\n" + datetime + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	sum := sha256.Sum256([]byte(stringToSign))
+	sig, err := rsa.SignPKCS1v15(nil, creds.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return gcsPublicEndpoint + escapedPath + "?" + canonicalQuery + "&X-Goog-Signature=" + hex.EncodeToString(sig), nil
+}
+
+func (s *gcsStore) canPresign() bool { return s.tokens.kind == "service_account" }
+
+// googleTokenSource 按 ADC 顺序获取 OAuth2 访问令牌并缓存到过期前 1 分钟：
+// GOOGLE_APPLICATION_CREDENTIALS 指向的文件 → gcloud 的 application_default_credentials.json → GCE/GKE 元数据服务器。
+type googleTokenSource struct {
+	kind    string // service_account / authorized_user / metadata
+	creds   *googleCredentialsFile
+	path    string // 凭证文件路径，文件修改后自动重新读取
+	modTime time.Time
+	client  *http.Client
+
+	mu      sync.Mutex
+	cached  string
+	expires time.Time
+}
+
+// googleCredentialsFile 为 ADC JSON 文件中用到的字段（service_account 或 authorized_user）。
+type googleCredentialsFile struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+
+	key *rsa.PrivateKey
+}
+
+func newGoogleTokenSource(client *http.Client) (*googleTokenSource, error) {
+	path := strings.TrimSpace(os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"))
+	if path == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			wellKnown := filepath.Join(dir, "gcloud", "application_default_credentials.json")
+			if _, err := os.Stat(wellKnown); err == nil {
+				path = wellKnown
+			}
+		}
+	}
+	if path == "" {
+		return &googleTokenSource{kind: "metadata", client: client}, nil
+	}
+
+	creds, modTime, err := readGoogleCredentials(path)
+	if err != nil {
+		return nil, err
+	}
+	return &googleTokenSource{kind: creds.Type, creds: creds, path: path, modTime: modTime, client: client}, nil
+}
+
+// readGoogleCredentials 读取并校验 ADC 凭证文件，同时返回文件修改时间（用于轮换检测）。
+func readGoogleCredentials(path string) (*googleCredentialsFile, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("read credentials: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("read credentials: %w", err)
+	}
+	var creds googleCredentialsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, time.Time{}, fmt.Errorf("parse credentials %s: %w", path, err)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = defaultTokenURI
+	}
+	switch creds.Type {
+	case "service_account":
+		if creds.ClientEmail == "" {
+			return nil, time.Time{}, fmt.Errorf("credentials %s: missing client_email", path)
+		}
+		if creds.key, err = parseRSAPrivateKey(creds.PrivateKey); err != nil {
+			return nil, time.Time{}, fmt.Errorf("credentials %s: %w", path, err)
+		}
+	case "authorized_user":
+		if creds.ClientID == "" || creds.ClientSecret == "" || creds.RefreshToken == "" {
+			return nil, time.Time{}, fmt.Errorf("credentials %s: missing client_id, client_secret or refresh_token", path)
+		}
+	default:
+		return nil, time.Time{}, fmt.Errorf("credentials %s: unsupported type %q", path, creds.Type)
+	}
+	return &creds, info.ModTime(), nil
+}
+
+// refreshCreds 在凭证文件被替换（Kubernetes secret 轮换等）后重新读取，并丢弃用旧凭证换取的令牌；
+// 新文件无效或类型不同时保留当前凭证。调用方需持有 t.mu。
+func (t *googleTokenSource) refreshCreds() {
+	if t.path == "" {
+		return
+	}
+	info, err := os.Stat(t.path)
+	if err != nil || info.ModTime().Equal(t.modTime) {
+		return
+	}
+	creds, modTime, err := readGoogleCredentials(t.path)
+	if err == nil && creds.Type != t.kind {
+		err = fmt.Errorf("credentials type changed from %s to %s", t.kind, creds.Type)
+	}
+	if err != nil {
+		log.Printf("gcs: keeping current credentials: %v", err)
+		t.modTime = info.ModTime()
+		return
+	}
+	t.creds, t.modTime = creds, modTime
+	t.cached = ""
+	log.Printf("gcs: credentials reloaded from %s", t.path)
+}
+
+// credentials 返回当前凭证（必要时重新读取文件）。
+func (t *googleTokenSource) credentials() *googleCredentialsFile {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refreshCreds()
+	return t.creds
+}
+
+func parseRSAPrivateKey(raw string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(raw))
+	if block == nil {
+		return nil, errors.New("invalid private_key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private_key is not an RSA key")
+	}
+	return key, nil
+}
+
+func (t *googleTokenSource) token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.refreshCreds()
+	if t.cached != "" && time.Now().Before(t.expires) {
+		return t.cached, nil
+	}
+
+	var req *http.Request
+	var err error
+	switch t.kind {
+	case "metadata":
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, gceTokenURL, nil)
+		if err == nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	case "service_account":
+		var assertion string
+		if assertion, err = t.signJWT(time.Now()); err != nil {
+			return "", err
+		}
+		req, err = newFormRequest(ctx, t.creds.TokenURI, url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		})
+	default:
+		req, err = newFormRequest(ctx, t.creds.TokenURI, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {t.creds.ClientID},
+			"client_secret": {t.creds.ClientSecret},
+			"refresh_token": {t.creds.RefreshToken},
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("token request (%s): status %d: %s", t.kind, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil || tok.AccessToken == "" {
+		return "", fmt.Errorf("token request (%s): invalid response", t.kind)
+	}
+	t.cached = tok.AccessToken
+	t.expires = time.Now().Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return t.cached, nil
+}
+
+// signJWT 生成 service account 的 RS256 JWT 断言（有效期 1 小时）。
+func (t *googleTokenSource) signJWT(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   t.creds.ClientEmail,
+		"scope": gcsScope,
+		"aud":   t.creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(nil, t.creds.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + enc.EncodeToString(sig), nil
+}
+
+func newFormRequest(ctx context.Context, target string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
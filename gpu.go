@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+// gpuProbeTTL 控制 GPU/WebGL 探测结果的缓存时间；上游浏览器能力通常不会频繁变化。
+const gpuProbeTTL = 5 * time.Minute
+
+// GPUInfo 描述上游浏览器的 WebGL 能力。
+type GPUInfo struct {
+	WebGL     bool      `json:"webgl"`
+	WebGL2    bool      `json:"webgl2"`
+	Vendor    string    `json:"vendor,omitempty"`
+	Renderer  string    `json:"renderer,omitempty"`
+	Software  bool      `json:"software"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// gpuProbeJS 在当前 tab 中创建离屏 canvas 探测 WebGL，并尽量读取未屏蔽的 vendor/renderer。
+const gpuProbeJS = `(() => {
+	const out = { webgl: false, webgl2: false, vendor: '', renderer: '' };
+	try {
+		const c = document.createElement('canvas');
+		const gl = c.getContext('webgl') || c.getContext('experimental-webgl');
+		if (gl) {
+			out.webgl = true;
+			const ext = gl.getExtension('WEBGL_debug_renderer_info');
+			out.vendor = String(ext ? gl.getParameter(ext.UNMASKED_VENDOR_WEBGL) : gl.getParameter(gl.VENDOR));
+			out.renderer = String(ext ? gl.getParameter(ext.UNMASKED_RENDERER_WEBGL) : gl.getParameter(gl.RENDERER));
+		}
+		out.webgl2 = !!document.createElement('canvas').getContext('webgl2');
+	} catch (e) {}
+	return out;
+})()`
+
+// gpuProbeCache 按上游分别缓存探测结果：多个 browserless 地址的 GPU 能力可能不同。
+var gpuProbeCache = struct {
+	mu    sync.Mutex
+	infos map[string]*GPUInfo
+}{infos: map[string]*GPUInfo{}}
+
+// gpuProbeKey 为 wsURL 所属上游的缓存键（ws 地址的 host，同一 browserless 的各个连接共用）。
+func gpuProbeKey(wsURL string) string {
+	if u, err := url.Parse(wsURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return wsURL
+}
+
+// cachedGPUInfo 返回该上游最近一次探测结果（可能为 nil 或已过期）。
+func cachedGPUInfo(wsURL string) *GPUInfo {
+	gpuProbeCache.mu.Lock()
+	defer gpuProbeCache.mu.Unlock()
+	return gpuProbeCache.infos[gpuProbeKey(wsURL)]
+}
+
+// gpuInfoByHost 返回各上游（按 ws host）最近一次的探测结果，用于 /health 的 upstreams。
+func gpuInfoByHost() map[string]*GPUInfo {
+	gpuProbeCache.mu.Lock()
+	defer gpuProbeCache.mu.Unlock()
+	out := make(map[string]*GPUInfo, len(gpuProbeCache.infos))
+	for k, v := range gpuProbeCache.infos {
+		out[k] = v
+	}
+	return out
+}
+
+// isSoftwareRenderer 判断 renderer 是否为软件光栅化（SwiftShader/llvmpipe 等）。
+func isSoftwareRenderer(renderer string) bool {
+	r := strings.ToLower(renderer)
+	return strings.Contains(r, "swiftshader") || strings.Contains(r, "llvmpipe") || strings.Contains(r, "software")
+}
+
+// probeGPU 在给定 tab（属于 wsURL 所在上游）中执行探测（该上游的结果未过期时直接返回缓存）。
+// 需在导航前调用，避免受目标页面 CSP/脚本影响。
+func probeGPU(ctx context.Context, wsURL string) (*GPUInfo, error) {
+	if info := cachedGPUInfo(wsURL); info != nil && time.Since(info.CheckedAt) < gpuProbeTTL {
+		return info, nil
+	}
+	var info GPUInfo
+	if err := chromedp.EvaluateAsDevTools(gpuProbeJS, &info).Do(ctx); err != nil {
+		return nil, err
+	}
+	info.Software = isSoftwareRenderer(info.Renderer)
+	info.CheckedAt = time.Now().UTC()
+
+	gpuProbeCache.mu.Lock()
+	gpuProbeCache.infos[gpuProbeKey(wsURL)] = &info
+	gpuProbeCache.mu.Unlock()
+	return &info, nil
+}
+
+// errWebGLUnavailable 用于 requires_webgl 请求在无 WebGL 的上游上快速失败。
+type errWebGLUnavailable struct {
+	info *GPUInfo
+}
+
+func (e *errWebGLUnavailable) Error() string {
+	return "upstream browser has no WebGL support (renderer=" + e.info.Renderer + ")"
+}
+
+// requireWebGLAction 探测 wsURL 所在上游的 WebGL 能力，不可用时返回 *errWebGLUnavailable。
+func requireWebGLAction(wsURL string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		info, err := probeGPU(ctx, wsURL)
+		if err != nil {
+			return err
+		}
+		if !info.WebGL {
+			return &errWebGLUnavailable{info: info}
+		}
+		return nil
+	})
+}
+
+// browserInfoHandler 返回本次选中的上游浏览器版本与 GPU/WebGL 能力（会强制刷新该上游的探测结果）。
+func browserInfoHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(defaultTimeoutSec)*time.Second)
+		defer cancel()
+
+		sess := openChromeSession(c, overallCtx, "browserInfoHandler")
+		if sess == nil {
+			return
+		}
+		defer sess.cancel()
+
+		var product, protocolVersion, revision, userAgent, jsVersion string
+		var gpu *GPUInfo
+		if err := chromedp.Run(sess.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			bctx := cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Browser)
+			protocolVersion, product, revision, userAgent, jsVersion, err = browser.GetVersion().Do(bctx)
+			if err != nil {
+				return err
+			}
+			gpuProbeCache.mu.Lock()
+			delete(gpuProbeCache.infos, gpuProbeKey(sess.wsURL))
+			gpuProbeCache.mu.Unlock()
+			gpu, err = probeGPU(ctx, sess.wsURL)
+			return err
+		})); err != nil {
+			respondRunError(c, err, sess.wsURL, "browser probe timeout", "failed to probe browser")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"upstream":         gpuProbeKey(redactSensitiveURL(sess.wsURL)),
+			"product":          product,
+			"protocol_version": protocolVersion,
+			"revision":         revision,
+			"user_agent":       userAgent,
+			"js_version":       jsVersion,
+			"gpu":              gpu,
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	maxHideSelectors  = 50
+	maxInjectCSSBytes = 256 << 10
+)
+
+// validateHideSelectors 校验 hide_selectors：最多 50 个非空 CSS 选择器，每个选择器须能安全地拼接成独立规则（见 checkHideSelector）。
+func (r *ScreenshotRequest) validateHideSelectors() error {
+	if len(r.HideSelectors) > maxHideSelectors {
+		return fmt.Errorf("hide_selectors must contain at most %d selectors", maxHideSelectors)
+	}
+	for i, sel := range r.HideSelectors {
+		r.HideSelectors[i] = strings.TrimSpace(sel)
+		if r.HideSelectors[i] == "" {
+			return fmt.Errorf("hide_selectors[%d] is empty", i)
+		}
+		if err := checkHideSelector(r.HideSelectors[i]); err != nil {
+			return fmt.Errorf("hide_selectors[%d] %w", i, err)
+		}
+	}
+	return nil
+}
+
+var selectorBrackets = map[rune]rune{'[': ']', '(': ')'}
+
+// checkHideSelector 拒绝可能逃出自身规则的选择器：花括号、注释（/* */）、字符串外的 @ 与 ;，
+// 以及未闭合的引号、括号或末尾的反斜杠（会吞掉后续规则）。
+func checkHideSelector(sel string) error {
+	if strings.ContainsAny(sel, "{}") {
+		return errors.New("must not contain { or }")
+	}
+	if strings.Contains(sel, "/*") || strings.Contains(sel, "*/") {
+		return errors.New("must not contain comments")
+	}
+	var (
+		quote   rune
+		escaped bool
+		open    []rune
+	)
+	for _, ch := range sel {
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else if ch == '\n' || ch == '\r' || ch == '\f' {
+				return errors.New("has an unterminated string")
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '@' || ch == ';':
+			return fmt.Errorf("must not contain %c outside a string", ch)
+		case ch == '[' || ch == '(':
+			open = append(open, ch)
+		case ch == ']' || ch == ')':
+			if len(open) == 0 || selectorBrackets[open[len(open)-1]] != ch {
+				return fmt.Errorf("has an unbalanced %c", ch)
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	switch {
+	case escaped:
+		return errors.New("must not end with a backslash")
+	case quote != 0:
+		return errors.New("has an unterminated string")
+	case len(open) > 0:
+		return fmt.Errorf("has an unclosed %c", open[len(open)-1])
+	}
+	return nil
+}
+
+// validateInjectCSS 校验 inject_css 的大小（最大 256KB）。
+func (r *ScreenshotRequest) validateInjectCSS() error {
+	if len(r.InjectCSS) > maxInjectCSSBytes {
+		return errors.New("inject_css must be at most 256KB")
+	}
+	return nil
+}
+
+// hideSelectorsCSS 为每个选择器生成一条独立规则：无效的选择器只会使自身规则失效，不影响其他选择器。
+func hideSelectorsCSS(selectors []string) string {
+	var sb strings.Builder
+	for _, sel := range selectors {
+		sb.WriteString(sel)
+		sb.WriteString(" { display: none !important; }\n")
+	}
+	return sb.String()
+}
+
+// injectStyleAction 在当前文档中追加一个 <style>；之后出现的元素同样受其约束（如延迟弹出的聊天窗口）。
+func injectStyleAction(css string) chromedp.Action {
+	arg, _ := json.Marshal(css)
+	return chromedp.Evaluate(`(() => {
+	const s = document.createElement('style');
+	s.setAttribute('data-screenshot-server', '');
+	s.textContent = `+string(arg)+`;
+	(document.head || document.documentElement).appendChild(s);
+})()`, nil)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckHideSelector(t *testing.T) {
+	valid := []string{
+		".chat-widget",
+		"#cookie-banner > div:not(.keep)",
+		`a[href^="mailto:x@y.com"]`,
+		`[data-x='a;b']`,
+		`div:has(> img[alt="(]"])`,
+		`.w-1\/2`,
+	}
+	for _, sel := range valid {
+		if err := checkHideSelector(sel); err != nil {
+			t.Errorf("checkHideSelector(%q) = %v, want nil", sel, err)
+		}
+	}
+
+	invalid := []string{
+		"a { color: red } b",
+		"a}",
+		`.a\{b`,
+		"a /* x */",
+		"a */",
+		"@import url(x)",
+		"a; b",
+		`a[x="b`,
+		`a[x='b`,
+		"a[x=\"b\nc\"]",
+		"a[x",
+		"a:not(.b",
+		"a]",
+		"a:not(.b]",
+		`a\`,
+	}
+	for _, sel := range invalid {
+		if err := checkHideSelector(sel); err == nil {
+			t.Errorf("checkHideSelector(%q) = nil, want error", sel)
+		}
+	}
+}
+
+func TestValidateHideSelectors(t *testing.T) {
+	r := &ScreenshotRequest{HideSelectors: []string{" .a ", ".b"}}
+	if err := r.validateHideSelectors(); err != nil {
+		t.Fatal(err)
+	}
+	if r.HideSelectors[0] != ".a" {
+		t.Fatalf("selector not trimmed: %q", r.HideSelectors[0])
+	}
+
+	r = &ScreenshotRequest{HideSelectors: []string{".a", "b;c"}}
+	err := r.validateHideSelectors()
+	if err == nil || !strings.HasPrefix(err.Error(), "hide_selectors[1] ") {
+		t.Fatalf("err = %v, want hide_selectors[1] error", err)
+	}
+}
+
+func TestHideSelectorsCSS(t *testing.T) {
+	got := hideSelectorsCSS([]string{".a", "#b"})
+	want := ".a { display: none !important; }\n#b { display: none !important; }\n"
+	if got != want {
+		t.Fatalf("hideSelectorsCSS = %q, want %q", got, want)
+	}
+}
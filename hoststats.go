@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxTrackedHosts 限制统计的主机数（也即 /metrics 的 host 标签数），超出时淘汰最久未出现的主机。
+	maxTrackedHosts = 500
+	// hostRecentAlpha / hostBaselineAlpha 为近期与基线耗时的指数移动平均权重（约最近 5 次与最近 100 次）。
+	hostRecentAlpha   = 0.2
+	hostBaselineAlpha = 0.01
+
+	defaultHostSlowFactor     = 2.0
+	defaultHostSlowMinSamples = 30
+)
+
+// hostCaptureStats 为单个目标主机的截图耗时统计。
+type hostCaptureStats struct {
+	Host       string    `json:"host"`
+	Captures   int64     `json:"captures"`
+	Errors     int64     `json:"errors"`
+	ErrorRate  float64   `json:"error_rate"`
+	AverageMS  float64   `json:"average_ms"`
+	RecentMS   float64   `json:"recent_ms"`
+	BaselineMS float64   `json:"baseline_ms"`
+	LastMS     float64   `json:"last_ms"`
+	LastSeen   time.Time `json:"last_seen"`
+	SlowFactor float64   `json:"slow_factor,omitempty"`
+	Slow       bool      `json:"slow"`
+
+	totalMS     float64 // 成功截图的耗时总和
+	sampleCount int64   // 成功截图数
+}
+
+// hostStatsTracker 按目标主机统计截图耗时：近期均值明显高于长期基线（HOST_SLOW_FACTOR 倍，且样本数不少于
+// HOST_SLOW_MIN_SAMPLES）的主机标记为 slow，用于发现拖慢整体延迟的目标站点。
+type hostStatsTracker struct {
+	slowFactor     float64
+	slowMinSamples int64
+
+	mu    sync.Mutex
+	hosts map[string]*hostCaptureStats
+}
+
+var hostStats = newHostStatsTrackerFromEnv()
+
+// newHostStatsTrackerFromEnv 读取 HOST_SLOW_FACTOR（默认 2）与 HOST_SLOW_MIN_SAMPLES（默认 30）。
+func newHostStatsTrackerFromEnv() *hostStatsTracker {
+	t := &hostStatsTracker{
+		slowFactor:     defaultHostSlowFactor,
+		slowMinSamples: defaultHostSlowMinSamples,
+		hosts:          map[string]*hostCaptureStats{},
+	}
+	if f, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv("HOST_SLOW_FACTOR")), 64); err == nil && f > 1 {
+		t.slowFactor = f
+	}
+	if n, err := strconv.ParseInt(strings.TrimSpace(os.Getenv("HOST_SLOW_MIN_SAMPLES")), 10, 64); err == nil && n > 0 {
+		t.slowMinSamples = n
+	}
+	return t
+}
+
+// targetHost 为请求目标的主机名（小写）；html 渲染为 "html"，无法解析时为 "unknown"。
+func (r *ScreenshotRequest) targetHost() string {
+	if r.HTML != "" {
+		return "html"
+	}
+	if u, err := url.Parse(r.URL); err == nil && u.Hostname() != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return "unknown"
+}
+
+// observe 记录一次截图；失败（非 2xx）只计数，不计入耗时，避免快速失败拉低均值。
+func (t *hostStatsTracker) observe(host string, d time.Duration, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.hosts[host]
+	if s == nil {
+		if len(t.hosts) >= maxTrackedHosts {
+			t.evictOldest()
+		}
+		s = &hostCaptureStats{Host: host}
+		t.hosts[host] = s
+	}
+	s.Captures++
+	s.LastSeen = time.Now()
+	if !ok {
+		s.Errors++
+		return
+	}
+	ms := float64(d) / float64(time.Millisecond)
+	s.LastMS = ms
+	s.totalMS += ms
+	if s.sampleCount == 0 {
+		s.RecentMS, s.BaselineMS = ms, ms
+	} else {
+		s.RecentMS += (ms - s.RecentMS) * hostRecentAlpha
+		s.BaselineMS += (ms - s.BaselineMS) * hostBaselineAlpha
+	}
+	s.sampleCount++
+}
+
+// evictOldest 删除最久未出现的主机（调用方需持有锁）。
+func (t *hostStatsTracker) evictOldest() {
+	var oldest string
+	for h, s := range t.hosts {
+		if oldest == "" || s.LastSeen.Before(t.hosts[oldest].LastSeen) {
+			oldest = h
+		}
+	}
+	delete(t.hosts, oldest)
+}
+
+// snapshot 返回各主机统计的副本（已计算 slow 等派生字段），按近期耗时从高到低排序。
+func (t *hostStatsTracker) snapshot() []hostCaptureStats {
+	t.mu.Lock()
+	out := make([]hostCaptureStats, 0, len(t.hosts))
+	for _, s := range t.hosts {
+		out = append(out, *s)
+	}
+	t.mu.Unlock()
+	for i := range out {
+		s := &out[i]
+		if s.sampleCount > 0 {
+			s.AverageMS = s.totalMS / float64(s.sampleCount)
+		}
+		if s.Captures > 0 {
+			s.ErrorRate = float64(s.Errors) / float64(s.Captures)
+		}
+		if s.BaselineMS > 0 {
+			s.SlowFactor = s.RecentMS / s.BaselineMS
+		}
+		s.Slow = s.sampleCount >= t.slowMinSamples && s.SlowFactor >= t.slowFactor
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].RecentMS > out[j].RecentMS })
+	return out
+}
+
+// hostStatsHandler 处理 GET /stats/hosts：返回各主机的耗时统计；slow=true 时只返回被标记为变慢的主机。
+func hostStatsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		onlySlow, err := parseBoolQuery(c, "slow", false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		hosts := hostStats.snapshot()
+		if onlySlow {
+			filtered := hosts[:0]
+			for _, h := range hosts {
+				if h.Slow {
+					filtered = append(filtered, h)
+				}
+			}
+			hosts = filtered
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"slow_factor":      hostStats.slowFactor,
+			"slow_min_samples": hostStats.slowMinSamples,
+			"hosts":            hosts,
+		})
+	}
+}
+
+// metricsHandler 处理 GET /metrics：以 Prometheus 文本格式输出按主机的截图统计（host 标签数受 maxTrackedHosts 限制）。
+func metricsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hosts := hostStats.snapshot()
+		sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+		var b strings.Builder
+		metric := func(name, typ, help string, value func(s hostCaptureStats) float64) {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+			for _, s := range hosts {
+				fmt.Fprintf(&b, "%s{host=%q} %s\n", name, s.Host, strconv.FormatFloat(value(s), 'g', -1, 64))
+			}
+		}
+		metric("screenshot_host_captures_total", "counter", "Captures per target host.", func(s hostCaptureStats) float64 { return float64(s.Captures) })
+		metric("screenshot_host_capture_errors_total", "counter", "Failed captures per target host.", func(s hostCaptureStats) float64 { return float64(s.Errors) })
+		metric("screenshot_host_capture_duration_seconds_sum", "counter", "Total duration of successful captures per target host.", func(s hostCaptureStats) float64 { return s.totalMS / 1000 })
+		metric("screenshot_host_capture_duration_seconds_count", "counter", "Successful captures per target host.", func(s hostCaptureStats) float64 { return float64(s.sampleCount) })
+		metric("screenshot_host_capture_recent_seconds", "gauge", "Moving average of recent capture durations per target host.", func(s hostCaptureStats) float64 { return s.RecentMS / 1000 })
+		metric("screenshot_host_capture_baseline_seconds", "gauge", "Long-term moving average of capture durations per target host.", func(s hostCaptureStats) float64 { return s.BaselineMS / 1000 })
+		metric("screenshot_host_slow", "gauge", "1 if the host's recent captures are significantly slower than its baseline.", func(s hostCaptureStats) float64 {
+			if s.Slow {
+				return 1
+			}
+			return 0
+		})
+		c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	maxInjectJSBytes = 256 << 10
+	// waitForExpressionInterval 为 wait_for_expression 的求值间隔。
+	waitForExpressionInterval = 100 * time.Millisecond
+)
+
+// injectJSAllowed 读取 ALLOW_INJECT_JS；自定义脚本可在目标页面中执行任意代码，默认关闭。
+var injectJSAllowed = func() bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("ALLOW_INJECT_JS")))
+	if err == nil && v {
+		log.Printf("WARNING: ALLOW_INJECT_JS is enabled, requests may run custom scripts via inject_js_before / inject_js_after / wait_for_expression")
+	}
+	return err == nil && v
+}()
+
+// validateInjectJS 校验 inject_js_before / inject_js_after / wait_for_expression：需服务端开启 ALLOW_INJECT_JS，单个脚本最大 256KB。
+func (r *ScreenshotRequest) validateInjectJS() error {
+	r.WaitForExpression = strings.TrimSpace(r.WaitForExpression)
+	if r.InjectJSBefore == "" && r.InjectJSAfter == "" && r.WaitForExpression == "" {
+		return nil
+	}
+	if !injectJSAllowed {
+		return errors.New("inject_js_before, inject_js_after and wait_for_expression are disabled on this server (ALLOW_INJECT_JS)")
+	}
+	if len(r.InjectJSBefore) > maxInjectJSBytes || len(r.InjectJSAfter) > maxInjectJSBytes || len(r.WaitForExpression) > maxInjectJSBytes {
+		return errors.New("inject_js_before, inject_js_after and wait_for_expression must be at most 256KB each")
+	}
+	return nil
+}
+
+// injectJSBeforeAction 通过 Page.addScriptToEvaluateOnNewDocument 注册脚本：在每个文档（含 iframe）的页面脚本之前执行，
+// 适合替换/模拟浏览器 API。
+func injectJSBeforeAction(script string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		_, err := page.AddScriptToEvaluateOnNewDocument(script).Do(ctx)
+		return err
+	})
+}
+
+// injectJSAfterAction 在页面加载与等待之后、截图之前执行脚本。脚本包在 async 函数中执行并等待其完成，
+// 可直接使用 await（如点击后等待动画结束）；脚本抛出异常时请求失败。
+func injectJSAfterAction(script string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		// 以 RemoteObject 接收结果：脚本的返回值（DOM 节点等）无需可序列化
+		var res *runtime.RemoteObject
+		if err := chromedp.Evaluate("(async () => {\n"+script+"\n})()", &res, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true).WithObjectGroup("inject_js")
+		}).Do(ctx); err != nil {
+			return fmt.Errorf("inject_js_after: %w", err)
+		}
+		return runtime.ReleaseObjectGroup("inject_js").Do(ctx)
+	})
+}
+
+// waitForExpressionAction 每隔 100ms 在页面中求值 expr，直到结果为 truthy（返回 Promise 时等待其完成）；
+// 不单独设超时，由请求的时间预算约束。表达式抛出异常时请求失败。
+func waitForExpressionAction(expr string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var res *runtime.RemoteObject
+		if err := chromedp.Poll(expr, &res, chromedp.WithPollingInterval(waitForExpressionInterval), chromedp.WithPollingTimeout(0)).Do(ctx); err != nil {
+			return fmt.Errorf("wait_for_expression: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// normalizeIRI 将 IRI 转换为纯 ASCII 的 URL：含非 ASCII 字符的主机名转为 punycode（IDNA2008，含大小写/NFC 映射），
+// 路径、查询与片段中的非 ASCII 字符及空白按 UTF-8 百分号编码；已有的 %XX 保持不变。
+func normalizeIRI(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+
+	// 纯 ASCII 主机名保持原样（IDNA 的严格规则会拒绝 docker 服务名中常见的下划线等）
+	if host := u.Hostname(); !isASCII(host) && net.ParseIP(host) == nil {
+		ascii, err := idna.Lookup.ToASCII(host)
+		if err != nil {
+			return "", fmt.Errorf("invalid host %q: %w", host, err)
+		}
+		if port := u.Port(); port != "" {
+			u.Host = net.JoinHostPort(ascii, port)
+		} else {
+			u.Host = ascii
+		}
+	}
+
+	// 路径与片段由 URL.String 按需转义；查询串需自行处理
+	u.RawQuery = escapeNonASCII(u.RawQuery)
+	return u.String(), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeNonASCII 对非 ASCII 字节、空白与控制字符做百分号编码，其余字符（包括已有的 %XX）原样保留。
+func escapeNonASCII(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if b >= 0x80 || b <= 0x20 || b == 0x7f {
+			fmt.Fprintf(&sb, "%%%02X", b)
+			continue
+		}
+		sb.WriteByte(b)
+	}
+	return sb.String()
+}
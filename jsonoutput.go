@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseTypeJSON 返回包含 base64 内容与页面信息的 JSON（用于无法处理二进制响应体的网关）。
+const responseTypeJSON = "json"
+
+// JSONOutput 为 response_type=json 的响应体；width/height 为图片像素尺寸，blurhash 为占位符，palette=true 时另有 dominant_color / palette（非图片输出时均省略）。
+type JSONOutput struct {
+	ImageBase64   string   `json:"image_base64"`
+	ContentType   string   `json:"content_type"`
+	Width         int      `json:"width,omitempty"`
+	Height        int      `json:"height,omitempty"`
+	Blurhash      string   `json:"blurhash,omitempty"`
+	DominantColor string   `json:"dominant_color,omitempty"`
+	Palette       []string `json:"palette,omitempty"`
+	FinalURL      string   `json:"final_url"`
+	PageTitle     string   `json:"page_title"`
+	PageStatus    int64    `json:"page_status,omitempty"`
+	DurationMS    int64    `json:"duration_ms"`
+	Bytes         int      `json:"bytes"`
+}
+
+func respondJSONOutput(c *gin.Context, info *outputInfo, contentType string, body []byte) {
+	out := JSONOutput{
+		ImageBase64: base64.StdEncoding.EncodeToString(body),
+		ContentType: contentType,
+		Bytes:       len(body),
+	}
+	out.Width, out.Height = imageDimensions(body)
+	if info != nil {
+		if out.Width > 0 {
+			out.Blurhash = info.blurhash
+			if info.palette != nil {
+				out.DominantColor, out.Palette = info.palette.Dominant, info.palette.Colors
+			}
+		}
+		out.FinalURL = info.page.URL
+		out.PageTitle = info.page.Title
+		out.PageStatus = info.status.Load()
+		out.DurationMS = time.Since(info.started).Milliseconds()
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// imageDimensions 返回 PNG/JPEG/WebP 图片的像素尺寸；无法识别时返回 0, 0。
+func imageDimensions(data []byte) (int, int) {
+	if w, h, ok := webpDimensions(data); ok {
+		return w, h
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
+
+// webpDimensions 解析 WebP 的 VP8 / VP8L / VP8X 头部（标准库不支持 WebP 解码）。
+func webpDimensions(data []byte) (int, int, bool) {
+	if len(data) < 30 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, false
+	}
+	switch string(data[12:16]) {
+	case "VP8 ":
+		w := int(binary.LittleEndian.Uint16(data[26:28]) & 0x3fff)
+		h := int(binary.LittleEndian.Uint16(data[28:30]) & 0x3fff)
+		return w, h, true
+	case "VP8L":
+		b := binary.LittleEndian.Uint32(data[21:25])
+		return int(b&0x3fff) + 1, int((b>>14)&0x3fff) + 1, true
+	case "VP8X":
+		w := int(data[24]) | int(data[25])<<8 | int(data[26])<<16
+		h := int(data[27]) | int(data[28])<<8 | int(data[29])<<16
+		return w + 1, h + 1, true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	defaultListingMaxPages = 10
+	maxListingPages        = 50
+	defaultListingWait     = 1000
+	maxListingWait         = 30000
+
+	listingStoppedNoNext    = "no_next"
+	listingStoppedUnchanged = "unchanged"
+	listingStoppedMaxPages  = "max_pages"
+)
+
+// PaginateOptions 为列表翻页截图参数：反复点击 next_selector 指向的“下一页”控件，逐页截图。
+type PaginateOptions struct {
+	// NextSelector 为“下一页”控件的 CSS 选择器；找不到、不可见或已禁用时结束。
+	NextSelector string `json:"next_selector"`
+	// MaxPages 为最多截取的页数（含第一页），默认 10，最大 50。
+	MaxPages int `json:"max_pages"`
+	// Wait 为每次点击后等待页面更新的时间（毫秒），默认 1000。
+	Wait int `json:"wait"`
+}
+
+// ListingPage 为翻页截图中每一页的元数据。
+type ListingPage struct {
+	Index  int    `json:"index"`
+	File   string `json:"file"`
+	URL    string `json:"url"`
+	Title  string `json:"title"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// ListingManifest 为翻页截图 ZIP 中的 pages.json；Stopped 为结束原因：
+// no_next（没有可点击的下一页）、unchanged（点击后页面未变化）、max_pages（达到 max_pages）。
+type ListingManifest struct {
+	Pages   []ListingPage `json:"pages"`
+	Stopped string        `json:"stopped"`
+}
+
+// validateListing 校验 paginate（翻页截图）参数；不能与 paginate_height 以及元素/区域截图、mode 预设、trace、trim 同时使用。
+func (r *ScreenshotRequest) validateListing() error {
+	if r.Paginate == nil {
+		return nil
+	}
+	p := r.Paginate
+	p.NextSelector = strings.TrimSpace(p.NextSelector)
+	if p.NextSelector == "" {
+		return errors.New("paginate.next_selector is required")
+	}
+	if p.MaxPages == 0 {
+		p.MaxPages = defaultListingMaxPages
+	}
+	if p.MaxPages < 1 || p.MaxPages > maxListingPages {
+		return fmt.Errorf("paginate.max_pages must be between 1 and %d", maxListingPages)
+	}
+	if p.Wait == 0 {
+		p.Wait = defaultListingWait
+	}
+	if p.Wait < 0 || p.Wait > maxListingWait {
+		return fmt.Errorf("paginate.wait must be between 0 and %d", maxListingWait)
+	}
+	if r.PaginateHeight != 0 {
+		return errors.New("paginate cannot be combined with paginate_height")
+	}
+	if r.Selector != "" || r.Clip != nil {
+		return errors.New("paginate cannot be combined with selector or clip")
+	}
+	if r.Mode != "" || r.Trace || r.Trim {
+		return errors.New("paginate cannot be combined with mode, trace or trim")
+	}
+	return nil
+}
+
+// listingNextJS 点击“下一页”控件；控件不存在、不可见或已禁用时返回 false。
+const listingNextJS = `(() => {
+  const el = document.querySelector(%s);
+  if (!el) return false;
+  if (el.disabled || el.getAttribute('aria-disabled') === 'true' || el.classList.contains('disabled')) return false;
+  const r = el.getBoundingClientRect();
+  if (r.width === 0 && r.height === 0) return false;
+  el.scrollIntoView({block: 'center'});
+  el.click();
+  return true;
+})()`
+
+// listingStateJS 返回当前页面状态的摘要（地址 + 正文哈希），用于判断点击后页面是否变化。
+const listingStateJS = `(() => {
+  const text = document.body ? document.body.innerText : '';
+  let h = 5381;
+  for (let i = 0; i < text.length; i++) h = ((h << 5) + h + text.charCodeAt(i)) | 0;
+  return location.href + '#' + text.length + ':' + h;
+})()`
+
+// captureListing 逐页截图：截取当前页 → 点击下一页 → 等待 wait 毫秒与 body 就绪，直到没有下一页、
+// 页面不再变化或达到 max_pages。shot 截取当前页面状态。
+func captureListing(ctx context.Context, req *ScreenshotRequest, shot func(context.Context) ([]byte, error), pages *[][]byte, manifest *ListingManifest) error {
+	opts := req.Paginate
+	sel, _ := json.Marshal(opts.NextSelector)
+	wait := time.Duration(opts.Wait) * time.Millisecond
+	for i := 0; ; i++ {
+		var info pageInfo
+		if err := pageInfoAction(&info).Do(ctx); err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+		buf, err := shot(ctx)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+		w, h := imageDimensions(buf)
+		*pages = append(*pages, buf)
+		manifest.Pages = append(manifest.Pages, ListingPage{
+			Index:  i + 1,
+			File:   pageFileName(i, req.Format),
+			URL:    info.URL,
+			Title:  info.Title,
+			Width:  w,
+			Height: h,
+		})
+		if i+1 >= opts.MaxPages {
+			manifest.Stopped = listingStoppedMaxPages
+			return nil
+		}
+
+		var before, after string
+		if err := chromedp.Evaluate(listingStateJS, &before).Do(ctx); err != nil {
+			return err
+		}
+		var clicked bool
+		if err := chromedp.Evaluate(fmt.Sprintf(listingNextJS, sel), &clicked).Do(ctx); err != nil {
+			return fmt.Errorf("paginate.next_selector: %w", err)
+		}
+		if !clicked {
+			manifest.Stopped = listingStoppedNoNext
+			return nil
+		}
+		// 点击可能触发整页导航，也可能只在页面内更新内容
+		if err := chromedp.Sleep(wait).Do(ctx); err != nil {
+			return err
+		}
+		if err := chromedp.WaitReady("body", chromedp.ByQuery).Do(ctx); err != nil {
+			return err
+		}
+		if err := chromedp.Evaluate(listingStateJS, &after).Do(ctx); err != nil {
+			return err
+		}
+		if after == before {
+			manifest.Stopped = listingStoppedUnchanged
+			return nil
+		}
+	}
+}
+
+// fullPageClip 用 LayoutMetrics 的 contentSize 构造整页截图区域。
+func fullPageClip(ctx context.Context) (*page.Viewport, error) {
+	_, _, contentSize, _, _, _, err := page.GetLayoutMetrics().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if contentSize == nil {
+		return nil, errors.New("failed to get layout metrics content size")
+	}
+	if contentSize.Width <= 0 || contentSize.Height <= 0 {
+		return nil, fmt.Errorf("invalid content size: %vx%v", contentSize.Width, contentSize.Height)
+	}
+	return &page.Viewport{X: 0, Y: 0, Width: contentSize.Width, Height: contentSize.Height, Scale: 1}, nil
+}
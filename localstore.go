@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	localStoreRoute           = "/stored"
+	localStoreJanitorInterval = time.Minute
+	localStoreTempPrefix      = ".upload-"
+)
+
+// localStore 把截图保存在本地目录（STORAGE_LOCAL_DIR），通过 GET /stored/<key> 取回；
+// 后台 janitor 按 STORAGE_LOCAL_MAX_AGE 删除过期文件，并在总大小超过 STORAGE_LOCAL_MAX_BYTES 时从最旧的开始删除。
+type localStore struct {
+	dir           string
+	maxAge        time.Duration // 0 不限制
+	maxBytes      int64         // 0 不限制
+	publicBaseURL string
+	signingKey    *secret // 配置后 /stored 只接受签名地址
+
+	mu        sync.Mutex
+	files     int
+	bytes     int64
+	removed   int64
+	lastSweep time.Time
+}
+
+// newLocalStoreFromEnv 读取 STORAGE_LOCAL_DIR / STORAGE_LOCAL_MAX_AGE / STORAGE_LOCAL_MAX_BYTES / STORAGE_LOCAL_PUBLIC_BASE_URL
+// / STORAGE_LOCAL_SIGNING_KEY；
+// 未配置 STORAGE_LOCAL_DIR 时返回 nil。
+func newLocalStoreFromEnv() (*localStore, error) {
+	dir := strings.TrimSpace(os.Getenv("STORAGE_LOCAL_DIR"))
+	if dir == "" {
+		return nil, nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, fmt.Errorf("create STORAGE_LOCAL_DIR: %w", err)
+	}
+	s := &localStore{
+		dir:           abs,
+		maxAge:        envDuration("STORAGE_LOCAL_MAX_AGE", 0),
+		publicBaseURL: strings.TrimRight(strings.TrimSpace(os.Getenv("STORAGE_LOCAL_PUBLIC_BASE_URL")), "/"),
+	}
+	if s.signingKey, err = loadSecret("STORAGE_LOCAL_SIGNING_KEY"); err != nil {
+		return nil, err
+	}
+	if v := strings.TrimSpace(os.Getenv("STORAGE_LOCAL_MAX_BYTES")); v != "" {
+		if s.maxBytes, err = parseByteSize(v); err != nil {
+			return nil, fmt.Errorf("invalid STORAGE_LOCAL_MAX_BYTES %q", v)
+		}
+	}
+	return s, nil
+}
+
+// parseByteSize 解析字节数，支持 KB/MB/GB/TB（1024 进制，可省略 B）后缀，如 "500MB"、"2G"。
+func parseByteSize(v string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+	mult := int64(1)
+	for i, unit := range []string{"K", "M", "G", "T"} {
+		if strings.HasSuffix(s, unit) {
+			s = strings.TrimSuffix(s, unit)
+			mult = int64(1) << (10 * (i + 1))
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid size")
+	}
+	return n * mult, nil
+}
+
+// path 把对象键映射为存储目录下的文件路径；拒绝越出目录的键。
+func (s *localStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(clean, "/"+localStoreTempPrefix) {
+		return "", errors.New("invalid key")
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *localStore) put(ctx context.Context, key, contentType string, body []byte, _ map[string]string) (string, error) {
+	target, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", err
+	}
+	// 先写临时文件再 rename，避免 GET 读到半个文件
+	tmp, err := os.CreateTemp(filepath.Dir(target), localStoreTempPrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.Write(body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.files++
+	s.bytes += int64(len(body))
+	s.mu.Unlock()
+	return s.publicBaseURL + localStoreRoute + "/" + s3EscapePath(key), nil
+}
+
+// presign 生成带过期时间与 HMAC 签名的 /stored 地址（需配置 STORAGE_LOCAL_SIGNING_KEY）。
+func (s *localStore) presign(key string, ttl time.Duration, now time.Time) (string, error) {
+	if !s.canPresign() {
+		return "", errors.New("local presigned URLs require STORAGE_LOCAL_SIGNING_KEY")
+	}
+	expires := strconv.FormatInt(now.Add(ttl).Unix(), 10)
+	return s.publicBaseURL + localStoreRoute + "/" + s3EscapePath(key) + "?expires=" + expires + "&signature=" + s.urlSignature(key, expires), nil
+}
+
+func (s *localStore) canPresign() bool { return s.signingKey.get() != "" }
+
+func (s *localStore) urlSignature(key, expires string) string {
+	return hex.EncodeToString(hmacSHA256([]byte(s.signingKey.get()), key+"\n"+expires))
+}
+
+// verifySignature 校验 /stored 请求的 expires 与 signature；未配置签名密钥时不校验。
+func (s *localStore) verifySignature(key, expires, signature string, now time.Time) bool {
+	if !s.canPresign() {
+		return true
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.urlSignature(key, expires)))
+}
+
+// describe 与 stats 会返回给客户端（store=true 的响应与 /health），因此不包含本地目录路径。
+func (s *localStore) describe() gin.H {
+	return gin.H{"type": "local"}
+}
+
+func (s *localStore) stats() gin.H {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := gin.H{
+		"type":      "local",
+		"files":     s.files,
+		"bytes":     s.bytes,
+		"removed":   s.removed,
+		"max_age":   s.maxAge.String(),
+		"max_bytes": s.maxBytes,
+	}
+	if !s.lastSweep.IsZero() {
+		out["last_sweep"] = s.lastSweep.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+// janitor 周期性执行清理，随进程运行。
+func (s *localStore) janitor() {
+	s.sweep()
+	ticker := time.NewTicker(localStoreJanitorInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.sweep()
+	}
+}
+
+type localStoredFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// sweep 删除超过 maxAge 的文件（以及遗留的临时文件），再按修改时间从旧到新删除直到总大小不超过 maxBytes。
+func (s *localStore) sweep() {
+	now := time.Now()
+	var files []localStoredFile
+	var total int64
+	var removed int64
+	_ = filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		age := now.Sub(info.ModTime())
+		// 临时文件超过一个上传超时仍存在说明写入中断
+		if strings.HasPrefix(d.Name(), localStoreTempPrefix) {
+			if age > storageUploadTimeout {
+				_ = os.Remove(p)
+			}
+			return nil
+		}
+		if s.maxAge > 0 && age > s.maxAge {
+			if s.remove(p) {
+				removed++
+			}
+			return nil
+		}
+		files = append(files, localStoredFile{path: p, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+
+	if s.maxBytes > 0 && total > s.maxBytes {
+		sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+		for len(files) > 0 && total > s.maxBytes {
+			if s.remove(files[0].path) {
+				removed++
+				total -= files[0].size
+			}
+			files = files[1:]
+		}
+	}
+	if removed > 0 {
+		log.Printf("storage: janitor removed %d file(s) from %s", removed, s.dir)
+	}
+
+	s.mu.Lock()
+	s.files = len(files)
+	s.bytes = total
+	s.removed += removed
+	s.lastSweep = now
+	s.mu.Unlock()
+}
+
+// remove 删除文件并清理因此变空的上级目录（不会删除存储根目录）。
+func (s *localStore) remove(p string) bool {
+	if err := os.Remove(p); err != nil {
+		return false
+	}
+	for dir := filepath.Dir(p); dir != s.dir && strings.HasPrefix(dir, s.dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return true
+}
+
+// registerStoredRoutes 在配置了本地存储时注册 GET /stored/<key>，按对象键取回已保存的结果；
+// 配置 STORAGE_LOCAL_SIGNING_KEY 时只接受 presign 生成的签名地址。
+func registerStoredRoutes(r *gin.Engine) {
+	s, ok := captureStores["local"].(*localStore)
+	if !ok {
+		return
+	}
+	r.GET(localStoreRoute+"/*key", func(c *gin.Context) {
+		key := strings.TrimPrefix(c.Param("key"), "/")
+		if !s.verifySignature(key, c.Query("expires"), c.Query("signature"), time.Now()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "invalid or expired signature", "code": "SIGNATURE_INVALID"})
+			return
+		}
+		p, err := s.path(key)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "stored object not found", "code": "NOT_FOUND"})
+			return
+		}
+		info, err := os.Stat(p)
+		if err != nil || info.IsDir() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "stored object not found", "code": "NOT_FOUND"})
+			return
+		}
+		// Content-Type 由扩展名（或内容嗅探）决定
+		c.File(p)
+	})
+}
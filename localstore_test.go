@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"1024", 1024},
+		{"500MB", 500 << 20},
+		{"500mb", 500 << 20},
+		{"2G", 2 << 30},
+		{"2GiB", 2 << 30},
+		{" 10 KB ", 10 << 10},
+		{"1T", 1 << 40},
+		{"7B", 7},
+	}
+	for _, tt := range tests {
+		got, err := parseByteSize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "MB", "-1", "1.5G", "10X", "ten"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("parseByteSize(%q) succeeded, want error", in)
+		}
+	}
+}
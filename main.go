@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -35,6 +37,9 @@ const (
 	defaultTimeoutSec  = 30
 	maxTimeoutSec      = 120
 
+	// maxHTMLBytes 为 html 参数（直接渲染 HTML 字符串）允许的最大长度。
+	maxHTMLBytes = 5 << 20
+
 	// maxAutoViewportHeight 用于“未显式设置 height + 元素截图”时自动把视口高度扩展到页面总高度。
 	// 该值是安全阈值，避免极端超长页面导致过高的内存/时间开销。
 	maxAutoViewportHeight = 30000
@@ -131,7 +136,7 @@ type ScreenshotRequest struct {
 	URL         string            `json:"url"`
 	Selector    string            `json:"selector"`
 	Width       int               `json:"width"`
-	Height      int               `json:"height"`
+	Height      heightValue       `json:"height"`
 	Format      string            `json:"format"`
 	Quality     int               `json:"quality"`
 	WaitTime    int               `json:"wait_time"`
@@ -145,6 +150,197 @@ type ScreenshotRequest struct {
 	Timeout     int               `json:"timeout"`
 	Clip        *Clip             `json:"clip"`
 	Transparent bool              `json:"transparent"`
+
+	// Trace 为 true 时在加载期间录制 Chrome trace，并以 JSON 返回（可选附带截图）。
+	Trace           bool     `json:"trace"`
+	TraceCategories []string `json:"trace_categories"`
+	TraceScreenshot bool     `json:"trace_screenshot"`
+
+	// ElementInfo 为 true 且设置了 selector 时，返回命中元素的 tag/属性/box/可见性（X-Element-Info 响应头）。
+	ElementInfo bool `json:"element_info"`
+
+	// HideOverlapping 为 true 且设置了 selector 时，截图前自动隐藏遮挡目标元素的浮层（弹窗、toast、吸顶栏等）。
+	HideOverlapping bool `json:"hide_overlapping"`
+
+	// Deterministic 为 true 时固定 Date/Math.random，并在截图前冻结动画、定时器与媒体播放，
+	// 使动态页面的渲染结果尽量稳定。
+	Deterministic bool `json:"deterministic"`
+
+	// DisableAnimations 为 true 时停止 CSS 动画与过渡（注入样式并把动画播放速率设为 0），
+	// 避免轮播图、加载动画导致每次截图结果不同。
+	DisableAnimations bool `json:"disable_animations"`
+
+	// ColorScheme 模拟 prefers-color-scheme 媒体查询：dark | light，为空时使用浏览器默认（light）。
+	ColorScheme string `json:"color_scheme"`
+
+	// MediaBehavior 控制截图前 <video>/<audio> 的处理方式：pause | hide | poster。
+	MediaBehavior string `json:"media_behavior"`
+
+	// Engine 为截图使用的浏览器：chromium（默认）或 firefox（实验性，经 FIREFOX_BIDI_URL 的 WebDriver BiDi
+	// 或 Playwright server，仅支持部分参数）。
+	Engine string `json:"engine"`
+
+	// WaitForExpression 在导航完成后反复求值该 JavaScript 表达式，直到结果为 truthy（如 window.appReady === true）。
+	// 需服务端开启 ALLOW_INJECT_JS。
+	WaitForExpression string `json:"wait_for_expression"`
+
+	// WaitUntil 导航完成的判定：load（默认）| domcontentloaded | networkidle0 | networkidle2（进行中请求数不超过 0/2 持续 500ms）。
+	WaitUntil string `json:"wait_until"`
+
+	// WaitForCanvas 等待该 selector 匹配的 canvas 出现非空白像素；CanvasPreserveBuffer 强制 WebGL preserveDrawingBuffer。
+	WaitForCanvas        string `json:"wait_for_canvas"`
+	CanvasPreserveBuffer bool   `json:"canvas_preserve_buffer"`
+
+	// RequiresWebGL 为 true 时，若上游浏览器无 WebGL（探测结果缓存 5 分钟）则直接失败，而不是返回黑色画面。
+	RequiresWebGL bool `json:"requires_webgl"`
+
+	// InjectFonts 为 true 时在导航前注册 FONTS_DIR 中的字体；FontReport 为 true 时在 X-Font-Report 中返回实际使用的字体。
+	InjectFonts bool `json:"inject_fonts"`
+	FontReport  bool `json:"font_report"`
+
+	// Emoji 为 twemoji 时把原生 emoji 替换为 Twemoji SVG，使不同宿主系统上的渲染一致；native（默认）保持不变。
+	Emoji string `json:"emoji"`
+
+	// PseudoLocale 为 true 时截图前把页面文本转换为伪本地化字符串（重音字符 + 约 30% 扩展），用于 i18n 布局检查。
+	PseudoLocale bool `json:"pseudo_locale"`
+
+	// BestEffort 为 true 时，若剩余时间预算不足以完成导航/等待，则跳过剩余等待直接截图（X-Budget-Exceeded 响应头列出被跳过的阶段），
+	// 否则返回 504 + BUDGET_EXCEEDED。
+	BestEffort bool `json:"best_effort"`
+
+	// TextFormat/TextWidth 仅用于 /text：输出格式（plain | ansi | json）与折行宽度。
+	TextFormat string `json:"text_format"`
+	TextWidth  int    `json:"text_width"`
+
+	// Favicon 仅用于 /metadata：为 true 时由服务端拉取最佳图标并以 base64 返回。
+	Favicon bool `json:"favicon"`
+
+	// IconSize 仅用于 /favicon：输出边长（像素），图标会被栅格化并缩放为 PNG；0 表示返回原始图标。
+	IconSize int `json:"size"`
+
+	// Mode 预设模式：thumbnail（低延迟缩略图）、archive（高保真归档）、print（打印效果）。预设会覆盖相关参数。
+	Mode string `json:"mode"`
+
+	// PrintDPI / PrintOutput 仅用于 mode=print：图片分辨率（默认 150）与输出类型（image 或 pdf）。
+	PrintDPI    int    `json:"print_dpi"`
+	PrintOutput string `json:"print_output"`
+
+	// SessionID 仅用于截图：使用 /prewarm 保留的已预热 tab（单次使用，截图后关闭）。
+	SessionID string `json:"session_id"`
+
+	// PreviewWidth 用于 /preview 与 mode=thumbnail：缩略图宽度（像素，按视口等比缩放）。
+	PreviewWidth int `json:"preview_width"`
+
+	// Trim 为 true 时自动裁掉截图四周与背景同色的边距（以左上角像素为背景色）：
+	// TrimTolerance 为每个通道允许的色差（默认 8），TrimMax 为每边最多裁掉的像素数（0 不限制）。
+	Trim          bool `json:"trim"`
+	TrimTolerance int  `json:"trim_tolerance"`
+	TrimMax       int  `json:"trim_max"`
+
+	// Grayscale / Brightness / Contrast / Sepia 为截图后的颜色滤镜（用于生成弱化的背景图/占位图）：
+	// brightness、contrast 为倍数（1 为原图，0 表示不调整），sepia 为强度 0-1。
+	Grayscale  bool    `json:"grayscale"`
+	Brightness float64 `json:"brightness"`
+	Contrast   float64 `json:"contrast"`
+	Sepia      float64 `json:"sepia"`
+
+	// Palette 为 true 时计算截图的主色与调色板（X-Dominant-Color / X-Palette），PaletteSize 为调色板颜色数（默认 5，最多 16）。
+	Palette     bool `json:"palette"`
+	PaletteSize int  `json:"palette_size"`
+
+	// PaginateHeight 配合 full_page：把整页按该高度（CSS px）切分为多张图片；PaginateOverlap 为相邻图片的重叠高度；
+	// PaginateOutput 为 zip（默认）或 pdf（每页一张图片，纸张/边距取自 pdf 参数）。
+	PaginateHeight  int    `json:"paginate_height"`
+	PaginateOverlap int    `json:"paginate_overlap"`
+	PaginateOutput  string `json:"paginate_output"`
+
+	// Paginate 为列表翻页截图：反复点击“下一页”控件逐页截图，返回包含各页图片与 pages.json 的 ZIP。
+	// GET 方式传 JSON 字符串。
+	Paginate *PaginateOptions `json:"paginate"`
+
+	// Store 为 true 时把结果上传到存储后端（S3 / GCS / 本地目录），响应返回对象地址与元数据 JSON 而不是图片本身；
+	// Storage 指定后端名称（s3、gcs、local，为空时为默认后端），非空时隐含 store=true。
+	Store   bool   `json:"store"`
+	Storage string `json:"storage"`
+
+	// Tags 为自由标签（最多 10 个），随存储对象写入元数据（tags）并可用于对象键模板 {tag}。GET 方式重复传参。
+	Tags []string `json:"tags"`
+
+	// RecordCDP 为 true 时把本次请求与浏览器之间的 CDP 消息（脱敏后）录制到 CDP_RECORDING_DIR，
+	// 录制 ID 通过 X-CDP-Recording 返回，可用 POST /debug/cdp/:id/replay 离线回放。
+	RecordCDP bool `json:"record_cdp"`
+
+	// Referer 通过 Page.navigate 的 referrer 参数设置顶层导航的 Referer（不影响子资源请求）；
+	// SpoofReferrer 为 true 时同时把页面中的 document.referrer 固定为该值。
+	Referer       string `json:"referer"`
+	SpoofReferrer bool   `json:"spoof_referrer"`
+
+	// ResponseType 为 binary（默认）、url 或 json：url 时上传到存储后端并只返回限时签名地址（JSON），
+	// 避免大图经过 API 链路；json 返回 base64 内容与页面信息，供无法处理二进制响应体的网关使用。
+	ResponseType string `json:"response_type"`
+
+	// Method 为 POST 时以 Body（Content-Type 为 ContentType，默认表单编码）作为首次导航的请求体，
+	// 用于只能通过表单提交到达的搜索结果、报表等页面。
+	Method      string `json:"method"`
+	Body        string `json:"body"`
+	ContentType string `json:"content_type"`
+
+	// Navigate 为多步导航：在同一 tab 中依次访问各地址（如先访问登录跳转地址，再访问深层链接），
+	// 只截取最后一个页面（最后一项即截图目标，不能与 url 同时使用）；NavigateWait 为每个中间站加载后的额外等待（毫秒）。
+	Navigate     []string `json:"navigate"`
+	NavigateWait int      `json:"navigate_wait"`
+
+	// Cookies 在导航前通过 Network.setCookies 写入（name/value/domain/path/secure/httpOnly/expires），
+	// 用于截取依赖会话 cookie 的登录后页面。GET 方式传 JSON 数组字符串。
+	Cookies []Cookie `json:"cookies"`
+
+	// ExpandSelectors 在页面加载后点击命中的元素（手风琴标题、“显示更多”按钮等，<details> 直接展开），
+	// 使折叠内容可见；ExpandWait 为每个选择器处理后的稳定等待（毫秒，默认 300）。GET 方式重复传参。
+	ExpandSelectors []string `json:"expand_selectors"`
+	ExpandWait      int      `json:"expand_wait"`
+
+	// HideSelectors 在截图前注入 display:none !important 的样式，去掉聊天窗口、弹窗等干扰元素。GET 方式重复传参。
+	HideSelectors []string `json:"hide_selectors"`
+
+	// InjectCSS 在截图前追加到页面中的自定义样式（隐藏吸顶导航、固定宽度等），无需自行代理页面。
+	InjectCSS string `json:"inject_css"`
+
+	// InjectJSBefore 在每个文档的页面脚本之前执行（Page.addScriptToEvaluateOnNewDocument），InjectJSAfter 在加载与等待之后、
+	// 截图之前执行（返回 Promise 时等待完成）。需服务端开启 ALLOW_INJECT_JS。
+	InjectJSBefore string `json:"inject_js_before"`
+	InjectJSAfter  string `json:"inject_js_after"`
+
+	// LocalStorage / SessionStorage 在页面脚本执行前写入目标源的 localStorage / sessionStorage
+	// （SPA 常把登录令牌与功能开关存放在这里）。GET 方式传 JSON 对象字符串。
+	LocalStorage   map[string]string `json:"local_storage"`
+	SessionStorage map[string]string `json:"session_storage"`
+
+	// BlockAds 为 true 时按 EasyList 规则（内置精简列表或 ADBLOCK_LISTS）通过请求拦截屏蔽广告与追踪请求。
+	BlockAds bool `json:"block_ads"`
+
+	// BlockURLs 通过 Network.setBlockedURLs 屏蔽匹配的请求（* 为通配符），用于去掉统计与追踪脚本等。GET 方式重复传参。
+	BlockURLs []string `json:"block_urls"`
+
+	// Assertions / ScreenshotOnFailure 仅用于 POST /assert：页面加载后求值的断言列表，以及有断言失败时是否附带截图。
+	Assertions          []Assertion `json:"assertions"`
+	ScreenshotOnFailure bool        `json:"screenshot_on_failure"`
+
+	// PDF 仅用于 /pdf 与 paginate_output=pdf：纸张、边距、页码范围、页眉页脚等打印参数。
+	PDF *PDFOptions `json:"pdf"`
+
+	// HTML 直接渲染请求体中的 HTML 字符串（与 url 互斥，仅 POST）。
+	// 页面以 about:blank 为基准地址，相对路径资源需在 HTML 中使用 <base href> 或绝对地址。
+	HTML string `json:"html"`
+
+	// Strict 为 true 时拒绝未知参数，且预设/裁剪需要改变显式传入的参数时报错（未传时取 STRICT_VALIDATION）。
+	Strict bool `json:"strict"`
+
+	// provided 记录请求中显式出现的参数名，用于 strict 校验与站点配置。
+	provided map[string]bool
+	// siteProfile 为已应用的站点配置名（X-Site-Profile）。
+	siteProfile string
+	// output 为 response_type=json 在截图过程中收集的信息。
+	output *outputInfo
 }
 
 func (r *ScreenshotRequest) applyDefaults() {
@@ -170,20 +366,68 @@ func (r *ScreenshotRequest) applyDefaults() {
 }
 
 func (r *ScreenshotRequest) validate() error {
-	if r.URL == "" {
-		return errors.New("url is required")
+	if err := r.validateNavigate(); err != nil {
+		return err
+	}
+	if r.HTML != "" {
+		if r.URL != "" {
+			return errors.New("url and html are mutually exclusive")
+		}
+		if len(r.HTML) > maxHTMLBytes {
+			return fmt.Errorf("html must be at most %d bytes", maxHTMLBytes)
+		}
+	} else {
+		if r.URL == "" {
+			return errors.New("url or html is required")
+		}
+
+		switch urlScheme(r.URL) {
+		case "data":
+			if err := validateDataURL(r.URL); err != nil {
+				return err
+			}
+		case "file":
+			if err := validateFileURL(r.URL); err != nil {
+				return err
+			}
+		default:
+			// 支持 IRI：Unicode 主机名转为 punycode，路径/查询中的非 ASCII 字符做百分号编码
+			normalized, err := normalizeIRI(r.URL)
+			if err != nil {
+				return errors.New("url must be a valid http/https URL")
+			}
+			r.URL = normalized
+
+			parsedURL, err := url.ParseRequestURI(r.URL)
+			if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+				return errors.New("url must be a valid http/https URL")
+			}
+			if hostPolicy != nil {
+				if err := hostPolicy.checkURL(r.URL); err != nil {
+					return err
+				}
+			}
+		}
 	}
 
-	parsedURL, err := url.ParseRequestURI(r.URL)
-	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
-		return errors.New("url must be a valid http/https URL")
+	// 站点配置在预设之前应用：mode 预设仍优先于站点默认值
+	if err := r.applySiteProfile(); err != nil {
+		return err
+	}
+	if err := r.applyMode(); err != nil {
+		return err
 	}
 
 	if r.Width < 100 || r.Width > 4096 {
 		return errors.New("width must be between 100 and 4096")
 	}
 	// height 允许为 0：仅在“元素截图且未设置 height”时使用，后续会自动扩展为页面总高度。
-	if r.Height != 0 {
+	// height=auto：截图前把视口高度调整为内容高度，不能与 full_page 同时使用。
+	if r.Height == heightAuto {
+		if r.FullPage {
+			return errors.New("height=auto cannot be combined with full_page")
+		}
+	} else if r.Height != 0 {
 		if r.Height < 100 || r.Height > 10000 {
 			return errors.New("height must be between 100 and 10000")
 		}
@@ -200,6 +444,84 @@ func (r *ScreenshotRequest) validate() error {
 	if r.Quality < 1 || r.Quality > 100 {
 		return errors.New("quality must be between 1 and 100")
 	}
+	if err := r.presetConflict("quality", r.Format == "png", "quality is not used with png format"); err != nil {
+		return err
+	}
+
+	if err := r.validateTrim(); err != nil {
+		return err
+	}
+	if err := r.validatePaginate(); err != nil {
+		return err
+	}
+	if err := r.validateListing(); err != nil {
+		return err
+	}
+	if err := r.validateFilters(); err != nil {
+		return err
+	}
+	if err := r.validatePalette(); err != nil {
+		return err
+	}
+	if err := r.validateReferer(); err != nil {
+		return err
+	}
+	if err := r.validateMethod(); err != nil {
+		return err
+	}
+	if err := r.validateCookies(); err != nil {
+		return err
+	}
+	if err := r.validateExpand(); err != nil {
+		return err
+	}
+	if err := r.validateWebStorage(); err != nil {
+		return err
+	}
+	if err := r.validateHideSelectors(); err != nil {
+		return err
+	}
+	if err := r.validateInjectCSS(); err != nil {
+		return err
+	}
+	if err := r.validateInjectJS(); err != nil {
+		return err
+	}
+	if err := r.validateBlockURLs(); err != nil {
+		return err
+	}
+	if err := r.validateTags(); err != nil {
+		return err
+	}
+	if err := r.validateColorScheme(); err != nil {
+		return err
+	}
+	if err := r.validateWaitUntil(); err != nil {
+		return err
+	}
+	if err := r.validateEngine(); err != nil {
+		return err
+	}
+	if r.RecordCDP {
+		if cdpRecordingDir() == "" {
+			return errors.New("record_cdp requires CDP_RECORDING_DIR")
+		}
+		if r.SessionID != "" {
+			return errors.New("record_cdp cannot be combined with session_id")
+		}
+	}
+	r.Storage = strings.ToLower(strings.TrimSpace(r.Storage))
+	if r.Storage != "" {
+		r.Store = true
+	}
+	if err := r.validateResponseType(); err != nil {
+		return err
+	}
+	if r.Store {
+		if _, err := lookupStore(r.Storage); err != nil {
+			return err
+		}
+	}
 
 	if r.Timeout < 1 || r.Timeout > maxTimeoutSec {
 		return fmt.Errorf("timeout must be between 1 and %d seconds", maxTimeoutSec)
@@ -222,6 +544,26 @@ func (r *ScreenshotRequest) validate() error {
 		}
 	}
 
+	if r.ElementInfo && r.Selector == "" {
+		return errors.New("element_info requires selector")
+	}
+	if r.HideOverlapping && r.Selector == "" {
+		return errors.New("hide_overlapping requires selector")
+	}
+
+	r.MediaBehavior = strings.ToLower(strings.TrimSpace(r.MediaBehavior))
+	if !isValidMediaBehavior(r.MediaBehavior) {
+		return errors.New("media_behavior must be one of: pause, hide, poster")
+	}
+
+	r.Emoji = strings.ToLower(strings.TrimSpace(r.Emoji))
+	if !isValidEmojiMode(r.Emoji) {
+		return errors.New("emoji must be one of: native, twemoji")
+	}
+	if r.Emoji == emojiTwemoji && twemojiDir == "" {
+		return errTwemojiUnavailable
+	}
+
 	if r.Transparent && r.Format == "jpeg" {
 		return errors.New("transparent is not supported with jpeg format, use png or webp")
 	}
@@ -274,12 +616,20 @@ func parseRequestFromGET(c *gin.Context) (ScreenshotRequest, error) {
 	}
 
 	var err error
+	req.Strict, err = parseBoolQuery(c, "strict", strictByDefault())
+	if err != nil {
+		return req, err
+	}
+	req.provided, err = checkQueryParams(c, req.Strict)
+	if err != nil {
+		return req, err
+	}
 	req.Width, err = parseIntQuery(c, "width", defaultWidth)
 	if err != nil {
 		return req, err
 	}
 	// height：GET 场景下如果未提供，则保持为 0（元素截图会在截图前自动扩展总高度；非元素截图会在 applyDefaults 中补默认值）。
-	req.Height, err = parseIntQuery(c, "height", 0)
+	req.Height, err = parseHeightQuery(c.Query("height"))
 	if err != nil {
 		return req, err
 	}
@@ -316,8 +666,193 @@ func parseRequestFromGET(c *gin.Context) (ScreenshotRequest, error) {
 		return req, err
 	}
 
+	req.Trace, err = parseBoolQuery(c, "trace", false)
+	if err != nil {
+		return req, err
+	}
+	req.TraceScreenshot, err = parseBoolQuery(c, "trace_screenshot", false)
+	if err != nil {
+		return req, err
+	}
+	req.TraceCategories = parseTraceCategories(c.Query("trace_categories"))
+	req.ElementInfo, err = parseBoolQuery(c, "element_info", false)
+	if err != nil {
+		return req, err
+	}
+	req.HideOverlapping, err = parseBoolQuery(c, "hide_overlapping", false)
+	if err != nil {
+		return req, err
+	}
+	req.Deterministic, err = parseBoolQuery(c, "deterministic", false)
+	if err != nil {
+		return req, err
+	}
+	req.DisableAnimations, err = parseBoolQuery(c, "disable_animations", false)
+	if err != nil {
+		return req, err
+	}
+	req.ColorScheme = c.Query("color_scheme")
+	req.MediaBehavior = c.Query("media_behavior")
+	req.WaitUntil = c.Query("wait_until")
+	req.Engine = c.Query("engine")
+	req.WaitForExpression = c.Query("wait_for_expression")
+	req.WaitForCanvas = c.Query("wait_for_canvas")
+	req.CanvasPreserveBuffer, err = parseBoolQuery(c, "canvas_preserve_buffer", false)
+	if err != nil {
+		return req, err
+	}
+	req.RequiresWebGL, err = parseBoolQuery(c, "requires_webgl", false)
+	if err != nil {
+		return req, err
+	}
+	req.InjectFonts, err = parseBoolQuery(c, "inject_fonts", false)
+	if err != nil {
+		return req, err
+	}
+	req.FontReport, err = parseBoolQuery(c, "font_report", false)
+	if err != nil {
+		return req, err
+	}
+	req.Emoji = c.Query("emoji")
+	req.PseudoLocale, err = parseBoolQuery(c, "pseudo_locale", false)
+	if err != nil {
+		return req, err
+	}
+	req.BestEffort, err = parseBoolQuery(c, "best_effort", false)
+	if err != nil {
+		return req, err
+	}
+	req.TextFormat = c.Query("text_format")
+	req.TextWidth, err = parseIntQuery(c, "text_width", 0)
+	if err != nil {
+		return req, err
+	}
+	req.Favicon, err = parseBoolQuery(c, "favicon", false)
+	if err != nil {
+		return req, err
+	}
+	req.Mode = c.Query("mode")
+	req.PrintDPI, err = parseIntQuery(c, "print_dpi", 0)
+	if err != nil {
+		return req, err
+	}
+	req.PrintOutput = c.Query("print_output")
+	req.SessionID = c.Query("session_id")
+	req.PreviewWidth, err = parseIntQuery(c, "preview_width", 0)
+	if err != nil {
+		return req, err
+	}
+	req.IconSize, err = parseIntQuery(c, "size", 0)
+	if err != nil {
+		return req, err
+	}
+	req.Trim, err = parseBoolQuery(c, "trim", false)
+	if err != nil {
+		return req, err
+	}
+	req.TrimTolerance, err = parseIntQuery(c, "trim_tolerance", 0)
+	if err != nil {
+		return req, err
+	}
+	req.TrimMax, err = parseIntQuery(c, "trim_max", 0)
+	if err != nil {
+		return req, err
+	}
+	req.Grayscale, err = parseBoolQuery(c, "grayscale", false)
+	if err != nil {
+		return req, err
+	}
+	req.Brightness, err = parseFloatQuery(c, "brightness", 0)
+	if err != nil {
+		return req, err
+	}
+	req.Contrast, err = parseFloatQuery(c, "contrast", 0)
+	if err != nil {
+		return req, err
+	}
+	req.Sepia, err = parseFloatQuery(c, "sepia", 0)
+	if err != nil {
+		return req, err
+	}
+	req.Palette, err = parseBoolQuery(c, "palette", false)
+	if err != nil {
+		return req, err
+	}
+	req.PaletteSize, err = parseIntQuery(c, "palette_size", 0)
+	if err != nil {
+		return req, err
+	}
+	req.PaginateHeight, err = parseIntQuery(c, "paginate_height", 0)
+	if err != nil {
+		return req, err
+	}
+	req.PaginateOverlap, err = parseIntQuery(c, "paginate_overlap", 0)
+	if err != nil {
+		return req, err
+	}
+	req.PaginateOutput = c.Query("paginate_output")
+	req.Store, err = parseBoolQuery(c, "store", false)
+	if err != nil {
+		return req, err
+	}
+	req.Storage = c.Query("storage")
+	req.Tags = c.QueryArray("tags")
+	req.RecordCDP, err = parseBoolQuery(c, "record_cdp", false)
+	if err != nil {
+		return req, err
+	}
+
+	req.Referer = c.Query("referer")
+	req.ResponseType = c.Query("response_type")
+	req.Method = c.Query("method")
+	req.Body = c.Query("body")
+	req.ContentType = c.Query("content_type")
+	req.Navigate = c.QueryArray("navigate")
+	req.NavigateWait, err = parseIntQuery(c, "navigate_wait", 0)
+	if err != nil {
+		return req, err
+	}
+	req.BlockURLs = c.QueryArray("block_urls")
+	req.BlockAds, err = parseBoolQuery(c, "block_ads", false)
+	if err != nil {
+		return req, err
+	}
+	req.HideSelectors = c.QueryArray("hide_selectors")
+	req.InjectCSS = c.Query("inject_css")
+	req.InjectJSBefore = c.Query("inject_js_before")
+	req.InjectJSAfter = c.Query("inject_js_after")
+	req.ExpandSelectors = c.QueryArray("expand_selectors")
+	req.ExpandWait, err = parseIntQuery(c, "expand_wait", 0)
+	if err != nil {
+		return req, err
+	}
+	req.SpoofReferrer, err = parseBoolQuery(c, "spoof_referrer", false)
+	if err != nil {
+		return req, err
+	}
+
 	req.UserAgent = c.Query("user_agent")
 
+	if raw := c.Query("paginate"); raw != "" {
+		req.Paginate = &PaginateOptions{}
+		if err := json.Unmarshal([]byte(raw), req.Paginate); err != nil {
+			return req, errors.New("paginate must be a valid JSON object")
+		}
+	}
+
+	for name, dst := range map[string]*map[string]string{"local_storage": &req.LocalStorage, "session_storage": &req.SessionStorage} {
+		if raw := c.Query(name); raw != "" {
+			if err := json.Unmarshal([]byte(raw), dst); err != nil {
+				return req, fmt.Errorf("%s must be a valid JSON object", name)
+			}
+		}
+	}
+	if raw := c.Query("cookies"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &req.Cookies); err != nil {
+			return req, errors.New("cookies must be a valid JSON array")
+		}
+	}
+
 	headersRaw := c.Query("headers")
 	if headersRaw != "" {
 		headers := map[string]string{}
@@ -336,9 +871,14 @@ func parseRequest(c *gin.Context) (ScreenshotRequest, error) {
 	}
 
 	var req ScreenshotRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	body, err := c.GetRawData()
+	if err != nil {
 		return req, errors.New("invalid JSON body")
 	}
+	req.provided, req.Strict, err = decodeRequestBody(body, &req)
+	if err != nil {
+		return req, err
+	}
 	req.applyDefaults()
 	return req, nil
 }
@@ -762,27 +1302,27 @@ func resolveWSEndpoint(ctx context.Context) (wsURL string, configured bool, err
 				return n, true, nil
 			}
 
-		// 对于类似 browserless 的 ws connect 路由（例如 /chromium），它本身就是可连接 endpoint，
-		// 不应再拼接 /json/version（否则会变成 /chromium/json/version 并导致 404）。
-		// browserless 的代理模式使用根路径（无路径或 /），也应该直接使用
-		if p != "" && p != "/" {
-			log.Printf("resolveWSEndpoint: using CHROME_WS_ENDPOINT (direct ws with path): %s", ws)
-			n := normalizeWSEndpointForDial(ws)
-			if n != ws {
-				log.Printf("resolveWSEndpoint: warning: CHROME_WS_ENDPOINT uses non-dialable host, rewritten to %s", n)
+			// 对于类似 browserless 的 ws connect 路由（例如 /chromium），它本身就是可连接 endpoint，
+			// 不应再拼接 /json/version（否则会变成 /chromium/json/version 并导致 404）。
+			// browserless 的代理模式使用根路径（无路径或 /），也应该直接使用
+			if p != "" && p != "/" {
+				log.Printf("resolveWSEndpoint: using CHROME_WS_ENDPOINT (direct ws with path): %s", ws)
+				n := normalizeWSEndpointForDial(ws)
+				if n != ws {
+					log.Printf("resolveWSEndpoint: warning: CHROME_WS_ENDPOINT uses non-dialable host, rewritten to %s", n)
+				}
+				return n, true, nil
 			}
-			return n, true, nil
-		}
-		
-		// browserless 代理模式：直接使用根路径 WebSocket 端点
-		if p == "" || p == "/" {
-			log.Printf("resolveWSEndpoint: using CHROME_WS_ENDPOINT (browserless proxy mode, path=%q): %s", p, ws)
-			n := normalizeWSEndpointForDial(ws)
-			if n != ws {
-				log.Printf("resolveWSEndpoint: warning: CHROME_WS_ENDPOINT uses non-dialable host, rewritten to %s", n)
+
+			// browserless 代理模式：直接使用根路径 WebSocket 端点
+			if p == "" || p == "/" {
+				log.Printf("resolveWSEndpoint: using CHROME_WS_ENDPOINT (browserless proxy mode, path=%q): %s", p, ws)
+				n := normalizeWSEndpointForDial(ws)
+				if n != ws {
+					log.Printf("resolveWSEndpoint: warning: CHROME_WS_ENDPOINT uses non-dialable host, rewritten to %s", n)
+				}
+				return n, true, nil
 			}
-			return n, true, nil
-		}
 		}
 
 		httpBase, convErr := httpBaseFromWSEndpoint(ws)
@@ -799,23 +1339,8 @@ func resolveWSEndpoint(ctx context.Context) (wsURL string, configured bool, err
 		return resolved, true, nil
 	}
 
-	httpBaseRaw := getBrowserlessHTTPURL()
-	if httpBaseRaw == "" {
-		return "", false, errors.New("browserless endpoint is not configured")
-	}
-
-	httpBase, err := parseBrowserlessHTTPBase(httpBaseRaw)
-	if err != nil {
-		return "", true, err
-	}
-
-	resolved, err := resolveWSEndpointViaJSONVersion(ctx, httpBase)
-	if err != nil {
-		return "", true, err
-	}
-	resolved = normalizeWSEndpointForDial(resolved)
-	log.Printf("resolveWSEndpoint: BROWSERLESS_HTTP_URL=%s resolved via /json/version -> %s", httpBaseRaw, resolved)
-	return resolved, true, nil
+	// BROWSERLESS_HTTP_URL 可为逗号分隔的多个地址，由 upstreams 负载均衡并跟踪各地址健康状态。
+	return upstreams.resolve(ctx)
 }
 
 func isTimeoutErr(err error) bool {
@@ -847,262 +1372,782 @@ func captureFormat(format string) page.CaptureScreenshotFormat {
 	}
 }
 
-func screenshotHandler() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		req, err := parseRequest(c)
+// chromeSession 表示一次请求所使用的远程 Chrome tab 及其解析后的 endpoint。
+type chromeSession struct {
+	ctx    context.Context
+	wsURL  string
+	cancel func()
+}
+
+// dialChrome 在 taskCtx 上触发与远程 Chrome 的首次连接，最多等待 remoteChromeDialTimeout。
+// chromedp 会把远程连接的生命周期绑定到首次 Run 所用的 context，因此不能用带超时的子 context 来 dial
+// （子 context 结束即断开整个会话），这里改用计时器单独控制 dial 超时；超时后由调用方取消 taskCtx。
+func dialChrome(taskCtx context.Context) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- chromedp.Run(taskCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+			// 只读操作，用于触发与远程 Chrome 的首次连接。
+			_, err := page.GetFrameTree().Do(ctx)
+			return err
+		}))
+	}()
+	timer := time.NewTimer(remoteChromeDialTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-errc:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("chrome dial: %w", context.DeadlineExceeded)
+	}
+}
+
+// openChromeSession 解析 endpoint、创建远程 allocator/tab 并完成 dial 探测。
+// 失败时已写入错误响应并返回 nil；成功时调用方需 defer sess.cancel()。
+func openChromeSession(c *gin.Context, overallCtx context.Context, logPrefix string) *chromeSession {
+	// 测试用故障注入（FAULT_INJECTION=true）：dial 类故障不连接上游、不计入熔断
+	fault, err := injectedFault(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil
+	}
+	if fault != nil && fault.dial != "" {
+		respondDialError(c, fault.dialFault(overallCtx), "")
+		return nil
+	}
+
+	// CDP 回放（调试用）：连接进程内的回放服务器，不经过熔断、负载均衡与连接池
+	if replay := cdpReplayFrom(c); replay != nil {
+		sess, err := dialRemoteSession(overallCtx, replay.wsURL)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+			respondDialError(c, err, replay.wsURL)
+			return nil
 		}
+		return guardSession(c, sess)
+	}
+	// CDP 录制需要独占连接（chromedp 按连接记录消息），因此不使用连接池
+	rec := cdpRecorderFrom(c)
 
-		req.applyDefaults()
-		if err := req.validate(); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+	// 熔断打开时直接失败，不再 dial 已知不可用的上游
+	if upstreamBreaker != nil {
+		if retryAfter, ok := upstreamBreaker.allow(); !ok {
+			respondCircuitOpen(c, retryAfter)
+			return nil
 		}
+	}
 
-		// 视口尺寸：req.Height 允许为 0（元素截图且未设置 height）。此时先用默认高度完成加载，
-		// 截图前再自动扩展为页面总高度。
-		viewportWidth := int64(req.Width)
-		viewportHeight := int64(req.Height)
-		autoExpandViewportHeight := req.Selector != "" && req.Height == 0
-		if viewportHeight == 0 {
-			viewportHeight = defaultHeight
+	wsURL, configured, err := resolveWSEndpoint(overallCtx)
+	if !configured {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "browserless/chrome endpoint is not configured, set BROWSERLESS_HTTP_URL or CHROME_WS_ENDPOINT"})
+		return nil
+	}
+	if err != nil {
+		recordUpstreamResult(overallCtx, err)
+		// 解析/探测 browserless 失败属于上游不可用
+		if isTimeoutErr(err) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "browserless endpoint timeout", "details": err.Error()})
+			return nil
 		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to resolve browserless websocket endpoint", "details": err.Error()})
+		return nil
+	}
 
-		if req.Mobile && req.Landscape {
-			viewportWidth, viewportHeight = viewportHeight, viewportWidth
-		}
+	log.Printf("%s: using chrome ws endpoint: %s", logPrefix, wsURL)
+	log.Printf("%s: endpoint sources: CHROME_WS_ENDPOINT=%q BROWSERLESS_HTTP_URL=%q", logPrefix, redactSensitiveURL(getChromeWSEndpoint()), redactEndpointList(getBrowserlessHTTPURL()))
 
-		overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
-		defer cancel()
+	// 统计各上游的进行中会话（least_inflight 依据），dial 失败计入该上游的健康状态
+	done := upstreams.begin(wsURL)
 
-		wsURL, configured, err := resolveWSEndpoint(overallCtx)
-		if !configured {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "browserless/chrome endpoint is not configured, set BROWSERLESS_HTTP_URL or CHROME_WS_ENDPOINT"})
-			return
-		}
+	if chromePool != nil && rec == nil {
+		sess, err := chromePool.session(overallCtx, wsURL)
 		if err != nil {
-			// 解析/探测 browserless 失败属于上游不可用
-			if isTimeoutErr(err) {
-				c.JSON(http.StatusGatewayTimeout, gin.H{"error": "browserless endpoint timeout", "details": err.Error()})
-				return
-			}
-			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to resolve browserless websocket endpoint", "details": err.Error()})
-			return
+			done()
+			upstreams.reportFailure(wsURL, err)
+			recordUpstreamResult(overallCtx, err)
+			respondDialError(c, err, wsURL)
+			return nil
+		}
+		recordUpstreamResult(overallCtx, nil)
+		release := sess.cancel
+		sess.cancel = func() {
+			release()
+			done()
 		}
+		return guardSession(c, fault.attach(sess))
+	}
 
-		log.Printf("screenshotHandler: using chrome ws endpoint: %s", wsURL)
-		log.Printf("screenshotHandler: endpoint sources: CHROME_WS_ENDPOINT=%q BROWSERLESS_HTTP_URL=%q", redactSensitiveURL(getChromeWSEndpoint()), redactSensitiveURL(getBrowserlessHTTPURL()))
+	sess, err := dialRemoteSession(overallCtx, wsURL, rec.contextOptions()...)
+	if err != nil {
+		done()
+		upstreams.reportFailure(wsURL, err)
+		recordUpstreamResult(overallCtx, err)
+		respondDialError(c, err, wsURL)
+		return nil
+	}
+	recordUpstreamResult(overallCtx, nil)
+	release := sess.cancel
+	sess.cancel = func() {
+		release()
+		done()
+	}
+	return guardSession(c, fault.attach(sess))
+}
 
-		// IMPORTANT:
-		// chromedp.NewRemoteAllocator 默认会“自动修改 wsURL”（未包含 /devtools/browser/ 时会去请求 /json/version）。
-		// 对于 browserless v2 的 ws connect 路由（例如 ws://browserless:3000/chromium），这种自动修改会把 wsURL 变成
-		// /json/version 返回的 ws://0.0.0.0:3000，从而导致 dial 失败。
-		// 这里明确禁止 chromedp 修改 wsURL，使用我们已经解析/选择好的 endpoint。
-		allocCtx, allocCancel := chromedp.NewRemoteAllocator(overallCtx, wsURL, chromedp.NoModifyURL)
-		defer allocCancel()
+// dialRemoteSession 为 wsURL 创建独立的远程 allocator/tab 并完成 dial 探测；失败时已释放资源。
+func dialRemoteSession(overallCtx context.Context, wsURL string, opts ...chromedp.ContextOption) (*chromeSession, error) {
+	// IMPORTANT:
+	// chromedp.NewRemoteAllocator 默认会“自动修改 wsURL”（未包含 /devtools/browser/ 时会去请求 /json/version）。
+	// 对于 browserless v2 的 ws connect 路由（例如 ws://browserless:3000/chromium），这种自动修改会把 wsURL 变成
+	// /json/version 返回的 ws://0.0.0.0:3000，从而导致 dial 失败。
+	// 这里明确禁止 chromedp 修改 wsURL，使用我们已经解析/选择好的 endpoint。
+	allocCtx, allocCancel := chromedp.NewRemoteAllocator(overallCtx, wsURL, chromedp.NoModifyURL)
+	taskCtx, taskCancel := chromedp.NewContext(allocCtx, opts...)
+	cancelAll := func() {
+		taskCancel()
+		allocCancel()
+	}
+
+	// dial 阶段：先完成一次轻量 CDP 调用，确保 websocket/握手/首次 session 建立。
+	// dial 成功后，后续所有动作仍用 taskCtx（其整体 deadline 来自请求 timeout）。
+	if err := dialChrome(taskCtx); err != nil {
+		cancelAll()
+		return nil, err
+	}
+	return &chromeSession{ctx: taskCtx, wsURL: wsURL, cancel: cancelAll}, nil
+}
 
-		taskCtx, taskCancel := chromedp.NewContext(allocCtx)
-		defer taskCancel()
+// guardSession 配置了域名策略或开启 ALLOW_FILE_URLS 时，在新 tab 上安装请求拦截；失败时关闭会话并写入错误响应。
+func guardSession(c *gin.Context, sess *chromeSession) *chromeSession {
+	if !requestGuardEnabled() {
+		return sess
+	}
+	if err := chromedp.Run(sess.ctx, requestGuardAction()); err != nil {
+		sess.cancel()
+		respondDialError(c, err, sess.wsURL)
+		return nil
+	}
+	return sess
+}
 
-		// dial 阶段：用独立的 30s 超时先完成一次轻量 CDP 调用，确保 websocket/握手/首次 session 建立。
-		// dial 成功后，后续所有动作仍用 taskCtx（其整体 deadline 来自请求 timeout）。
-		dialCtx, dialCancel := context.WithTimeout(taskCtx, remoteChromeDialTimeout)
-		defer dialCancel()
+// respondDialError 将连接远程 Chrome 失败的错误映射为 HTTP 状态码（超时 504，其余 502）。
+func respondDialError(c *gin.Context, err error, wsURL string) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "chrome dial timeout", "details": err.Error()})
+		return
+	}
+
+	// 其他 dial 类错误：尽量保持与后续 chromedp.Run 的错误码映射一致（连接/握手 => 502）
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "websocket") || strings.Contains(msg, "handshake") || strings.Contains(msg, "connect") || strings.Contains(msg, "dial") {
+		details := "dial failed: " + redactURLsInString(err.Error())
+		// 增强可观测性：返回 endpoint 来源与解析后的 ws，便于快速定位 0.0.0.0 / 端口不通 / 反代路径等问题。
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":              "failed to connect chrome endpoint",
+			"details":            details,
+			"chrome_ws_endpoint": redactSensitiveURL(wsURL),
+			"chrome_ws_endpoint_source": func() string {
+				if getChromeWSEndpoint() != "" {
+					return "CHROME_WS_ENDPOINT"
+				}
+				return "BROWSERLESS_HTTP_URL"
+			}(),
+			"browserless_http_url": redactEndpointList(getBrowserlessHTTPURL()),
+		})
+		return
+	}
+	if isTimeoutErr(err) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "chrome dial timeout", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadGateway, gin.H{"error": "failed to connect chrome endpoint", "details": err.Error()})
+}
 
-		if err := chromedp.Run(dialCtx, chromedp.ActionFunc(func(ctx context.Context) error {
-			// 只读操作，用于触发与远程 Chrome 的首次连接。
-			_, err := page.GetFrameTree().Do(ctx)
-			return err
-		})); err != nil {
-			// dialCtx 自身超时（最明确）
-			if errors.Is(dialCtx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
-				c.JSON(http.StatusGatewayTimeout, gin.H{"error": "chrome dial timeout", "details": err.Error()})
-				return
+// respondRunError 将 chromedp.Run 的错误映射为 HTTP 状态码（超时 504，连接类 502，其余 500）。
+func respondRunError(c *gin.Context, err error, wsURL, timeoutMsg, failMsg string) {
+	if stage := budgetExceededStage(err); stage != "" {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "capture time budget exceeded", "code": "BUDGET_EXCEEDED", "stage": stage, "details": err.Error()})
+		return
+	}
+	// 目标站点导航错误（net::ERR_*）需先于超时/连接类判断：ERR_CONNECTION_REFUSED 等也包含 "connect" 字样，
+	// 但并不是上游 Chrome 不可达。
+	if ne := classifyNavigationError(err); ne != nil {
+		c.JSON(ne.status, gin.H{"error": "navigation failed", "code": ne.code, "net_error": ne.netCode, "details": err.Error()})
+		return
+	}
+	var noWebGL *errWebGLUnavailable
+	if errors.As(err, &noWebGL) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "upstream browser has no WebGL support", "details": err.Error(), "gpu": noWebGL.info})
+		return
+	}
+	if isTimeoutErr(err) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": timeoutMsg, "details": err.Error()})
+		return
+	}
+	// 远程连接类错误（握手/不可达）尽量映射为 502
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "websocket") || strings.Contains(msg, "handshake") || strings.Contains(msg, "connect") {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":                "failed to connect chrome endpoint",
+			"details":              redactURLsInString(err.Error()),
+			"chrome_ws_endpoint":   redactSensitiveURL(wsURL),
+			"browserless_http_url": redactEndpointList(getBrowserlessHTTPURL()),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": failMsg, "details": err.Error()})
+}
+
+// sensitiveHeaderNames 在 X-Effective-Request 中隐藏取值的请求头（小写）。
+var sensitiveHeaderNames = map[string]struct{}{
+	"authorization":       {},
+	"proxy-authorization": {},
+	"cookie":              {},
+	"x-api-key":           {},
+}
+
+// setEffectiveRequestHeader 通过 X-Effective-Request 返回应用默认值、预设与裁剪后实际使用的参数（JSON），
+// 便于排查“输出与预期不符”的问题。html 只保留长度，敏感请求头、cookie 与 local_storage / session_storage 的值替换为 REDACTED。
+func setEffectiveRequestHeader(c *gin.Context, req *ScreenshotRequest) {
+	eff := *req
+	if eff.HTML != "" {
+		eff.HTML = fmt.Sprintf("(%d bytes)", len(req.HTML))
+	}
+	if len(req.Headers) > 0 {
+		eff.Headers = make(map[string]string, len(req.Headers))
+		for k, v := range req.Headers {
+			if _, ok := sensitiveHeaderNames[strings.ToLower(k)]; ok {
+				v = "REDACTED"
 			}
+			eff.Headers[k] = v
+		}
+	}
+	if len(req.Cookies) > 0 {
+		eff.Cookies = make([]Cookie, len(req.Cookies))
+		for i, ck := range req.Cookies {
+			ck.Value = "REDACTED"
+			eff.Cookies[i] = ck
+		}
+	}
+	eff.LocalStorage = redactedValues(req.LocalStorage)
+	eff.SessionStorage = redactedValues(req.SessionStorage)
+	if v, err := headerJSON(eff); err == nil {
+		c.Header("X-Effective-Request", v)
+	}
+	if req.siteProfile != "" {
+		c.Header("X-Site-Profile", req.siteProfile)
+	}
+}
 
-			// 其他 dial 类错误：尽量保持与后续 chromedp.Run 的错误码映射一致（连接/握手 => 502）
-			msg := strings.ToLower(err.Error())
-			if strings.Contains(msg, "websocket") || strings.Contains(msg, "handshake") || strings.Contains(msg, "connect") || strings.Contains(msg, "dial") {
-				details := "dial failed: " + redactURLsInString(err.Error())
-				// 增强可观测性：返回 endpoint 来源与解析后的 ws，便于快速定位 0.0.0.0 / 端口不通 / 反代路径等问题。
-				c.JSON(http.StatusBadGateway, gin.H{
-					"error":              "failed to connect chrome endpoint",
-					"details":            details,
-					"chrome_ws_endpoint": redactSensitiveURL(wsURL),
-					"chrome_ws_endpoint_source": func() string {
-						if getChromeWSEndpoint() != "" {
-							return "CHROME_WS_ENDPOINT"
-						}
-						return "BROWSERLESS_HTTP_URL"
-					}(),
-					"browserless_http_url": redactSensitiveURL(getBrowserlessHTTPURL()),
-				})
-				return
+// usesOnly 报告请求是否只设置了 keep 复制的参数：把这些参数复制到空请求后与原请求的 JSON 比较，
+// 有任何其他参数（包括站点配置带来的）时返回 false。
+func (r *ScreenshotRequest) usesOnly(keep func(dst, src *ScreenshotRequest)) bool {
+	var simple ScreenshotRequest
+	keep(&simple, r)
+	a, err1 := json.Marshal(r)
+	b, err2 := json.Marshal(&simple)
+	return err1 == nil && err2 == nil && bytes.Equal(a, b)
+}
+
+// viewportSize 返回实际使用的视口尺寸：height==0（元素截图自动高度）或 auto 时先用默认高度，
+// mobile+landscape 时交换宽高。
+func (r *ScreenshotRequest) viewportSize() (int64, int64) {
+	w := int64(r.Width)
+	h := int64(r.Height)
+	if h <= 0 {
+		h = defaultHeight
+	}
+	if r.Mobile && r.Landscape {
+		w, h = h, w
+	}
+	return w, h
+}
+
+// navigationActions 构造“设置视口/UA/Header -> 导航 -> 等待”这一段通用动作，
+// 供截图以及其他基于页面渲染结果的接口复用。
+//
+// 导航与各类等待受 budget 约束：预留截图所需时间，预算耗尽时返回 BUDGET_EXCEEDED（或在 best-effort 模式下跳过）。
+func navigationActions(req *ScreenshotRequest, viewportWidth, viewportHeight int64, budget *captureBudget) []chromedp.Action {
+	actions := []chromedp.Action{
+		network.Enable(),
+		emulation.SetDeviceMetricsOverride(viewportWidth, viewportHeight, req.DeviceScale, req.Mobile),
+	}
+
+	if req.UserAgent != "" {
+		// cdproto 中 UA override 位于 Emulation domain
+		actions = append(actions, emulation.SetUserAgentOverride(req.UserAgent))
+	}
+
+	if len(req.Headers) > 0 {
+		headers := make(network.Headers, len(req.Headers))
+		for k, v := range req.Headers {
+			headers[k] = v
+		}
+		actions = append(actions, network.SetExtraHTTPHeaders(headers))
+	}
+
+	if len(req.Cookies) > 0 {
+		actions = append(actions, setCookiesAction(req))
+	}
+
+	if len(req.LocalStorage) > 0 || len(req.SessionStorage) > 0 {
+		actions = append(actions, webStorageAction(req))
+	}
+
+	if req.InjectJSBefore != "" {
+		actions = append(actions, injectJSBeforeAction(req.InjectJSBefore))
+	}
+
+	if req.Deterministic {
+		actions = append(actions, deterministicInitAction())
+	}
+
+	if req.DisableAnimations {
+		actions = append(actions, animationPlaybackPauseAction())
+	}
+
+	// wait_for_canvas 需要读回 WebGL 画面，未开启 preserveDrawingBuffer 时合成后读回为空白，因此一并开启
+	if req.CanvasPreserveBuffer || req.WaitForCanvas != "" {
+		actions = append(actions, canvasPreserveBufferAction())
+	}
+
+	if req.InjectFonts {
+		actions = append(actions, injectFontsAction())
+	}
+
+	if req.SpoofReferrer {
+		actions = append(actions, spoofReferrerAction(req.Referer))
+	}
+
+	if req.Emoji == emojiTwemoji {
+		// Twemoji 图片（data URI）可能被页面 CSP 的 img-src 拦截
+		actions = append(actions, page.SetBypassCSP(true))
+	}
+
+	if blocked := req.blockedURLs(); len(blocked) > 0 {
+		actions = append(actions, network.SetBlockedURLs(blocked))
+	}
+
+	if req.BlockAds {
+		actions = append(actions, adBlockAction())
+	}
+
+	if media := emulatedMediaAction(req); media != nil {
+		actions = append(actions, media)
+	}
+
+	if faultInjectionEnabled {
+		actions = append(actions, faultBeforeNavigateAction())
+	}
+
+	// 请求计数需在导航之前开始
+	idle := req.networkIdleTracker()
+	if idle != nil {
+		actions = append(actions, idle.listenAction())
+	}
+
+	if req.HTML != "" {
+		actions = append(actions, budget.wait("navigate", 0, setDocumentContentAction(req.HTML)))
+	} else {
+		actions = append(actions, navigateStepsActions(req, budget)...)
+		var nav chromedp.Action = chromedp.Tasks{
+			navigateAction(req.URL, req.finalReferer()),
+			chromedp.WaitReady("body", chromedp.ByQuery),
+		}
+		if req.WaitUntil == waitUntilDOMContentLoaded {
+			nav = chromedp.Tasks{
+				domContentLoadedNavigateAction(req.URL, req.finalReferer()),
+				chromedp.WaitReady("body", chromedp.ByQuery),
 			}
-			if isTimeoutErr(err) {
-				c.JSON(http.StatusGatewayTimeout, gin.H{"error": "chrome dial timeout", "details": err.Error()})
-				return
+		} else if req.Mode == modeThumbnail {
+			nav = thumbnailNavigateAction(req.URL, req.finalReferer())
+		}
+		if req.Method == http.MethodPost {
+			nav = postNavigateAction(req, nav)
+		}
+		actions = append(actions, budget.wait("navigate", 0, nav))
+	}
+
+	if idle != nil {
+		actions = append(actions, budget.wait("wait_until", 0, idle.waitAction(networkIdleWindow)))
+	}
+
+	if req.WaitFor != "" {
+		actions = append(actions, budget.wait("wait_for", 0, chromedp.WaitVisible(req.WaitFor, chromedp.ByQuery)))
+	}
+
+	if req.WaitForExpression != "" {
+		actions = append(actions, budget.wait("wait_for_expression", 0, waitForExpressionAction(req.WaitForExpression)))
+	}
+
+	if req.WaitForCanvas != "" {
+		actions = append(actions, budget.wait("wait_for_canvas", 0, waitForCanvasAction(req.WaitForCanvas)))
+	}
+
+	if req.WaitTime > 0 {
+		d := time.Duration(req.WaitTime) * time.Millisecond
+		actions = append(actions, budget.wait("wait_time", d, chromedp.Sleep(d)))
+	}
+
+	if len(req.ExpandSelectors) > 0 {
+		actions = append(actions, budget.wait("expand", 0, expandAction(req)))
+	}
+
+	if req.InjectJSAfter != "" {
+		actions = append(actions, budget.wait("inject_js", 0, injectJSAfterAction(req.InjectJSAfter)))
+	}
+
+	if req.DisableAnimations {
+		actions = append(actions, injectStyleAction(disableAnimationsCSS))
+	}
+
+	if strings.TrimSpace(req.InjectCSS) != "" {
+		actions = append(actions, injectStyleAction(req.InjectCSS))
+	}
+
+	if len(req.HideSelectors) > 0 {
+		actions = append(actions, injectStyleAction(hideSelectorsCSS(req.HideSelectors)))
+	}
+
+	if faultInjectionEnabled {
+		actions = append(actions, faultAfterLoadAction())
+	}
+	return actions
+}
+
+// setDocumentContentAction 打开 about:blank 并用 Page.setDocumentContent 写入 HTML，
+// 等待文档（含图片/样式等子资源）加载完成。
+func setDocumentContentAction(html string) chromedp.Action {
+	var loaded bool
+	return chromedp.Tasks{
+		chromedp.Navigate("about:blank"),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			tree, err := page.GetFrameTree().Do(ctx)
+			if err != nil {
+				return err
 			}
-			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to connect chrome endpoint", "details": err.Error()})
+			return page.SetDocumentContent(tree.Frame.ID, html).Do(ctx)
+		}),
+		chromedp.Poll(`document.readyState === 'complete'`, &loaded,
+			chromedp.WithPollingInterval(50*time.Millisecond),
+			chromedp.WithPollingTimeout(0),
+		),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+	}
+}
+
+func screenshotHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := parseRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		actions := make([]chromedp.Action, 0, 16)
+		req.applyDefaults()
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setEffectiveRequestHeader(c, &req)
 
-		actions = append(actions,
-			network.Enable(),
-			emulation.SetDeviceMetricsOverride(viewportWidth, viewportHeight, req.DeviceScale, req.Mobile),
-		)
+		// 录制与故障注入请求不读写缓存、不与其他请求合并
+		if req.RecordCDP {
+			rec := startCDPRecording(c, &req)
+			defer rec.finish()
+			captureScreenshot(c, req, "", 0, "")
+			return
+		}
+		if faultInjectionEnabled && c.GetHeader(faultHeader) != "" {
+			captureScreenshot(c, req, "", 0, "")
+			return
+		}
 
-		if req.UserAgent != "" {
-			// cdproto 中 UA override 位于 Emulation domain
-			actions = append(actions, emulation.SetUserAgentOverride(req.UserAgent))
+		var thumbnailKey string
+		thumbnailTTL := getThumbnailCacheTTL()
+		if req.Mode == modeThumbnail && thumbnailTTL > 0 && !req.Store && req.ResponseType != responseTypeJSON {
+			thumbnailKey = requestFingerprint(&req)
+			if img, remaining, ok := thumbnailCache.get(thumbnailKey); ok {
+				c.Header("X-Cache", "HIT")
+				c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(remaining.Seconds())))
+				respondImage(c, contentTypeForFormat(req.Format), img)
+				return
+			}
 		}
 
-		if len(req.Headers) > 0 {
-			headers := make(network.Headers, len(req.Headers))
-			for k, v := range req.Headers {
-				headers[k] = v
+		var responseKey string
+		if responseCache != nil && responseCache.cacheable(&req) {
+			responseKey = responseCache.key(&req)
+			if !responseCache.bypass(c) {
+				if resp, remaining, ok := responseCache.get(responseKey); ok {
+					for k, v := range resp.Headers {
+						c.Header(k, v)
+					}
+					c.Header("X-Cache", "HIT")
+					c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(remaining.Seconds())))
+					respondImage(c, resp.ContentType, resp.Body)
+					return
+				}
 			}
-			actions = append(actions, network.SetExtraHTTPHeaders(headers))
 		}
 
-		actions = append(actions,
-			chromedp.Navigate(req.URL),
-			chromedp.WaitReady("body", chromedp.ByQuery),
-		)
+		if captureGroup != nil && req.SessionID == "" {
+			coalesceCapture(c, coalesceKey(req), func(ic *gin.Context) {
+				captureScreenshot(ic, req, thumbnailKey, thumbnailTTL, responseKey)
+			})
+			return
+		}
+		captureScreenshot(c, req, thumbnailKey, thumbnailTTL, responseKey)
+	}
+}
 
-		if req.WaitFor != "" {
-			actions = append(actions, chromedp.WaitVisible(req.WaitFor, chromedp.ByQuery))
+// captureScreenshot 执行实际的渲染与截图（缓存未命中之后的部分），结果写入 c；
+// 命中缓存键的结果在此写回缓存。
+func captureScreenshot(c *gin.Context, req ScreenshotRequest, thumbnailKey string, thumbnailTTL time.Duration, responseKey string) {
+	req.output = &outputInfo{started: time.Now()}
+	defer func() {
+		hostStats.observe(req.targetHost(), time.Since(req.output.started), c.Writer.Status()/100 == 2)
+	}()
+	// 视口尺寸：req.Height 允许为 0（元素截图且未设置 height）。此时先用默认高度完成加载，
+	// 截图前再自动扩展为页面总高度。
+	viewportWidth, viewportHeight := req.viewportSize()
+	autoExpandViewportHeight := req.Selector != "" && (req.Height == 0 || req.Height == heightAuto)
+	fitViewportHeight := req.Selector == "" && req.Height == heightAuto
+
+	overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
+	defer cancel()
+
+	if backend := captureBackendFor(&req); backend != nil {
+		// engine=firefox 已在 validate 中检查；CAPTURE_BACKEND=playwright 只作用于截图，在此检查
+		if !req.simpleCaptureOnly() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("the %s capture backend supports only %s", backend.name(), simpleCaptureParams)})
+			return
 		}
+		captureWithBackend(c, overallCtx, &req, backend, responseKey)
+		return
+	}
 
-		if req.WaitTime > 0 {
-			actions = append(actions, chromedp.Sleep(time.Duration(req.WaitTime)*time.Millisecond))
+	if req.SessionID == "" && useRESTPassthrough(c, &req) {
+		if img, handled := captureViaBrowserlessREST(c, overallCtx, &req); handled {
+			if img != nil {
+				setCaptureHeaders(c, req.output, img)
+				respondCapturedImage(c, &req, img, responseKey, true)
+			}
+			return
 		}
+	}
+
+	var sess *chromeSession
+	if req.SessionID != "" {
+		sess = openWarmSession(c, overallCtx, req.SessionID)
+	} else {
+		sess = openChromeSession(c, overallCtx, "screenshotHandler")
+	}
+	if sess == nil {
+		return
+	}
+	defer sess.cancel()
+	taskCtx, wsURL := sess.ctx, sess.wsURL
+	req.output.listen(taskCtx)
+
+	actions := make([]chromedp.Action, 0, 16)
+
+	var tracer *traceRecorder
+	if req.Trace {
+		tracer = newTraceRecorder()
+		tracer.listen(taskCtx)
+		actions = append(actions, tracer.start(req.TraceCategories))
+	}
+
+	if req.RequiresWebGL {
+		actions = append(actions, requireWebGLAction(wsURL))
+	}
+
+	var archiveTracker *networkIdleTracker
+	if req.Mode == modeArchive {
+		archiveTracker = newNetworkIdleTracker(archiveMaxInflight)
+		actions = append(actions, archiveTracker.listenAction())
+	}
+
+	budget := newCaptureBudget(time.Duration(req.Timeout)*time.Second, req.BestEffort)
+	actions = append(actions, navigationActions(&req, viewportWidth, viewportHeight, budget)...)
+
+	if archiveTracker != nil {
+		actions = append(actions, archivePrepareActions(archiveTracker, budget)...)
+	}
+
+	// 伪本地化需在 Twemoji 替换之前执行，避免改写 emoji 图片的 alt。
+	if req.PseudoLocale {
+		actions = append(actions, pseudoLocaleAction())
+	}
+
+	if req.Emoji == emojiTwemoji {
+		actions = append(actions, twemojiAction())
+	}
+
+	if req.MediaBehavior != "" {
+		actions = append(actions, mediaBehaviorAction(req.MediaBehavior))
+	}
+
+	if req.Deterministic {
+		actions = append(actions, deterministicFreezeAction())
+	}
+
+	var fontReport []FontUsage
+	if req.FontReport {
+		actions = append(actions, fontReportAction(&fontReport))
+	}
 
 	if req.Transparent {
 		// 透明背景：
 		// 1. 设置透明背景色（必须在截图前设置）
 		actions = append(actions, emulation.SetDefaultBackgroundColorOverride().
 			WithColor(&cdp.RGBA{R: 0, G: 0, B: 0, A: 0}))
-		
+
 		// 2. 注入 CSS 移除页面自身设置的 html/body 背景色
 		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
 			return chromedp.EvaluateAsDevTools(`(function() {
-				var s = document.createElement('style');
-				s.textContent = 'html, body { background: transparent !important; background-color: transparent !important; }';
-				document.head.appendChild(s);
-			})()`, nil).Do(ctx)
+			var s = document.createElement('style');
+			s.textContent = 'html, body { background: transparent !important; background-color: transparent !important; }';
+			document.head.appendChild(s);
+		})()`, nil).Do(ctx)
 		}))
 	}
 
-		// 元素截图 + 未设置 height：截图前先获取页面总高度，把视口高度扩展到页面高度。
-		// 不新增参数：以 height==0 作为触发条件。
-		if autoExpandViewportHeight {
-			actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
-				// 优先使用 LayoutMetrics（更接近渲染层的真实尺寸）
-				var pageHeight float64
-				if _, _, contentSize, _, _, _, err := page.GetLayoutMetrics().Do(ctx); err == nil && contentSize != nil && contentSize.Height > 0 {
-					pageHeight = contentSize.Height
-				} else {
-					// fallback：用 DOM 的 scrollHeight
-					var h float64
-					js := `(() => {
-						const de = document.documentElement;
-						const b = document.body;
-						return Math.max(
-							de ? de.scrollHeight : 0,
-							de ? de.offsetHeight : 0,
-							b ? b.scrollHeight : 0,
-							b ? b.offsetHeight : 0
-						);
-					})()`
-					if err := chromedp.EvaluateAsDevTools(js, &h).Do(ctx); err != nil {
-						return err
-					}
-					pageHeight = h
+	// 元素截图 + 未设置 height：截图前先获取页面总高度，把视口高度扩展到页面高度。
+	// 不新增参数：以 height==0 作为触发条件。
+	if autoExpandViewportHeight {
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			// 优先使用 LayoutMetrics（更接近渲染层的真实尺寸）
+			var pageHeight float64
+			if _, _, contentSize, _, _, _, err := page.GetLayoutMetrics().Do(ctx); err == nil && contentSize != nil && contentSize.Height > 0 {
+				pageHeight = contentSize.Height
+			} else {
+				// fallback：用 DOM 的 scrollHeight
+				var h float64
+				js := `(() => {
+					const de = document.documentElement;
+					const b = document.body;
+					return Math.max(
+						de ? de.scrollHeight : 0,
+						de ? de.offsetHeight : 0,
+						b ? b.scrollHeight : 0,
+						b ? b.offsetHeight : 0
+					);
+				})()`
+				if err := chromedp.EvaluateAsDevTools(js, &h).Do(ctx); err != nil {
+					return err
 				}
+				pageHeight = h
+			}
 
-				if pageHeight <= 0 {
-					return fmt.Errorf("failed to determine page height")
-				}
+			if pageHeight <= 0 {
+				return fmt.Errorf("failed to determine page height")
+			}
 
-				desired := int64(math.Ceil(pageHeight))
-				if desired < viewportHeight {
-					desired = viewportHeight
-				}
-				if desired > maxAutoViewportHeight {
-					desired = maxAutoViewportHeight
-				}
+			desired := int64(math.Ceil(pageHeight))
+			if desired < viewportHeight {
+				desired = viewportHeight
+			}
+			if desired > maxAutoViewportHeight {
+				desired = maxAutoViewportHeight
+			}
 
-				if desired != viewportHeight {
-					viewportHeight = desired
-					if err := emulation.SetDeviceMetricsOverride(viewportWidth, viewportHeight, req.DeviceScale, req.Mobile).Do(ctx); err != nil {
-						return err
-					}
+			if desired != viewportHeight {
+				viewportHeight = desired
+				if err := emulation.SetDeviceMetricsOverride(viewportWidth, viewportHeight, req.DeviceScale, req.Mobile).Do(ctx); err != nil {
+					return err
 				}
+			}
 
-				// 给浏览器一点时间完成 relayout
-				return nil
-			}))
-		}
-
-		var clip *page.Viewport
-		if req.Clip != nil {
-			clip = &page.Viewport{X: req.Clip.X, Y: req.Clip.Y, Width: req.Clip.Width, Height: req.Clip.Height, Scale: 1}
-		}
-
-		// selector 截图：尽量保持与 Playwright 行为一致：滚动到元素、再计算 bounding box 并转成 clip
-		if req.Selector != "" {
-			actions = append(actions,
-				chromedp.ScrollIntoView(req.Selector, chromedp.ByQuery),
-				chromedp.WaitVisible(req.Selector, chromedp.ByQuery),
-				chromedp.ActionFunc(func(ctx context.Context) error {
-					js := fmt.Sprintf(`(() => {
-						const el = document.querySelector(%q);
-						if (!el) return null;
-						const r = el.getBoundingClientRect();
-						return { x: r.x + window.scrollX, y: r.y + window.scrollY, width: r.width, height: r.height };
-					})()`, req.Selector)
-
-					var rect struct {
-						X      float64 `json:"x"`
-						Y      float64 `json:"y"`
-						Width  float64 `json:"width"`
-						Height float64 `json:"height"`
-					}
-					if err := chromedp.EvaluateAsDevTools(js, &rect).Do(ctx); err != nil {
-						return err
-					}
-					if rect.Width <= 0 || rect.Height <= 0 {
-						return fmt.Errorf("selector resolved but has empty bounding box: %s", req.Selector)
-					}
-					clip = &page.Viewport{X: rect.X, Y: rect.Y, Width: rect.Width, Height: rect.Height, Scale: 1}
-					return nil
-				}),
-			)
-		} else if req.FullPage && clip == nil {
-			// full_page：用 LayoutMetrics 的 contentSize 构造 clip
-			actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
-				_, _, contentSize, _, _, _, err := page.GetLayoutMetrics().Do(ctx)
-				if err != nil {
-					return err
+			// 给浏览器一点时间完成 relayout
+			return nil
+		}))
+	}
+	if fitViewportHeight {
+		actions = append(actions, fitViewportToContent(&req, viewportWidth, &viewportHeight))
+	}
+
+	var clip *page.Viewport
+	var truncated bool
+	if req.Clip != nil {
+		clip = &page.Viewport{X: req.Clip.X, Y: req.Clip.Y, Width: req.Clip.Width, Height: req.Clip.Height, Scale: 1}
+	}
+
+	var elementInfo *ElementInfo
+
+	// selector 截图：尽量保持与 Playwright 行为一致：滚动到元素、再计算 bounding box 并转成 clip
+	if req.Selector != "" {
+		actions = append(actions,
+			chromedp.ScrollIntoView(req.Selector, chromedp.ByQuery),
+			chromedp.WaitVisible(req.Selector, chromedp.ByQuery),
+		)
+		if req.HideOverlapping {
+			actions = append(actions, hideOverlappingAction(req.Selector))
+		}
+		actions = append(actions,
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				js := fmt.Sprintf(`(() => {
+					const el = document.querySelector(%q);
+					if (!el) return null;
+					const r = el.getBoundingClientRect();
+					return { x: r.x + window.scrollX, y: r.y + window.scrollY, width: r.width, height: r.height };
+				})()`, req.Selector)
+
+				var rect struct {
+					X      float64 `json:"x"`
+					Y      float64 `json:"y"`
+					Width  float64 `json:"width"`
+					Height float64 `json:"height"`
 				}
-				if contentSize == nil {
-					return errors.New("failed to get layout metrics content size")
+				if err := chromedp.EvaluateAsDevTools(js, &rect).Do(ctx); err != nil {
+					return err
 				}
-				if contentSize.Width <= 0 || contentSize.Height <= 0 {
-					return fmt.Errorf("invalid content size: %vx%v", contentSize.Width, contentSize.Height)
+				if rect.Width <= 0 || rect.Height <= 0 {
+					return fmt.Errorf("selector resolved but has empty bounding box: %s", req.Selector)
 				}
-				clip = &page.Viewport{X: 0, Y: 0, Width: contentSize.Width, Height: contentSize.Height, Scale: 1}
+				clip = &page.Viewport{X: rect.X, Y: rect.Y, Width: rect.Width, Height: rect.Height, Scale: 1}
 				return nil
-			}))
+			}),
+		)
+		if req.ElementInfo {
+			elementInfo = &ElementInfo{}
+			actions = append(actions, elementInfoAction(req.Selector, elementInfo))
 		}
+	} else if req.FullPage && clip == nil {
+		// full_page：用 LayoutMetrics 的 contentSize 构造 clip，超出像素预算时截断高度
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			clip, err = fullPageClip(ctx)
+			if err == nil {
+				truncated = limitCapturePixels(clip, req.DeviceScale)
+			}
+			return err
+		}))
+	}
+
+	var archiveMHTML string
+	var archiveMeta *ArchiveMetadata
+	if req.Mode == modeArchive {
+		archiveMeta = &ArchiveMetadata{URL: req.URL, ViewportWidth: viewportWidth, ViewportHeight: viewportHeight, DeviceScale: req.DeviceScale}
+		actions = append(actions,
+			chromedp.Evaluate(archivePageInfoJS, archiveMeta),
+			captureMHTMLAction(&archiveMHTML),
+		)
+	}
 
 	var img []byte
+	var pages [][]byte
+	var listingManifest *ListingManifest
+	var printPDF []byte
 	actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+		if req.Mode == modePrint && req.PrintOutput == printOutputPDF {
+			params, err := req.PDF.printParams()
+			if err != nil {
+				return err
+			}
+			printPDF, _, err = params.Do(ctx)
+			return err
+		}
+
 		// 使用标准 API（透明背景已通过 SetDefaultBackgroundColorOverride 设置）
 		cap := page.CaptureScreenshot().WithFromSurface(true).WithFormat(captureFormat(req.Format))
 
@@ -1114,6 +2159,36 @@ func screenshotHandler() gin.HandlerFunc {
 			cap = cap.WithQuality(int64(req.Quality))
 		}
 
+		if req.Mode == modeThumbnail {
+			// 缩略图：在 Chrome 端把截图区域缩放到 preview_width
+			if clip == nil {
+				clip = &page.Viewport{X: 0, Y: 0, Width: float64(viewportWidth), Height: float64(viewportHeight)}
+			}
+			clip.Scale = float64(req.PreviewWidth) / clip.Width
+		}
+
+		if req.PaginateHeight > 0 {
+			var err error
+			pages, err = capturePages(ctx, cap, clip, &req)
+			return err
+		}
+
+		if req.Paginate != nil {
+			// 翻页后页面高度可能变化，整页截图时每页重新计算区域
+			listingManifest = &ListingManifest{}
+			return captureListing(ctx, &req, func(ctx context.Context) ([]byte, error) {
+				if !req.FullPage {
+					return cap.Do(ctx)
+				}
+				full, err := fullPageClip(ctx)
+				if err != nil {
+					return nil, err
+				}
+				truncated = limitCapturePixels(full, req.DeviceScale) || truncated
+				return cap.WithClip(full).Do(ctx)
+			}, &pages, listingManifest)
+		}
+
 		if clip != nil {
 			cap = cap.WithClip(clip)
 		}
@@ -1126,28 +2201,188 @@ func screenshotHandler() gin.HandlerFunc {
 		return nil
 	}))
 
-		if err := chromedp.Run(taskCtx, actions...); err != nil {
-			if isTimeoutErr(err) {
-				c.JSON(http.StatusGatewayTimeout, gin.H{"error": "screenshot timeout", "details": err.Error()})
-				return
-			}
-			// 远程连接类错误（握手/不可达）尽量映射为 502
-			msg := strings.ToLower(err.Error())
-			if strings.Contains(msg, "websocket") || strings.Contains(msg, "handshake") || strings.Contains(msg, "connect") {
-				c.JSON(http.StatusBadGateway, gin.H{
-					"error":                "failed to connect chrome endpoint",
-					"details":              redactURLsInString(err.Error()),
-					"chrome_ws_endpoint":   redactSensitiveURL(wsURL),
-					"browserless_http_url": redactSensitiveURL(getBrowserlessHTTPURL()),
-				})
-				return
+	var trimResult *TrimResult
+	if req.Trim {
+		trimResult = &TrimResult{}
+		actions = append(actions, trimImageAction(&req, &img, trimResult))
+	}
+
+	var filterSkipped bool
+	if req.imageFilter() != "" {
+		actions = append(actions, filterImageAction(&req, &img, &filterSkipped))
+	}
+
+	var sample rgbaSample
+	if (blurhashEnabled || req.Palette) && archiveMeta == nil && tracer == nil {
+		actions = append(actions, sampleImageAction(&req, &img, &sample))
+	}
+
+	var assembledPDF []byte
+	if req.PaginateOutput == outputPDF {
+		// 分页截图合成 PDF：复用当前 tab（截图已完成）
+		printParams, _ := req.PDF.printParams()
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			images := make([]assembledImage, len(pages))
+			for i, p := range pages {
+				images[i] = assembledImage{contentType: contentTypeForFormat(req.Format), data: p}
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to screenshot", "details": err.Error()})
+			return assemblePDFAction(images, printParams, &assembledPDF).Do(ctx)
+		}))
+	}
+
+	actions = append(actions, pageInfoAction(&req.output.page))
+
+	if tracer != nil {
+		actions = append(actions, tracer.stop())
+	}
+
+	if err := chromedp.Run(taskCtx, actions...); err != nil {
+		respondRunError(c, err, wsURL, "screenshot timeout", "failed to screenshot")
+		return
+	}
+	if sample.Pix != nil {
+		if blurhashEnabled {
+			req.output.blurhash = encodeBlurhash(sample)
+		}
+		if req.Palette {
+			req.output.palette = extractPalette(sample, req.PaletteSize)
+		}
+	}
+	img = normalizeColorProfile(img)
+	for i := range pages {
+		pages[i] = normalizeColorProfile(pages[i])
+	}
+
+	if tracer != nil {
+		if !req.TraceScreenshot {
+			c.JSON(http.StatusOK, tracer.traceJSON())
 			return
 		}
+		payload := gin.H{
+			"trace":        tracer.traceJSON(),
+			"content_type": contentTypeForFormat(req.Format),
+			"image_base64": base64.StdEncoding.EncodeToString(img),
+		}
+		if elementInfo != nil {
+			payload["element"] = elementInfo
+		}
+		if req.FontReport {
+			payload["fonts"] = fontReport
+		}
+		c.JSON(http.StatusOK, payload)
+		return
+	}
 
-		c.Data(http.StatusOK, contentTypeForFormat(req.Format), img)
+	if skipped := budget.skippedStages(); len(skipped) > 0 {
+		c.Header("X-Budget-Exceeded", strings.Join(skipped, ","))
+	}
+	outputImage := img
+	if archiveMeta != nil || pages != nil {
+		outputImage = nil
+	}
+	setCaptureHeaders(c, req.output, outputImage)
+	if elementInfo != nil {
+		if v, err := headerJSON(elementInfo); err == nil {
+			c.Header("X-Element-Info", v)
+		}
+	}
+	if req.FontReport {
+		if v, err := headerJSON(fontReport); err == nil {
+			c.Header("X-Font-Report", v)
+		}
+	}
+	if trimResult != nil {
+		if v, err := headerJSON(trimResult); err == nil {
+			c.Header("X-Trim", v)
+		}
 	}
+	if filterSkipped {
+		c.Header("X-Filter-Skipped", "true")
+	}
+	if truncated {
+		c.Header("X-Capture-Truncated", "true")
+	}
+	if archiveMeta != nil {
+		archiveMeta.CapturedAt = time.Now().UTC().Format(time.RFC3339)
+		archiveMeta.SkippedStages = budget.skippedStages()
+		zipData, err := buildArchiveZip(img, archiveMHTML, archiveMeta)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build archive", "details": err.Error()})
+			return
+		}
+		respondOutput(c, &req, "application/zip", zipData, func() {
+			c.Header("Content-Disposition", `attachment; filename="archive.zip"`)
+			c.Data(http.StatusOK, "application/zip", zipData)
+		})
+		return
+	}
+	if printPDF != nil {
+		respondOutput(c, &req, "application/pdf", printPDF, func() {
+			c.Data(http.StatusOK, "application/pdf", printPDF)
+		})
+		return
+	}
+	if assembledPDF != nil {
+		c.Header("X-Page-Count", strconv.Itoa(len(pages)))
+		respondOutput(c, &req, "application/pdf", assembledPDF, func() {
+			respondAssembledPDF(c, assembledPDF, len(pages))
+		})
+		return
+	}
+	if pages != nil {
+		zipData, err := buildPagesZip(pages, req.Format, listingManifest)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build pages zip", "details": err.Error()})
+			return
+		}
+		c.Header("X-Page-Count", strconv.Itoa(len(pages)))
+		respondOutput(c, &req, "application/zip", zipData, func() {
+			c.Header("Content-Disposition", `attachment; filename="pages.zip"`)
+			c.Data(http.StatusOK, "application/zip", zipData)
+		})
+		return
+	}
+	if thumbnailKey != "" {
+		// 缩略图允许 best-effort 结果进入缓存：以延迟优先
+		thumbnailCache.put(thumbnailKey, img, thumbnailTTL)
+		c.Header("X-Cache", "MISS")
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(thumbnailTTL.Seconds())))
+	}
+	// best-effort 跳过了等待的结果不完整，不写入缓存
+	respondCapturedImage(c, &req, img, responseKey, len(budget.skippedStages()) == 0)
+}
+
+// respondCapturedImage 返回截图结果；responseKey 非空且 cacheable 时连同结果类响应头写入响应缓存。
+func respondCapturedImage(c *gin.Context, req *ScreenshotRequest, img []byte, responseKey string, cacheable bool) {
+	if responseKey != "" {
+		if cacheable {
+			resp := &cachedResponse{ContentType: contentTypeForFormat(req.Format), Body: img, Headers: map[string]string{}}
+			for _, h := range cachedHeaders {
+				if v := c.Writer.Header().Get(h); v != "" {
+					resp.Headers[h] = v
+				}
+			}
+			responseCache.put(responseKey, resp)
+			c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(responseCache.ttl.Seconds())))
+		}
+		c.Header("X-Cache", "MISS")
+	}
+
+	respondOutput(c, req, contentTypeForFormat(req.Format), img, func() {
+		respondImage(c, contentTypeForFormat(req.Format), img)
+	})
+}
+
+// captureMiddlewares 为需要上游 Chrome 的接口依次加上按客户端限流与整个实例的并发限制。
+func captureMiddlewares() []gin.HandlerFunc {
+	var mw []gin.HandlerFunc
+	if rateLimiter != nil {
+		mw = append(mw, rateLimiter.middleware())
+	}
+	if captureSlots != nil {
+		mw = append(mw, captureSlots.middleware())
+	}
+	return mw
 }
 
 func main() {
@@ -1156,6 +2391,29 @@ func main() {
 		port = "8080"
 	}
 
+	applyResourceLimits()
+	if err := loadSiteProfiles(); err != nil {
+		log.Fatalf("failed to load site profiles: %v", err)
+	}
+	if err := loadDomainPolicy(); err != nil {
+		log.Fatalf("failed to load domain policy: %v", err)
+	}
+	if err := loadColorProfile(); err != nil {
+		log.Fatalf("invalid color profile config: %v", err)
+	}
+	if err := loadCaptureBackend(); err != nil {
+		log.Fatalf("invalid capture backend config: %v", err)
+	}
+	if err := loadCaptureStore(); err != nil {
+		log.Fatalf("failed to configure storage: %v", err)
+	}
+	watchSecrets()
+	loadAdBlock()
+
+	if chromePool != nil {
+		go chromePool.maintain()
+	}
+
 	r := gin.Default()
 
 	r.GET("/health", func(c *gin.Context) {
@@ -1169,8 +2427,11 @@ func main() {
 		status := http.StatusOK
 		state := "ok"
 		if !available {
-			status = http.StatusServiceUnavailable
 			state = "degraded"
+			// CAPTURE_BACKEND=playwright 时截图不依赖 CDP，仅其他接口不可用
+			if captureBackendName != backendPlaywright {
+				status = http.StatusServiceUnavailable
+			}
 		}
 
 		payload := gin.H{
@@ -1178,18 +2439,76 @@ func main() {
 			"time":                 time.Now().UTC().Format(time.RFC3339),
 			"chrome_ws_configured": configured,
 			"chrome_ws_available":  available,
-			"browserless_http_url": getBrowserlessHTTPURL(),
-			"chrome_ws_endpoint":   wsURL,
+			"browserless_http_url": redactEndpointList(getBrowserlessHTTPURL()),
+			"chrome_ws_endpoint":   redactSensitiveURL(wsURL),
 		}
 		if err != nil {
-			payload["details"] = err.Error()
+			payload["details"] = redactURLsInString(err.Error())
+		}
+		if captureBackendName == backendPlaywright {
+			payload["capture_backend"] = gin.H{"type": backendPlaywright, "endpoints": len(playwrightEndpoints)}
+		}
+		// GPU/WebGL 能力仅返回该上游最近一次探测结果（/browser 或 requires_webgl 请求触发），health 本身不发起探测；
+		// 多上游时各地址的结果见 upstreams。
+		if gpu := cachedGPUInfo(wsURL); gpu != nil {
+			payload["gpu"] = gpu
+		}
+		if chromePool != nil {
+			payload["browser_pool"] = chromePool.stats()
+		}
+		if upstreamBreaker != nil {
+			payload["circuit_breaker"] = upstreamBreaker.stats()
+		}
+		if captureSlots != nil {
+			payload["capture_slots"] = captureSlots.stats()
+		}
+		if responseCache != nil {
+			payload["response_cache"] = responseCache.stats()
+		}
+		if storage := storageStats(); storage != nil {
+			payload["storage"] = storage
+		}
+		if f := adFilters.Load(); f != nil {
+			payload["adblock"] = f.stats()
+		}
+		if len(upstreams.endpoints) > 1 {
+			payload["upstreams"] = upstreams.stats()
 		}
 
 		c.JSON(status, payload)
 	})
 
-	r.GET("/screenshot", screenshotHandler())
-	r.POST("/screenshot", screenshotHandler())
+	r.GET("/readyz", readyzHandler())
+	r.GET("/metrics", metricsHandler())
+	r.GET("/stats/hosts", hostStatsHandler())
+
+	registerFontRoutes(r)
+	registerTestPageRoutes(r)
+	registerStoredRoutes(r)
+
+	// 需要上游 Chrome 的接口按客户端限流（/health、/fonts、/_test、/stored 不受限）
+	capture := r.Group("", captureMiddlewares()...)
+	registerCDPRecordingRoutes(capture)
+	capture.GET("/browser", browserInfoHandler())
+	capture.GET("/screenshot", screenshotHandler())
+	capture.POST("/screenshot", screenshotHandler())
+	// 批量接口本身不占名额：每一项分别经过限流与并发限制（见 batchHandler）
+	r.POST("/screenshots/batch", batchHandler())
+	capture.POST("/prewarm", prewarmHandler())
+	capture.POST("/pdf", pdfHandler())
+	capture.POST("/article", articleHandler())
+	capture.GET("/assets", assetsHandler())
+	capture.POST("/assets", assetsHandler())
+	capture.GET("/preview", previewHandler())
+	capture.GET("/favicon", faviconHandler())
+	capture.GET("/metadata", metadataHandler())
+	capture.POST("/metadata", metadataHandler())
+	capture.GET("/text", textHandler())
+	capture.POST("/text", textHandler())
+	capture.GET("/coverage", coverageHandler())
+	capture.POST("/coverage", coverageHandler())
+	capture.POST("/blurhash", blurhashHandler())
+	capture.POST("/assert", assertHandler())
 
 	if err := r.Run(":" + port); err != nil {
 		log.Fatalf("server start failed: %v", err)
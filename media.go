@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// media_behavior 取值：控制截图前如何处理 <video>/<audio>。
+const (
+	mediaBehaviorPause  = "pause"
+	mediaBehaviorHide   = "hide"
+	mediaBehaviorPoster = "poster"
+)
+
+func isValidMediaBehavior(v string) bool {
+	switch v {
+	case "", mediaBehaviorPause, mediaBehaviorHide, mediaBehaviorPoster:
+		return true
+	}
+	return false
+}
+
+// mediaBehaviorAction 截图前处理页面中的媒体元素：
+// - pause：暂停并定位到 t=0（等待 seeked，最多 2s）
+// - hide：visibility:hidden（保留布局占位）
+// - poster：有 poster 的 video 替换为同尺寸的 poster 图片，其余按 pause 处理
+func mediaBehaviorAction(behavior string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		js := fmt.Sprintf(`(async () => {
+			const behavior = %q;
+			const seekToStart = (m) => new Promise((resolve) => {
+				try {
+					m.autoplay = false;
+					m.pause();
+					if (m.currentTime === 0) return resolve();
+					const t = setTimeout(resolve, 2000);
+					m.addEventListener('seeked', () => { clearTimeout(t); resolve(); }, { once: true });
+					m.currentTime = 0;
+				} catch (e) { resolve(); }
+			});
+			const waits = [];
+			for (const m of document.querySelectorAll('video, audio')) {
+				if (behavior === 'hide') {
+					m.pause();
+					m.style.setProperty('visibility', 'hidden', 'important');
+					continue;
+				}
+				if (behavior === 'poster' && m.tagName === 'VIDEO' && m.poster) {
+					m.pause();
+					const r = m.getBoundingClientRect();
+					const img = document.createElement('img');
+					img.src = m.poster;
+					img.className = m.className;
+					img.style.cssText = m.style.cssText;
+					img.style.width = r.width + 'px';
+					img.style.height = r.height + 'px';
+					img.style.objectFit = getComputedStyle(m).objectFit || 'contain';
+					m.replaceWith(img);
+					waits.push(img.decode().catch(() => {}));
+					continue;
+				}
+				waits.push(seekToStart(m));
+			}
+			await Promise.all(waits);
+			return true;
+		})()`, behavior)
+		return chromedp.Evaluate(js, nil, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}).Do(ctx)
+	})
+}
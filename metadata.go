@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+// maxFaviconBytes 为服务端拉取图标时允许的最大响应体。
+const maxFaviconBytes = 1 << 20
+
+// Favicon 页面声明的一个图标（link rel=icon/apple-touch-icon 等，以及 /favicon.ico 兜底）。
+type Favicon struct {
+	URL   string `json:"url"`
+	Rel   string `json:"rel"`
+	Sizes string `json:"sizes,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+// FaviconData 为服务端实际拉取到的图标内容。
+type FaviconData struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Base64      string `json:"base64"`
+}
+
+// PageMetadata 为 /metadata 的返回结果。
+type PageMetadata struct {
+	URL         string            `json:"url"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Canonical   string            `json:"canonical"`
+	Lang        string            `json:"lang"`
+	OpenGraph   map[string]string `json:"open_graph"`
+	Twitter     map[string]string `json:"twitter"`
+	JSONLD      []json.RawMessage `json:"json_ld"`
+	Favicons    []Favicon         `json:"favicons"`
+	Favicon     *FaviconData      `json:"favicon,omitempty"`
+}
+
+// pageMetadataJS 读取 og:* / twitter:* / JSON-LD / canonical / 图标声明（同名 meta 取第一个）。
+const pageMetadataJS = `(() => {
+	const og = {};
+	const twitter = {};
+	for (const m of document.querySelectorAll('meta[property], meta[name]')) {
+		const key = (m.getAttribute('property') || m.getAttribute('name') || '').trim().toLowerCase();
+		const val = (m.getAttribute('content') || '').trim();
+		if (!val) continue;
+		if (key.startsWith('og:') && !(key in og)) og[key] = val;
+		if (key.startsWith('twitter:') && !(key in twitter)) twitter[key] = val;
+	}
+	const jsonLD = [];
+	for (const s of document.querySelectorAll('script[type="application/ld+json"]')) {
+		try { jsonLD.push(JSON.parse(s.textContent)); } catch (e) {}
+	}
+	const favicons = [];
+	for (const l of document.querySelectorAll('link[rel][href]')) {
+		const rel = l.rel.toLowerCase();
+		if (!/(^|\s)(icon|apple-touch-icon|apple-touch-icon-precomposed|mask-icon)(\s|$)/.test(rel)) continue;
+		favicons.push({ url: l.href, rel, sizes: l.getAttribute('sizes') || '', type: l.type || '' });
+	}
+	if (/^https?:$/.test(location.protocol)) {
+		favicons.push({ url: location.origin + '/favicon.ico', rel: 'fallback', sizes: '', type: '' });
+	}
+	const desc = document.querySelector('meta[name="description" i]');
+	const canonical = document.querySelector('link[rel="canonical" i]');
+	return {
+		url: location.href,
+		title: document.title,
+		description: desc ? (desc.getAttribute('content') || '').trim() : '',
+		canonical: canonical ? canonical.href : '',
+		lang: document.documentElement.lang || '',
+		open_graph: og,
+		twitter,
+		json_ld: jsonLD,
+		favicons,
+	};
+})()`
+
+// faviconSize 从 sizes 属性中取最大边长（any 视为矢量，返回一个很大的值）。
+func faviconSize(f Favicon) int {
+	if strings.Contains(f.Type, "svg") || strings.HasSuffix(strings.ToLower(f.URL), ".svg") {
+		return 1 << 16
+	}
+	best := 0
+	for _, s := range strings.Fields(strings.ToLower(f.Sizes)) {
+		if s == "any" {
+			return 1 << 16
+		}
+		if w, _, ok := strings.Cut(s, "x"); ok {
+			if n, err := strconv.Atoi(w); err == nil && n > best {
+				best = n
+			}
+		}
+	}
+	if best == 0 && strings.HasPrefix(f.Rel, "apple-touch-icon") {
+		// 未声明尺寸的 apple-touch-icon 按约定为 180x180
+		best = 180
+	}
+	return best
+}
+
+// rankFavicons 按“最适合 size 的优先”排序候选图标：不小于 size 的取最小者，其余按尺寸从大到小；
+// size 为 0 时取最大者。mask-icon（单色 SVG）与 /favicon.ico 兜底放在最后。
+func rankFavicons(icons []Favicon, size int) []Favicon {
+	out := append([]Favicon(nil), icons...)
+	penalty := func(f Favicon) int {
+		switch f.Rel {
+		case "mask-icon":
+			return 2
+		case "fallback":
+			return 1
+		}
+		return 0
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		pi, pj := penalty(out[i]), penalty(out[j])
+		if pi != pj {
+			return pi < pj
+		}
+		si, sj := faviconSize(out[i]), faviconSize(out[j])
+		if size > 0 && (si >= size) != (sj >= size) {
+			return si >= size
+		}
+		if size > 0 && si >= size {
+			return si < sj
+		}
+		return si > sj
+	})
+	return out
+}
+
+// fetchFavicon 由本服务拉取图标（沿用请求的 UA/Header），仅接受图片类型的响应。
+func fetchFavicon(ctx context.Context, req *ScreenshotRequest, iconURL string) (string, []byte, error) {
+	if strings.HasPrefix(iconURL, "data:") {
+		return decodeDataURL(iconURL)
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodGet, iconURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	for k, v := range req.Headers {
+		hreq.Header.Set(k, v)
+	}
+	if req.UserAgent != "" {
+		hreq.Header.Set("User-Agent", req.UserAgent)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(hreq)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", nil, fmt.Errorf("favicon %s: status %d", iconURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconBytes+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(body) > maxFaviconBytes {
+		return "", nil, fmt.Errorf("favicon %s: larger than %d bytes", iconURL, maxFaviconBytes)
+	}
+	ct := resp.Header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err == nil {
+		ct = mt
+	}
+	if !strings.HasPrefix(ct, "image/") {
+		// 不少站点以 text/plain、application/octet-stream 返回 .ico，按内容嗅探
+		ct = http.DetectContentType(body)
+		if strings.HasSuffix(strings.ToLower(resp.Request.URL.Path), ".ico") && !strings.HasPrefix(ct, "text/html") {
+			ct = "image/x-icon"
+		}
+	}
+	if !strings.HasPrefix(ct, "image/") {
+		return "", nil, fmt.Errorf("favicon %s: unexpected content type %s", iconURL, ct)
+	}
+	return ct, body, nil
+}
+
+// decodeDataURL 解析 data: URL（支持 base64 与 URL 编码两种形式）。
+func decodeDataURL(u string) (string, []byte, error) {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(u, "data:"), ",")
+	if !ok {
+		return "", nil, errors.New("invalid data URL")
+	}
+	ct := "text/plain"
+	isBase64 := false
+	for i, part := range strings.Split(meta, ";") {
+		if i == 0 && part != "" {
+			ct = part
+		}
+		if part == "base64" {
+			isBase64 = true
+		}
+	}
+	if isBase64 {
+		b, err := base64.StdEncoding.DecodeString(data)
+		return ct, b, err
+	}
+	s, err := url.PathUnescape(data)
+	return ct, []byte(s), err
+}
+
+// bestFavicon 按 rankFavicons 的顺序依次尝试拉取，返回第一个成功的图标。
+func bestFavicon(ctx context.Context, req *ScreenshotRequest, icons []Favicon, size int) (*FaviconData, []byte, error) {
+	var lastErr error
+	for _, f := range rankFavicons(icons, size) {
+		ct, body, err := fetchFavicon(ctx, req, f.URL)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return &FaviconData{URL: f.URL, ContentType: ct, Base64: base64.StdEncoding.EncodeToString(body)}, body, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no favicon declared")
+	}
+	return nil, nil, lastErr
+}
+
+// normalize 把空集合统一为 [] / {}，保持返回结构稳定。
+func (m *PageMetadata) normalize() {
+	if m.OpenGraph == nil {
+		m.OpenGraph = map[string]string{}
+	}
+	if m.Twitter == nil {
+		m.Twitter = map[string]string{}
+	}
+	if m.JSONLD == nil {
+		m.JSONLD = []json.RawMessage{}
+	}
+	if m.Favicons == nil {
+		m.Favicons = []Favicon{}
+	}
+}
+
+func metadataHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := parseRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.applyDefaults()
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setEffectiveRequestHeader(c, &req)
+
+		viewportWidth, viewportHeight := req.viewportSize()
+
+		overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+
+		sess := openChromeSession(c, overallCtx, "metadataHandler")
+		if sess == nil {
+			return
+		}
+		defer sess.cancel()
+
+		var meta PageMetadata
+		budget := newCaptureBudget(time.Duration(req.Timeout)*time.Second, req.BestEffort)
+		actions := navigationActions(&req, viewportWidth, viewportHeight, budget)
+		actions = append(actions, chromedp.EvaluateAsDevTools(pageMetadataJS, &meta))
+
+		if err := chromedp.Run(sess.ctx, actions...); err != nil {
+			respondRunError(c, err, sess.wsURL, "metadata extraction timeout", "failed to extract metadata")
+			return
+		}
+		meta.normalize()
+
+		if req.Favicon {
+			// 图标拉取失败不影响元数据返回
+			if data, _, err := bestFavicon(overallCtx, &req, meta.Favicons, 0); err == nil {
+				meta.Favicon = data
+			} else {
+				c.Header("X-Favicon-Error", err.Error())
+			}
+		}
+
+		c.JSON(http.StatusOK, meta)
+	}
+}
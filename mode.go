@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	modeThumbnail = "thumbnail"
+	modeArchive   = "archive"
+	modePrint     = "print"
+
+	defaultThumbnailWidth    = 320
+	defaultThumbnailCacheTTL = 10 * time.Minute
+	thumbnailCacheMaxEntries = 2048
+	thumbnailQuality         = 60
+	thumbnailMaxWaitTimeMs   = 500
+	thumbnailMaxTimeoutSec   = 10
+	thumbnailLoadWait        = 1500 * time.Millisecond
+
+	printOutputImage   = "image"
+	printOutputPDF     = "pdf"
+	defaultPrintDPI    = 150
+	minPrintDPI        = 72
+	maxPrintDPI        = 384 // device_scale 最大为 4
+	cssPixelsPerInch   = 96
+	defaultPrintMargin = 0.4 // 英寸，与 Chrome 打印的默认边距一致
+)
+
+// thumbnailBlockedURLs 缩略图模式下屏蔽的重资源（音视频、字体），缩略图尺寸下对观感影响很小。
+var thumbnailBlockedURLs = []string{
+	"*.mp4", "*.webm", "*.ogv", "*.mov", "*.m3u8", "*.mp3", "*.ogg", "*.wav", "*.flac",
+	"*.woff", "*.woff2", "*.ttf", "*.otf", "*.eot",
+}
+
+// thumbnailCache 缩略图模式独立的结果缓存（THUMBNAIL_CACHE_TTL，0 表示不缓存）。
+var thumbnailCache = newTTLCache(thumbnailCacheMaxEntries)
+
+func getThumbnailCacheTTL() time.Duration {
+	return envDuration("THUMBNAIL_CACHE_TTL", defaultThumbnailCacheTTL)
+}
+
+func isValidMode(v string) bool {
+	return v == "" || v == modeThumbnail || v == modeArchive || v == modePrint
+}
+
+// applyMode 按 mode 预设覆盖相关参数；预设优先于请求中的同名参数。
+func (r *ScreenshotRequest) applyMode() error {
+	if !isValidMode(r.Mode) {
+		return errors.New("mode must be one of: thumbnail, archive, print")
+	}
+	if r.Mode != modePrint && (r.PrintDPI != 0 || r.PrintOutput != "") {
+		return errors.New("print_dpi and print_output require mode=print")
+	}
+	switch r.Mode {
+	case modeThumbnail:
+		// 追求延迟（目标 <2s）而非保真度：小尺寸 webp、1x DPR、仅视口、压缩等待并在预算不足时直接截图。
+		for _, err := range []error{
+			r.presetConflict("format", !strings.EqualFold(r.Format, "webp"), "format is fixed to webp in thumbnail mode"),
+			r.presetConflict("quality", r.Quality != thumbnailQuality, "quality is fixed to %d in thumbnail mode", thumbnailQuality),
+			r.presetConflict("device_scale", r.DeviceScale != 1, "device_scale is fixed to 1 in thumbnail mode"),
+			r.presetConflict("full_page", r.FullPage, "full_page is not supported in thumbnail mode"),
+			r.presetConflict("best_effort", !r.BestEffort, "best_effort is always enabled in thumbnail mode"),
+			r.presetConflict("wait_time", r.WaitTime > thumbnailMaxWaitTimeMs, "wait_time must be at most %d in thumbnail mode", thumbnailMaxWaitTimeMs),
+			r.presetConflict("timeout", r.Timeout > thumbnailMaxTimeoutSec, "timeout must be at most %d in thumbnail mode", thumbnailMaxTimeoutSec),
+		} {
+			if err != nil {
+				return err
+			}
+		}
+		r.Format = "webp"
+		r.Quality = thumbnailQuality
+		r.DeviceScale = 1
+		r.FullPage = false
+		r.BestEffort = true
+		if r.WaitTime > thumbnailMaxWaitTimeMs {
+			r.WaitTime = thumbnailMaxWaitTimeMs
+		}
+		if r.Timeout > thumbnailMaxTimeoutSec {
+			r.Timeout = thumbnailMaxTimeoutSec
+		}
+		if r.PreviewWidth == 0 {
+			r.PreviewWidth = defaultThumbnailWidth
+		}
+		if r.PreviewWidth < 64 || r.PreviewWidth > r.Width {
+			return fmt.Errorf("preview_width must be between 64 and width (%d)", r.Width)
+		}
+	case modeArchive:
+		// 保真度优先：整页无损 PNG，等待网络空闲/懒加载/字体与图片就绪，并附带 MHTML 与元数据。
+		for _, err := range []error{
+			r.presetConflict("format", !strings.EqualFold(r.Format, "png"), "format is fixed to png in archive mode"),
+			r.presetConflict("full_page", !r.FullPage, "full_page is always enabled in archive mode"),
+			r.presetConflict("timeout", r.Timeout < archiveMinTimeoutSec, "timeout must be at least %d in archive mode", archiveMinTimeoutSec),
+		} {
+			if err != nil {
+				return err
+			}
+		}
+		r.Format = "png"
+		r.FullPage = true
+		if r.Timeout < archiveMinTimeoutSec {
+			r.Timeout = archiveMinTimeoutSec
+		}
+	case modePrint:
+		return r.applyPrintMode()
+	}
+	return nil
+}
+
+// applyPrintMode 打印预设：模拟 print 媒体类型，视口宽度为纸张可打印宽度（纸张宽度减左右边距，按 96 CSS px/英寸），
+// 输出为整页图片（device_scale = print_dpi / 96）或启用背景图形的 PDF。纸张、方向与边距取自 pdf 对象（默认 A4、0.4in 边距）。
+func (r *ScreenshotRequest) applyPrintMode() error {
+	r.PrintOutput = strings.ToLower(strings.TrimSpace(r.PrintOutput))
+	if r.PrintOutput == "" {
+		r.PrintOutput = printOutputImage
+	}
+	if r.PrintOutput != printOutputImage && r.PrintOutput != printOutputPDF {
+		return errors.New("print_output must be one of: image, pdf")
+	}
+	if r.PrintDPI == 0 {
+		r.PrintDPI = defaultPrintDPI
+	}
+	if r.PrintDPI < minPrintDPI || r.PrintDPI > maxPrintDPI {
+		return fmt.Errorf("print_dpi must be between %d and %d", minPrintDPI, maxPrintDPI)
+	}
+	if r.Selector != "" || r.Clip != nil {
+		return errors.New("mode=print cannot be combined with selector or clip")
+	}
+	if r.PrintOutput == printOutputPDF && (r.Trim || r.Trace) {
+		return errors.New("print_output=pdf cannot be combined with trim or trace")
+	}
+	if r.PDF == nil {
+		r.PDF = &PDFOptions{}
+	}
+	if r.PDF.Margin == nil {
+		m := pdfLength(strconv.FormatFloat(defaultPrintMargin, 'f', -1, 64) + "in")
+		r.PDF.Margin = &PDFMargin{Top: m, Right: m, Bottom: m, Left: m}
+	}
+	r.PDF.PrintBackground = true
+	params, err := r.PDF.printParams()
+	if err != nil {
+		return err
+	}
+	paperWidth := params.PaperWidth
+	if params.Landscape {
+		paperWidth = params.PaperHeight
+	}
+	width := int(math.Round((paperWidth - params.MarginLeft - params.MarginRight) * cssPixelsPerInch))
+	deviceScale := float64(r.PrintDPI) / cssPixelsPerInch
+
+	for _, err := range []error{
+		r.presetConflict("width", r.Width != width, "width is derived from the paper size in print mode (%d)", width),
+		r.presetConflict("device_scale", r.DeviceScale != deviceScale, "device_scale is derived from print_dpi in print mode"),
+		r.presetConflict("full_page", !r.FullPage, "full_page is always enabled in print mode"),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	r.Width = width
+	r.DeviceScale = deviceScale
+	r.FullPage = true
+	return nil
+}
+
+// thumbnailNavigateAction 导航时最多等待 thumbnailLoadWait 的 load 事件，超时后直接使用已渲染的内容。
+func thumbnailNavigateAction(url, referer string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		nctx, cancel := context.WithTimeout(ctx, thumbnailLoadWait)
+		defer cancel()
+		if err := navigateAction(url, referer).Do(nctx); err != nil {
+			if !errors.Is(nctx.Err(), context.DeadlineExceeded) || ctx.Err() != nil {
+				return err
+			}
+		}
+		return chromedp.WaitReady("body", chromedp.ByQuery).Do(ctx)
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	maxNavigateSteps = 10
+	maxNavigateWait  = 30000
+)
+
+// validateNavigate 校验多步导航：navigate 中的地址在同一 tab 中依次访问，只截取最后一个页面
+// （最后一项即截图目标，写入 url）。中间各站必须是 http/https 地址，并受域名策略约束。
+func (r *ScreenshotRequest) validateNavigate() error {
+	if len(r.Navigate) == 0 {
+		if r.NavigateWait != 0 {
+			return errors.New("navigate_wait requires navigate")
+		}
+		return nil
+	}
+	if r.URL != "" || r.HTML != "" {
+		return errors.New("navigate cannot be combined with url or html")
+	}
+	if len(r.Navigate) > maxNavigateSteps {
+		return fmt.Errorf("navigate must contain at most %d URLs", maxNavigateSteps)
+	}
+	if r.NavigateWait < 0 || r.NavigateWait > maxNavigateWait {
+		return fmt.Errorf("navigate_wait must be between 0 and %d", maxNavigateWait)
+	}
+	last := len(r.Navigate) - 1
+	for i, step := range r.Navigate[:last] {
+		normalized, err := normalizeIRI(step)
+		if err != nil {
+			return fmt.Errorf("navigate[%d] must be a valid http/https URL", i)
+		}
+		u, err := url.ParseRequestURI(normalized)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("navigate[%d] must be a valid http/https URL", i)
+		}
+		if hostPolicy != nil {
+			if err := hostPolicy.checkURL(normalized); err != nil {
+				return err
+			}
+		}
+		r.Navigate[i] = normalized
+	}
+	// 最后一项按 url 的规则校验（并做同样的规范化）
+	r.URL = r.Navigate[last]
+	return nil
+}
+
+// navigateStepsActions 依次访问 navigate 的中间各站：每站等待 body 就绪后再等待 navigate_wait 毫秒。
+// referer 只用于第一站（后续导航与在地址栏中输入一致，不带 Referer）。
+func navigateStepsActions(req *ScreenshotRequest, budget *captureBudget) []chromedp.Action {
+	if len(req.Navigate) < 2 {
+		return nil
+	}
+	var actions []chromedp.Action
+	for i, step := range req.Navigate[:len(req.Navigate)-1] {
+		referer := ""
+		if i == 0 {
+			referer = req.Referer
+		}
+		actions = append(actions, budget.wait("navigate", 0, chromedp.Tasks{
+			navigateAction(step, referer),
+			chromedp.WaitReady("body", chromedp.ByQuery),
+		}))
+		if req.NavigateWait > 0 {
+			d := time.Duration(req.NavigateWait) * time.Millisecond
+			actions = append(actions, budget.wait("navigate_wait", d, chromedp.Sleep(d)))
+		}
+	}
+	return actions
+}
+
+// finalReferer 为截图目标导航使用的 referer：多步导航时 referer 已用于第一站。
+func (r *ScreenshotRequest) finalReferer() string {
+	if len(r.Navigate) > 1 {
+		return ""
+	}
+	return r.Referer
+}
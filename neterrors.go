@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var netErrorRe = regexp.MustCompile(`net::(ERR_[A-Z0-9_]+)`)
+
+// navigationError 描述目标站点导航失败（Chrome net::ERR_*）对应的 HTTP 状态与错误码。
+// 这类错误属于“目标站点不可用/被拦截”，与上游 Chrome 连接失败（502）区分开：
+// 目标站点问题使用 424 Failed Dependency，目标超时 504，被拦截 403。
+type navigationError struct {
+	status  int
+	code    string
+	netCode string
+}
+
+// classifyNavigationError 从错误信息中识别 net::ERR_*；不是导航错误时返回 nil。
+func classifyNavigationError(err error) *navigationError {
+	if err == nil {
+		return nil
+	}
+	m := netErrorRe.FindStringSubmatch(err.Error())
+	if m == nil {
+		return nil
+	}
+	netCode := m[1]
+	ne := &navigationError{status: http.StatusFailedDependency, code: "TARGET_NAVIGATION_FAILED", netCode: "net::" + netCode}
+	switch {
+	case netCode == "ERR_NAME_NOT_RESOLVED" || netCode == "ERR_NAME_RESOLUTION_FAILED":
+		ne.code = "TARGET_DNS_FAILED"
+	case netCode == "ERR_CONNECTION_REFUSED":
+		ne.code = "TARGET_CONNECTION_REFUSED"
+	case netCode == "ERR_CONNECTION_TIMED_OUT" || netCode == "ERR_TIMED_OUT":
+		ne.status = http.StatusGatewayTimeout
+		ne.code = "TARGET_TIMEOUT"
+	case netCode == "ERR_CONNECTION_RESET" || netCode == "ERR_CONNECTION_CLOSED" || netCode == "ERR_CONNECTION_FAILED" ||
+		netCode == "ERR_ADDRESS_UNREACHABLE" || netCode == "ERR_INTERNET_DISCONNECTED" || netCode == "ERR_EMPTY_RESPONSE":
+		ne.code = "TARGET_UNREACHABLE"
+	case strings.HasPrefix(netCode, "ERR_CERT_") || strings.HasPrefix(netCode, "ERR_SSL_"):
+		ne.code = "TARGET_TLS_ERROR"
+	case strings.HasPrefix(netCode, "ERR_BLOCKED_BY_"):
+		ne.status = http.StatusForbidden
+		ne.code = "TARGET_BLOCKED"
+	case netCode == "ERR_TOO_MANY_REDIRECTS":
+		ne.code = "TARGET_TOO_MANY_REDIRECTS"
+	case netCode == "ERR_ABORTED":
+		ne.code = "NAVIGATION_ABORTED"
+	}
+	return ne
+}
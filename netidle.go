@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// networkIdleTracker 统计 tab 内进行中的请求数，用于实现类似 Puppeteer networkidle 的等待。
+type networkIdleTracker struct {
+	maxInflight int
+
+	mu        sync.Mutex
+	inflight  map[network.RequestID]struct{}
+	idleSince time.Time
+}
+
+// newNetworkIdleTracker maxInflight 为视为“空闲”时允许的进行中请求数（0 对应 networkidle0，2 对应 networkidle2）。
+func newNetworkIdleTracker(maxInflight int) *networkIdleTracker {
+	return &networkIdleTracker{maxInflight: maxInflight, inflight: map[network.RequestID]struct{}{}, idleSince: time.Now()}
+}
+
+func (t *networkIdleTracker) update() {
+	if len(t.inflight) > t.maxInflight {
+		t.idleSince = time.Time{}
+	} else if t.idleSince.IsZero() {
+		t.idleSince = time.Now()
+	}
+}
+
+// listenAction 开始监听网络事件，需在导航之前执行。
+func (t *networkIdleTracker) listenAction() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		chromedp.ListenTarget(ctx, func(ev interface{}) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			switch e := ev.(type) {
+			case *network.EventRequestWillBeSent:
+				t.inflight[e.RequestID] = struct{}{}
+			case *network.EventLoadingFinished:
+				delete(t.inflight, e.RequestID)
+			case *network.EventLoadingFailed:
+				delete(t.inflight, e.RequestID)
+			default:
+				return
+			}
+			t.update()
+		})
+		return nil
+	})
+}
+
+// waitAction 等待进行中请求数持续不超过 maxInflight 达 idle 时长。
+func (t *networkIdleTracker) waitAction(idle time.Duration) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			t.mu.Lock()
+			since := t.idleSince
+			t.mu.Unlock()
+			if !since.IsZero() && time.Since(since) >= idle {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/chromedp/cdproto/page"
+)
+
+// maxPaginatePages 分页截图最多输出的图片数（超过时返回错误，需增大 paginate_height）。
+const maxPaginatePages = 200
+
+// validatePaginate 校验分页截图参数：需配合 full_page，不支持元素/区域截图、mode 预设、trace 与 trim。
+func (r *ScreenshotRequest) validatePaginate() error {
+	if r.PaginateHeight == 0 {
+		if r.PaginateOverlap != 0 || r.PaginateOutput != "" {
+			return errors.New("paginate_overlap and paginate_output require paginate_height")
+		}
+		return nil
+	}
+	if r.PaginateHeight < 100 || r.PaginateHeight > 10000 {
+		return errors.New("paginate_height must be between 100 and 10000")
+	}
+	if r.PaginateOverlap < 0 || r.PaginateOverlap >= r.PaginateHeight {
+		return errors.New("paginate_overlap must be >= 0 and less than paginate_height")
+	}
+	if !r.FullPage || r.Selector != "" || r.Clip != nil {
+		return errors.New("paginate_height requires full_page and cannot be combined with selector or clip")
+	}
+	if r.Mode != "" || r.Trace || r.Trim {
+		return errors.New("paginate_height cannot be combined with mode, trace or trim")
+	}
+	r.PaginateOutput = strings.ToLower(strings.TrimSpace(r.PaginateOutput))
+	if r.PaginateOutput == "" {
+		r.PaginateOutput = outputZip
+	}
+	switch r.PaginateOutput {
+	case outputZip:
+	case outputPDF:
+		// pdf 对象（纸张、边距等）用于合成 PDF，提前校验
+		if r.PDF == nil {
+			r.PDF = &PDFOptions{}
+		}
+		if _, err := r.PDF.printParams(); err != nil {
+			return err
+		}
+	default:
+		return errors.New("paginate_output must be one of: zip, pdf")
+	}
+	return nil
+}
+
+// capturePages 把整页区域 full（CSS px）按 paginate_height 纵向切片逐张截图，相邻切片重叠 paginate_overlap，
+// 最后一张为剩余高度。切片由 Chrome 直接按 clip 截取，无需在本服务解码大图。
+func capturePages(ctx context.Context, cap *page.CaptureScreenshotParams, full *page.Viewport, req *ScreenshotRequest) ([][]byte, error) {
+	if full == nil {
+		return nil, errors.New("failed to determine page size for pagination")
+	}
+	step := float64(req.PaginateHeight - req.PaginateOverlap)
+	count := 1
+	if full.Height > float64(req.PaginateHeight) {
+		count += int(math.Ceil((full.Height - float64(req.PaginateHeight)) / step))
+	}
+	if count > maxPaginatePages {
+		return nil, fmt.Errorf("page would be split into %d images (max %d), increase paginate_height", count, maxPaginatePages)
+	}
+
+	pages := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		y := float64(i) * step
+		h := math.Min(float64(req.PaginateHeight), full.Height-y)
+		buf, err := cap.WithClip(&page.Viewport{X: full.X, Y: full.Y + y, Width: full.Width, Height: h, Scale: 1}).Do(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+		pages = append(pages, buf)
+	}
+	return pages, nil
+}
+
+// pageFileName 返回第 i 张（从 0 开始）分页图片在 ZIP 中的文件名：page-001.png、page-002.png ...
+func pageFileName(i int, format string) string {
+	ext := "." + format
+	if format == "jpeg" {
+		ext = ".jpg"
+	}
+	return fmt.Sprintf("page-%03d%s", i+1, ext)
+}
+
+// buildPagesZip 按顺序打包分页图片；manifest 非 nil 时（翻页截图）另写入 pages.json。
+func buildPagesZip(pages [][]byte, format string, manifest *ListingManifest) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i, p := range pages {
+		w, err := zw.Create(pageFileName(i, format))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(p); err != nil {
+			return nil, err
+		}
+	}
+	if manifest != nil {
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		w, err := zw.Create("pages.json")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
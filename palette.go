@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultPaletteSize = 5
+	maxPaletteSize     = 16
+)
+
+// validatePalette 校验 palette / palette_size：只对单张图片输出有效。
+func (r *ScreenshotRequest) validatePalette() error {
+	if !r.Palette {
+		if r.PaletteSize != 0 {
+			return errors.New("palette_size requires palette=true")
+		}
+		return nil
+	}
+	if r.PaletteSize == 0 {
+		r.PaletteSize = defaultPaletteSize
+	}
+	if r.PaletteSize < 1 || r.PaletteSize > maxPaletteSize {
+		return fmt.Errorf("palette_size must be between 1 and %d", maxPaletteSize)
+	}
+	if r.PaginateHeight != 0 || r.Paginate != nil || r.Trace || r.Mode == modeArchive ||
+		(r.Mode == modePrint && r.PrintOutput == printOutputPDF) {
+		return errors.New("palette requires an image output and cannot be combined with paginate_height, paginate, trace, mode=archive or print_output=pdf")
+	}
+	return nil
+}
+
+// ColorPalette 为截图的主色与调色板（#rrggbb），调色板按像素占比从高到低排列，第一项即主色。
+type ColorPalette struct {
+	Dominant string   `json:"dominant_color"`
+	Colors   []string `json:"palette"`
+}
+
+// colorBox 为中位切分中的一组像素。
+type colorBox struct {
+	pixels [][3]uint8
+}
+
+// widestChannel 返回取值范围最大的通道及其范围。
+func (b colorBox) widestChannel() (int, int) {
+	ch, width := 0, -1
+	for c := 0; c < 3; c++ {
+		lo, hi := 255, 0
+		for _, p := range b.pixels {
+			lo, hi = min(lo, int(p[c])), max(hi, int(p[c]))
+		}
+		if hi-lo > width {
+			ch, width = c, hi-lo
+		}
+	}
+	return ch, width
+}
+
+func (b colorBox) average() [3]int {
+	var sum [3]int
+	for _, p := range b.pixels {
+		for c := range sum {
+			sum[c] += int(p[c])
+		}
+	}
+	n := len(b.pixels)
+	return [3]int{(sum[0] + n/2) / n, (sum[1] + n/2) / n, (sum[2] + n/2) / n}
+}
+
+// extractPalette 以中位切分（median cut）从缩小后的图片中提取最多 n 种颜色；
+// 近乎透明的像素（alpha < 128）不参与计算，全透明图片返回 nil。
+func extractPalette(s rgbaSample, n int) *ColorPalette {
+	pixels := make([][3]uint8, 0, len(s.Pix)/4)
+	for i := 0; i+3 < len(s.Pix); i += 4 {
+		if s.Pix[i+3] >= 128 {
+			pixels = append(pixels, [3]uint8{s.Pix[i], s.Pix[i+1], s.Pix[i+2]})
+		}
+	}
+	if len(pixels) == 0 {
+		return nil
+	}
+	boxes := []colorBox{{pixels: pixels}}
+	for len(boxes) < n {
+		// 切分像素最多且仍有色差的一组
+		idx := -1
+		for i, b := range boxes {
+			if _, w := b.widestChannel(); w > 0 && len(b.pixels) > 1 && (idx < 0 || len(b.pixels) > len(boxes[idx].pixels)) {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			break
+		}
+		b := boxes[idx]
+		ch, _ := b.widestChannel()
+		sort.Slice(b.pixels, func(i, j int) bool { return b.pixels[i][ch] < b.pixels[j][ch] })
+		mid := len(b.pixels) / 2
+		boxes[idx] = colorBox{pixels: b.pixels[:mid]}
+		boxes = append(boxes, colorBox{pixels: b.pixels[mid:]})
+	}
+	// 中位切分得到的各组像素数接近，按最近颜色重新计数后排序，主色才反映真实占比
+	centers := make([][3]int, len(boxes))
+	for i, b := range boxes {
+		centers[i] = b.average()
+	}
+	counts := make([]int, len(centers))
+	for _, p := range pixels {
+		best, bestDist := 0, -1
+		for i, c := range centers {
+			dr, dg, db := int(p[0])-c[0], int(p[1])-c[1], int(p[2])-c[2]
+			if d := dr*dr + dg*dg + db*db; bestDist < 0 || d < bestDist {
+				best, bestDist = i, d
+			}
+		}
+		counts[best]++
+	}
+	order := make([]int, len(centers))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] > counts[order[j]] })
+
+	out := &ColorPalette{}
+	seen := make(map[string]bool, len(centers))
+	for _, i := range order {
+		c := centers[i]
+		if hex := fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2]); counts[i] > 0 && !seen[hex] {
+			seen[hex] = true
+			out.Colors = append(out.Colors, hex)
+		}
+	}
+	out.Dominant = out.Colors[0]
+	return out
+}
+
+// paletteHeader 返回 X-Palette 响应头的值（逗号分隔）。
+func (p *ColorPalette) paletteHeader() string {
+	return strings.Join(p.Colors, ",")
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+// pdfPaperSizes 常用纸张尺寸（英寸，纵向）。
+var pdfPaperSizes = map[string][2]float64{
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+	"ledger":  {17, 11},
+	"a0":      {33.1, 46.8},
+	"a1":      {23.4, 33.1},
+	"a2":      {16.54, 23.4},
+	"a3":      {11.7, 16.54},
+	"a4":      {8.27, 11.7},
+	"a5":      {5.83, 8.27},
+	"a6":      {4.13, 5.83},
+}
+
+// pdfLength 表示一个长度：JSON 数字按 px 解释，字符串支持 px/in/cm/mm 单位（如 "1cm"、"0.5in"）。
+type pdfLength string
+
+func (l *pdfLength) UnmarshalJSON(b []byte) error {
+	var n float64
+	if err := json.Unmarshal(b, &n); err == nil {
+		*l = pdfLength(strconv.FormatFloat(n, 'f', -1, 64))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.New("length must be a number (px) or a string with unit (px/in/cm/mm)")
+	}
+	*l = pdfLength(s)
+	return nil
+}
+
+// inches 将长度换算为英寸（CDP PrintToPDF 的单位）。
+func (l pdfLength) inches() (float64, error) {
+	s := strings.ToLower(strings.TrimSpace(string(l)))
+	if s == "" {
+		return 0, nil
+	}
+	unit := 1.0 / 96 // 默认 px
+	for suffix, factor := range map[string]float64{"px": 1.0 / 96, "in": 1, "cm": 1 / 2.54, "mm": 1 / 25.4} {
+		if strings.HasSuffix(s, suffix) {
+			unit = factor
+			s = strings.TrimSpace(strings.TrimSuffix(s, suffix))
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid length %q", string(l))
+	}
+	return v * unit, nil
+}
+
+type PDFMargin struct {
+	Top    pdfLength `json:"top"`
+	Right  pdfLength `json:"right"`
+	Bottom pdfLength `json:"bottom"`
+	Left   pdfLength `json:"left"`
+}
+
+// PDFOptions 为 /pdf 的打印参数。
+type PDFOptions struct {
+	PaperFormat         string     `json:"paper_format"`
+	PaperWidth          pdfLength  `json:"paper_width"`
+	PaperHeight         pdfLength  `json:"paper_height"`
+	Margin              *PDFMargin `json:"margin"`
+	Landscape           bool       `json:"landscape"`
+	PageRanges          string     `json:"page_ranges"`
+	HeaderTemplate      string     `json:"header_template"`
+	FooterTemplate      string     `json:"footer_template"`
+	DisplayHeaderFooter bool       `json:"display_header_footer"`
+	PrintBackground     bool       `json:"print_background"`
+	Scale               float64    `json:"scale"`
+	PreferCSSPageSize   bool       `json:"prefer_css_page_size"`
+}
+
+// printParams 校验打印参数并构造 Page.printToPDF 请求。
+func (o *PDFOptions) printParams() (*page.PrintToPDFParams, error) {
+	p := page.PrintToPDF().
+		WithLandscape(o.Landscape).
+		WithPrintBackground(o.PrintBackground).
+		WithPreferCSSPageSize(o.PreferCSSPageSize).
+		WithPageRanges(strings.TrimSpace(o.PageRanges))
+
+	format := strings.ToLower(strings.TrimSpace(o.PaperFormat))
+	if format == "" {
+		format = "a4"
+	}
+	size, ok := pdfPaperSizes[format]
+	if !ok {
+		return nil, fmt.Errorf("paper_format must be one of: letter, legal, tabloid, ledger, a0-a6")
+	}
+	w, h := size[0], size[1]
+	if o.PaperWidth != "" || o.PaperHeight != "" {
+		var err error
+		if w, err = o.PaperWidth.inches(); err != nil || w <= 0 {
+			return nil, errors.New("paper_width must be a positive length")
+		}
+		if h, err = o.PaperHeight.inches(); err != nil || h <= 0 {
+			return nil, errors.New("paper_height must be a positive length")
+		}
+	}
+	p = p.WithPaperWidth(w).WithPaperHeight(h)
+
+	if o.Margin != nil {
+		top, err := o.Margin.Top.inches()
+		if err != nil {
+			return nil, fmt.Errorf("margin.top: %w", err)
+		}
+		right, err := o.Margin.Right.inches()
+		if err != nil {
+			return nil, fmt.Errorf("margin.right: %w", err)
+		}
+		bottom, err := o.Margin.Bottom.inches()
+		if err != nil {
+			return nil, fmt.Errorf("margin.bottom: %w", err)
+		}
+		left, err := o.Margin.Left.inches()
+		if err != nil {
+			return nil, fmt.Errorf("margin.left: %w", err)
+		}
+		p = p.WithMarginTop(top).WithMarginRight(right).WithMarginBottom(bottom).WithMarginLeft(left)
+	}
+
+	if o.Scale != 0 {
+		if o.Scale < 0.1 || o.Scale > 2 {
+			return nil, errors.New("scale must be between 0.1 and 2")
+		}
+		p = p.WithScale(o.Scale)
+	}
+
+	if o.DisplayHeaderFooter || o.HeaderTemplate != "" || o.FooterTemplate != "" {
+		// Chrome 对未提供的模板会使用默认页眉/页脚（日期、标题等），这里用空元素占位以仅显示用户提供的部分。
+		header, footer := o.HeaderTemplate, o.FooterTemplate
+		if header == "" {
+			header = "<span></span>"
+		}
+		if footer == "" {
+			footer = "<span></span>"
+		}
+		p = p.WithDisplayHeaderFooter(true).WithHeaderTemplate(header).WithFooterTemplate(footer)
+	}
+	return p, nil
+}
+
+func pdfHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := parseRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.applyDefaults()
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.PDF == nil {
+			req.PDF = &PDFOptions{}
+		}
+		printParams, err := req.PDF.printParams()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setEffectiveRequestHeader(c, &req)
+
+		viewportWidth, viewportHeight := req.viewportSize()
+
+		overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+
+		sess := openChromeSession(c, overallCtx, "pdfHandler")
+		if sess == nil {
+			return
+		}
+		defer sess.cancel()
+
+		var pdf []byte
+		budget := newCaptureBudget(time.Duration(req.Timeout)*time.Second, req.BestEffort)
+		actions := navigationActions(&req, viewportWidth, viewportHeight, budget)
+		actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+			buf, _, err := printParams.Do(ctx)
+			if err != nil {
+				return err
+			}
+			pdf = buf
+			return nil
+		}))
+
+		if err := chromedp.Run(sess.ctx, actions...); err != nil {
+			respondRunError(c, err, sess.wsURL, "pdf timeout", "failed to print pdf")
+			return
+		}
+
+		if skipped := budget.skippedStages(); len(skipped) > 0 {
+			c.Header("X-Budget-Exceeded", strings.Join(skipped, ","))
+		}
+		c.Data(http.StatusOK, "application/pdf", pdf)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// playwrightVersion 为本客户端实现的 Playwright server 协议版本。协议随 minor 版本变化（命令参数与对象初始化字段），
+// 连接时通过 User-Agent 告知 server，major.minor 不同的 server 会以 428 拒绝握手，避免按错误的协议执行。
+const playwrightVersion = "1.49.1"
+
+// playwrightEndpoints 为 PLAYWRIGHT_WS_URL（逗号分隔）中的 Playwright server 地址（`playwright run-server`）。
+// server 为每个连接启动独立的浏览器，因此不需要独占，按顺序轮流使用。
+var playwrightEndpoints = splitEndpointList(os.Getenv("PLAYWRIGHT_WS_URL"))
+
+var playwrightNext atomic.Uint64
+
+// playwrightBackend 经 Playwright server 截图；browser 为 server 启动的浏览器（chromium / firefox）。
+type playwrightBackend struct {
+	browser string
+}
+
+func (playwrightBackend) name() string     { return backendPlaywright }
+func (b playwrightBackend) engine() string { return b.browser }
+
+func (playwrightBackend) acquire(ctx context.Context) (string, func(), error) {
+	if ctx.Err() != nil {
+		return "", nil, context.Cause(ctx)
+	}
+	n := playwrightNext.Add(1) - 1
+	return playwrightEndpoints[n%uint64(len(playwrightEndpoints))], func() {}, nil
+}
+
+func (b playwrightBackend) capture(ctx context.Context, wsURL string, req *ScreenshotRequest) ([]byte, bool, error) {
+	return playwrightCapture(ctx, wsURL, b.browser, req)
+}
+
+// playwrightClient 为 Playwright server 协议的最小客户端：请求为 {id, guid, method, params}，
+// 服务端通过 __create__ 消息创建对象（guid + initializer）；命令按顺序同步执行，其他事件直接丢弃。
+type playwrightClient struct {
+	conn         net.Conn
+	rw           io.ReadWriter
+	nextID       int64
+	initializers map[string]json.RawMessage
+}
+
+// playwrightRef 为协议中对对象的引用。
+type playwrightRef struct {
+	GUID string `json:"guid"`
+}
+
+// playwrightError 为命令返回的错误；name 为 TimeoutError 时按超时处理。
+type playwrightError struct {
+	method  string
+	name    string
+	message string
+}
+
+func (e *playwrightError) Error() string {
+	return fmt.Sprintf("playwright %s: %s", e.method, e.message)
+}
+
+func (e *playwrightError) Unwrap() error {
+	if e.name == "TimeoutError" {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// dialPlaywright 连接 Playwright server，并通过 x-playwright-browser 请求它启动 browser。
+func dialPlaywright(ctx context.Context, wsURL, browser string) (*playwrightClient, error) {
+	var mismatch string
+	d := ws.Dialer{
+		Header: ws.HandshakeHeaderHTTP(http.Header{
+			"User-Agent":                  []string{"Playwright/" + playwrightVersion + " (screenshot-server)"},
+			"X-Playwright-Browser":        []string{browser},
+			"X-Playwright-Launch-Options": []string{`{"headless":true}`},
+		}),
+		OnStatusError: func(status int, _ []byte, resp io.Reader) {
+			if status != http.StatusPreconditionRequired {
+				return
+			}
+			mismatch = "version mismatch"
+			if r, err := http.ReadResponse(bufio.NewReader(resp), nil); err == nil {
+				body, _ := io.ReadAll(io.LimitReader(r.Body, 4096))
+				if msg := strings.TrimSpace(string(body)); msg != "" {
+					mismatch = msg
+				}
+			}
+		},
+	}
+	conn, br, _, err := d.Dial(ctx, wsURL)
+	if err != nil {
+		if mismatch != "" {
+			return nil, fmt.Errorf("playwright server rejected client version %s (run playwright %s run-server): %s", playwrightVersion, playwrightVersion, mismatch)
+		}
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	var r io.Reader = conn
+	if br != nil {
+		r = io.MultiReader(br, conn)
+	}
+	return &playwrightClient{
+		conn: conn,
+		rw: struct {
+			io.Reader
+			io.Writer
+		}{r, conn},
+		initializers: map[string]json.RawMessage{},
+	}, nil
+}
+
+// call 向 guid 对象发送命令并等待对应 id 的响应；result 为 nil 时忽略返回值。
+func (p *playwrightClient) call(guid, method string, params, result interface{}) error {
+	p.nextID++
+	id := p.nextID
+	msg, err := json.Marshal(map[string]interface{}{
+		"id":       id,
+		"guid":     guid,
+		"method":   method,
+		"params":   params,
+		"metadata": map[string]interface{}{"wallTime": time.Now().UnixMilli()},
+	})
+	if err != nil {
+		return err
+	}
+	if err := wsutil.WriteClientText(p.rw, msg); err != nil {
+		return err
+	}
+	for {
+		data, err := wsutil.ReadServerText(p.rw)
+		if err != nil {
+			return err
+		}
+		var resp struct {
+			ID     int64           `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+			Error  *struct {
+				Error struct {
+					Name    string `json:"name"`
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return err
+		}
+		if resp.ID == 0 {
+			if resp.Method == "__create__" {
+				var created struct {
+					GUID        string          `json:"guid"`
+					Initializer json.RawMessage `json:"initializer"`
+				}
+				if json.Unmarshal(resp.Params, &created) == nil {
+					p.initializers[created.GUID] = created.Initializer
+				}
+			}
+			continue
+		}
+		if resp.ID != id {
+			continue
+		}
+		if resp.Error != nil {
+			return &playwrightError{method: method, name: resp.Error.Error.Name, message: resp.Error.Error.Message}
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	}
+}
+
+// initializer 解析 guid 对象创建时的 initializer。
+func (p *playwrightClient) initializer(guid string, v interface{}) error {
+	raw, ok := p.initializers[guid]
+	if !ok {
+		return fmt.Errorf("playwright: unknown object %s", guid)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// evaluateString 在 frame 中求值返回字符串的表达式。
+func (p *playwrightClient) evaluateString(frame, expr string) (string, error) {
+	var res struct {
+		Value struct {
+			S string `json:"s"`
+		} `json:"value"`
+	}
+	err := p.call(frame, "evaluateExpression", map[string]interface{}{
+		"expression": expr,
+		"isFunction": false,
+		"arg":        map[string]interface{}{"value": map[string]string{"v": "undefined"}, "handles": []interface{}{}},
+	}, &res)
+	return res.Value.S, err
+}
+
+// playwrightTimeout 为 ctx 剩余的毫秒数，作为命令的 timeout 参数。
+func playwrightTimeout(ctx context.Context) float64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	return math.Max(1, float64(time.Until(deadline).Milliseconds()))
+}
+
+// playwrightCapture 在 Playwright server 启动的浏览器中新建 context/page、导航并截图；
+// full_page 时超出像素预算则按预算截断高度。
+func playwrightCapture(ctx context.Context, wsURL, browser string, req *ScreenshotRequest) (img []byte, truncated bool, err error) {
+	p, err := dialPlaywright(ctx, wsURL, browser)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", errBackendDial, err)
+	}
+	defer p.conn.Close()
+	// 超时后关闭连接，使阻塞中的读写立即返回
+	stop := context.AfterFunc(ctx, func() { p.conn.Close() })
+	defer stop()
+
+	var root struct {
+		Playwright playwrightRef `json:"playwright"`
+	}
+	if err := p.call("", "initialize", map[string]string{"sdkLanguage": "javascript"}, &root); err != nil {
+		return nil, false, fmt.Errorf("%w: %v", errBackendDial, err)
+	}
+	var pw struct {
+		PreLaunchedBrowser *playwrightRef `json:"preLaunchedBrowser"`
+	}
+	if err := p.initializer(root.Playwright.GUID, &pw); err != nil {
+		return nil, false, err
+	}
+	if pw.PreLaunchedBrowser == nil {
+		return nil, false, fmt.Errorf("%w: server did not launch %s (expected `playwright run-server`)", errBackendDial, browser)
+	}
+
+	w, h := req.viewportSize()
+	var created struct {
+		Context playwrightRef `json:"context"`
+	}
+	if err := p.call(pw.PreLaunchedBrowser.GUID, "newContext", map[string]interface{}{
+		"viewport":            map[string]int64{"width": w, "height": h},
+		"deviceScaleFactor":   req.DeviceScale,
+		"selectorEngines":     []interface{}{},
+		"testIdAttributeName": "data-testid",
+	}, &created); err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = p.call(created.Context.GUID, "close", map[string]interface{}{}, nil) }()
+
+	var opened struct {
+		Page playwrightRef `json:"page"`
+	}
+	if err := p.call(created.Context.GUID, "newPage", map[string]interface{}{}, &opened); err != nil {
+		return nil, false, err
+	}
+	var pageInit struct {
+		MainFrame playwrightRef `json:"mainFrame"`
+	}
+	if err := p.initializer(opened.Page.GUID, &pageInit); err != nil {
+		return nil, false, err
+	}
+	frame := pageInit.MainFrame.GUID
+
+	waitUntil := waitUntilLoad
+	if req.WaitUntil == waitUntilDOMContentLoaded {
+		waitUntil = waitUntilDOMContentLoaded
+	}
+	var nav struct {
+		Response *playwrightRef `json:"response"`
+	}
+	if err := p.call(frame, "goto", map[string]interface{}{"url": req.URL, "waitUntil": waitUntil, "timeout": playwrightTimeout(ctx)}, &nav); err != nil {
+		var pe *playwrightError
+		if errors.As(err, &pe) && !errors.Is(err, context.DeadlineExceeded) {
+			return nil, false, &backendNavigationError{err: err}
+		}
+		return nil, false, err
+	}
+	if nav.Response != nil {
+		var resp struct {
+			Status int64 `json:"status"`
+		}
+		if p.initializer(nav.Response.GUID, &resp) == nil {
+			req.output.status.Store(resp.Status)
+		}
+	}
+	if req.WaitTime > 0 {
+		select {
+		case <-time.After(time.Duration(req.WaitTime) * time.Millisecond):
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+
+	// clip 为页面坐标，Playwright 只在 fullPage 时按页面坐标解释 clip
+	shot := map[string]interface{}{"type": req.Format, "fullPage": req.FullPage || req.Clip != nil}
+	if req.Format == "jpeg" {
+		shot["quality"] = req.Quality
+	}
+	var clip *page.Viewport
+	if req.Clip != nil {
+		clip = &page.Viewport{X: req.Clip.X, Y: req.Clip.Y, Width: req.Clip.Width, Height: req.Clip.Height}
+	} else if req.FullPage && maxCapturePixels > 0 {
+		size, err := p.evaluateString(frame, `JSON.stringify([
+			Math.max(document.documentElement.scrollWidth, document.body ? document.body.scrollWidth : 0),
+			Math.max(document.documentElement.scrollHeight, document.body ? document.body.scrollHeight : 0)])`)
+		if err != nil {
+			return nil, false, err
+		}
+		var dims [2]float64
+		if err := json.Unmarshal([]byte(size), &dims); err != nil {
+			return nil, false, err
+		}
+		full := &page.Viewport{Width: math.Max(dims[0], float64(w)), Height: math.Max(dims[1], float64(h))}
+		if truncated = limitCapturePixels(full, req.DeviceScale); truncated {
+			clip = full
+		}
+	}
+	if clip != nil {
+		shot["clip"] = map[string]float64{"x": clip.X, "y": clip.Y, "width": clip.Width, "height": clip.Height}
+	}
+	shot["timeout"] = playwrightTimeout(ctx)
+	var captured struct {
+		Binary string `json:"binary"`
+	}
+	if err := p.call(opened.Page.GUID, "screenshot", shot, &captured); err != nil {
+		return nil, false, err
+	}
+	img, err = base64.StdEncoding.DecodeString(captured.Binary)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 页面信息只用于响应头，读取失败不影响结果
+	if info, err := p.evaluateString(frame, `JSON.stringify({url: location.href, title: document.title})`); err == nil {
+		_ = json.Unmarshal([]byte(info), &req.output.page)
+	}
+	return img, truncated, nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+type transcriptLine struct {
+	Dir string          `json:"dir"`
+	Msg json.RawMessage `json:"msg"`
+}
+
+// loadTranscript 读取 testdata 中的 Playwright server 会话记录：send 为客户端应发出的命令，recv 为服务端随后的消息。
+func loadTranscript(t *testing.T, path string) []transcriptLine {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []transcriptLine
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var l transcriptLine
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, l)
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+// normalizeCommand 去掉每次运行都不同的字段（metadata、timeout），便于与记录比较。
+func normalizeCommand(t *testing.T, raw []byte) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal(raw, &m); err != nil {
+		t.Fatalf("invalid command %s: %v", raw, err)
+	}
+	delete(m, "metadata")
+	if params, ok := m["params"].(map[string]any); ok {
+		delete(params, "timeout")
+	}
+	return m
+}
+
+// transcriptServer 按记录回放 Playwright server：校验客户端的每条命令与记录一致，并依次返回记录的消息。
+func transcriptServer(t *testing.T, lines []transcriptLine) (*httptest.Server, func() int) {
+	var mu sync.Mutex
+	next := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ua := r.Header.Get("User-Agent"); !strings.HasPrefix(ua, "Playwright/1.49.") {
+			t.Errorf("User-Agent = %q, want Playwright/1.49.x", ua)
+		}
+		if got := r.Header.Get("X-Playwright-Browser"); got != "chromium" {
+			t.Errorf("X-Playwright-Browser = %q", got)
+		}
+		conn, _, _, err := ws.UpgradeHTTP(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			data, err := wsutil.ReadClientText(conn)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			if next >= len(lines) || lines[next].Dir != "send" {
+				mu.Unlock()
+				t.Errorf("unexpected command %s", data)
+				return
+			}
+			got, want := normalizeCommand(t, data), normalizeCommand(t, lines[next].Msg)
+			if !reflect.DeepEqual(got, want) {
+				mu.Unlock()
+				t.Errorf("command %d:\n got  %v\n want %v", next, got, want)
+				return
+			}
+			next++
+			var out []json.RawMessage
+			for next < len(lines) && lines[next].Dir == "recv" {
+				out = append(out, lines[next].Msg)
+				next++
+			}
+			mu.Unlock()
+			for _, msg := range out {
+				if err := wsutil.WriteServerText(conn, msg); err != nil {
+					return
+				}
+			}
+		}
+	}))
+	return srv, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return next
+	}
+}
+
+func TestPlaywrightCaptureTranscript(t *testing.T) {
+	lines := loadTranscript(t, "testdata/playwright-1.49.transcript.jsonl")
+	srv, consumed := transcriptServer(t, lines)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := &ScreenshotRequest{URL: "https://example.com/", Width: 1280, Height: 720, DeviceScale: 1, Format: "png", output: &outputInfo{}}
+	img, truncated, err := playwrightCapture(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), "chromium", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(img, []byte("\x89PNG")) {
+		t.Errorf("image is not a PNG: % x", img[:min(8, len(img))])
+	}
+	if truncated {
+		t.Error("truncated = true")
+	}
+	if got := req.output.status.Load(); got != 200 {
+		t.Errorf("status = %d, want 200", got)
+	}
+	if want := (pageInfo{URL: "https://example.com/", Title: "Example Domain"}); req.output.page != want {
+		t.Errorf("page = %+v, want %+v", req.output.page, want)
+	}
+	if n := consumed(); n != len(lines) {
+		t.Errorf("consumed %d of %d transcript lines", n, len(lines))
+	}
+}
+
+func TestPlaywrightVersionMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPreconditionRequired)
+		_, _ = w.Write([]byte("Playwright version mismatch:\n  - server version: v1.50\n  - client version: v1.49"))
+	}))
+	defer srv.Close()
+
+	_, _, err := playwrightCapture(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), "chromium", &ScreenshotRequest{output: &outputInfo{}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"rejected client version " + playwrightVersion, "server version: v1.50"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	defaultBrowserPoolMaxAge  = 5 * time.Minute
+	browserPoolHealthInterval = 30 * time.Second
+	browserPoolHealthTimeout  = 3 * time.Second
+)
+
+// chromePool 为进程级连接池；BROWSER_POOL_SIZE 未配置（或为 0）时为 nil，每个请求单独建立连接。
+var chromePool = newBrowserPoolFromEnv()
+
+// pooledBrowser 一条已建立的远程 Chrome 连接。
+type pooledBrowser struct {
+	wsURL   string
+	ctx     context.Context // 根 chromedp context（已完成 dial），请求在其下新建 tab
+	cancel  func()
+	created time.Time
+}
+
+func (b *pooledBrowser) expired(maxAge time.Duration) bool {
+	return time.Since(b.created) > maxAge
+}
+
+// healthy 通过 Browser.getVersion 检查连接是否仍然可用。
+func (b *pooledBrowser) healthy() bool {
+	if b.ctx.Err() != nil {
+		return false
+	}
+	c := chromedp.FromContext(b.ctx)
+	if c == nil || c.Browser == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(b.ctx, browserPoolHealthTimeout)
+	defer cancel()
+	_, _, _, _, _, err := browser.GetVersion().Do(cdp.WithExecutor(ctx, c.Browser))
+	return err == nil
+}
+
+// browserPool 复用已建立的远程 Chrome 连接，省去每个请求的 websocket dial 与（browserless 下的）浏览器启动。
+// 请求借用一条连接，在其中以新的 BrowserContext 打开 tab（cookie/缓存/存储彼此隔离），用完关闭 tab 并归还连接。
+// 空闲连接超过 maxAge 或健康检查失败时丢弃，后台按 size 补足。
+type browserPool struct {
+	size   int
+	maxAge time.Duration
+
+	mu     sync.Mutex
+	idle   []*pooledBrowser
+	inUse  int
+	dials  uint64
+	reuses uint64
+}
+
+// newBrowserPoolFromEnv 读取 BROWSER_POOL_SIZE / BROWSER_POOL_MAX_AGE（Go duration）。
+func newBrowserPoolFromEnv() *browserPool {
+	size, err := strconv.Atoi(strings.TrimSpace(os.Getenv("BROWSER_POOL_SIZE")))
+	if err != nil || size <= 0 {
+		return nil
+	}
+	maxAge := defaultBrowserPoolMaxAge
+	if d, err := time.ParseDuration(strings.TrimSpace(os.Getenv("BROWSER_POOL_MAX_AGE"))); err == nil && d > 0 {
+		maxAge = d
+	}
+	return &browserPool{size: size, maxAge: maxAge}
+}
+
+// dialPooledBrowser 建立一条不随请求结束的连接（根 context 不带 deadline）。
+func dialPooledBrowser(wsURL string) (*pooledBrowser, error) {
+	allocCtx, allocCancel := chromedp.NewRemoteAllocator(context.Background(), wsURL, chromedp.NoModifyURL)
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	b := &pooledBrowser{wsURL: wsURL, ctx: ctx, created: time.Now(), cancel: func() {
+		cancel()
+		allocCancel()
+	}}
+	if err := dialChrome(ctx); err != nil {
+		b.cancel()
+		return nil, err
+	}
+	return b, nil
+}
+
+// acquire 取出一条可用的空闲连接，没有时新建（允许超出 size，归还时多余的连接会被关闭）。
+func (p *browserPool) acquire(wsURL string) (*pooledBrowser, error) {
+	p.mu.Lock()
+	// 多个上游时空闲列表中混有不同 endpoint 的连接，只取与 wsURL 一致的
+	for i := len(p.idle) - 1; i >= 0; i-- {
+		b := p.idle[i]
+		if b.wsURL != wsURL {
+			continue
+		}
+		p.idle = append(p.idle[:i], p.idle[i+1:]...)
+		if b.ctx.Err() != nil || b.expired(p.maxAge) {
+			go b.cancel()
+			continue
+		}
+		p.inUse++
+		p.reuses++
+		p.mu.Unlock()
+		return b, nil
+	}
+	p.mu.Unlock()
+
+	b, err := dialPooledBrowser(wsURL)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.inUse++
+	p.dials++
+	p.mu.Unlock()
+	return b, nil
+}
+
+func (p *browserPool) release(b *pooledBrowser) {
+	p.mu.Lock()
+	p.inUse--
+	p.mu.Unlock()
+	p.put(b)
+}
+
+// put 将连接放回空闲列表；连接已失效、过期或空闲数已满时直接关闭。
+func (p *browserPool) put(b *pooledBrowser) {
+	p.mu.Lock()
+	keep := b.ctx.Err() == nil && !b.expired(p.maxAge) && len(p.idle) < p.size
+	if keep {
+		p.idle = append(p.idle, b)
+	}
+	p.mu.Unlock()
+	if !keep {
+		b.cancel()
+	}
+}
+
+// session 借用一条连接并在新的 BrowserContext 中打开 tab；tab 的 deadline 与 overallCtx 一致。
+func (p *browserPool) session(overallCtx context.Context, wsURL string) (*chromeSession, error) {
+	b, err := p.acquire(wsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	tabCtx, tabCancel := chromedp.NewContext(b.ctx, chromedp.WithNewBrowserContext())
+	taskCtx, taskCancel := context.WithCancel(tabCtx)
+	if deadline, ok := overallCtx.Deadline(); ok {
+		taskCtx, taskCancel = context.WithDeadline(tabCtx, deadline)
+	}
+	stop := context.AfterFunc(overallCtx, taskCancel)
+	cancelAll := func() {
+		stop()
+		taskCancel()
+		tabCancel()
+		p.release(b)
+	}
+
+	// 创建 tab（失败通常意味着连接已失效，归还时会被丢弃）
+	if err := chromedp.Run(taskCtx); err != nil {
+		cancelAll()
+		return nil, err
+	}
+	return &chromeSession{ctx: taskCtx, wsURL: wsURL, cancel: cancelAll}, nil
+}
+
+// maintain 定期清理过期/失效的空闲连接，并按 size 预先建立连接。
+func (p *browserPool) maintain() {
+	for {
+		p.mu.Lock()
+		idle := p.idle
+		p.idle = nil
+		p.mu.Unlock()
+
+		var alive []*pooledBrowser
+		for _, b := range idle {
+			if !b.expired(p.maxAge) && b.healthy() {
+				alive = append(alive, b)
+				continue
+			}
+			b.cancel()
+		}
+
+		p.mu.Lock()
+		p.idle = append(p.idle, alive...)
+		missing := p.size - len(p.idle) - p.inUse
+		p.mu.Unlock()
+
+		// 每条连接单独解析 endpoint，多个上游时预热连接按负载均衡策略分布
+		// 熔断打开期间由熔断器负责探测，这里不再预热
+		if upstreamBreaker != nil && upstreamBreaker.isOpen() {
+			missing = 0
+		}
+		for i := 0; i < missing; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			wsURL, configured, err := resolveWSEndpoint(ctx)
+			cancel()
+			if !configured || err != nil {
+				break
+			}
+			b, err := dialPooledBrowser(wsURL)
+			if err != nil {
+				upstreams.reportFailure(wsURL, err)
+				log.Printf("browserPool: prewarm failed: %s", redactURLsInString(err.Error()))
+				break
+			}
+			p.mu.Lock()
+			p.dials++
+			p.mu.Unlock()
+			p.put(b)
+		}
+
+		time.Sleep(browserPoolHealthInterval)
+	}
+}
+
+// stats 返回连接池状态，用于 /health。
+func (p *browserPool) stats() map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return map[string]interface{}{
+		"size":    p.size,
+		"max_age": p.maxAge.String(),
+		"idle":    len(p.idle),
+		"in_use":  p.inUse,
+		"dials":   p.dials,
+		"reuses":  p.reuses,
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	defaultPostContentType = "application/x-www-form-urlencoded"
+	maxPostBodyBytes       = 1 << 20
+)
+
+// validateMethod 校验导航方法：method=POST 时 body / content_type 作为首次导航的请求体与 Content-Type
+// （content_type 默认 application/x-www-form-urlencoded），仅适用于 url。
+func (r *ScreenshotRequest) validateMethod() error {
+	r.Method = strings.ToUpper(strings.TrimSpace(r.Method))
+	r.ContentType = strings.TrimSpace(r.ContentType)
+	switch r.Method {
+	case "", http.MethodGet:
+		r.Method = ""
+		if r.Body != "" || r.ContentType != "" {
+			return errors.New("body and content_type require method=POST")
+		}
+		return nil
+	case http.MethodPost:
+		if r.HTML != "" {
+			return errors.New("method=POST cannot be combined with html")
+		}
+		if len(r.Body) > maxPostBodyBytes {
+			return errors.New("body must be at most 1MB")
+		}
+		if r.ContentType == "" {
+			r.ContentType = defaultPostContentType
+		}
+		return nil
+	default:
+		return errors.New("method must be one of: GET, POST")
+	}
+}
+
+// navRewrite 为待改写的首次导航请求（按 tab 登记，一次性）。
+type navRewrite struct {
+	frameID     cdp.FrameID
+	method      string
+	body        string
+	contentType string
+}
+
+// navRewrites: target ID -> *navRewrite。域名策略的拦截与 POST 改写共用同一个 Fetch 拦截，
+// 由 continuePausedRequest 统一放行，避免两个监听者对同一请求重复 continue。
+var navRewrites sync.Map
+
+// takeNavRewrite 取出当前 tab 待改写的导航；只匹配主框架的文档请求，重定向后的请求不再改写。
+func takeNavRewrite(ctx context.Context, e *fetch.EventRequestPaused) *navRewrite {
+	c := chromedp.FromContext(ctx)
+	if c == nil || c.Target == nil || e.ResourceType != network.ResourceTypeDocument {
+		return nil
+	}
+	v, ok := navRewrites.Load(c.Target.TargetID)
+	if !ok || v.(*navRewrite).frameID != e.FrameID {
+		return nil
+	}
+	if !navRewrites.CompareAndDelete(c.Target.TargetID, v) {
+		return nil
+	}
+	return v.(*navRewrite)
+}
+
+// continuePausedRequest 放行被 Fetch 暂停的请求；如果是待改写的首次导航，则替换方法、请求体与 Content-Type。
+func continuePausedRequest(ctx context.Context, e *fetch.EventRequestPaused) error {
+	cmd := fetch.ContinueRequest(e.RequestID)
+	if rw := takeNavRewrite(ctx, e); rw != nil {
+		headers := make([]*fetch.HeaderEntry, 0, len(e.Request.Headers)+1)
+		for k, v := range e.Request.Headers {
+			if strings.EqualFold(k, "Content-Type") {
+				continue
+			}
+			if s, ok := v.(string); ok {
+				headers = append(headers, &fetch.HeaderEntry{Name: k, Value: s})
+			}
+		}
+		headers = append(headers, &fetch.HeaderEntry{Name: "Content-Type", Value: rw.contentType})
+		cmd = cmd.WithMethod(rw.method).
+			WithPostData(base64.StdEncoding.EncodeToString([]byte(rw.body))).
+			WithHeaders(headers)
+	}
+	return cmd.Do(ctx)
+}
+
+// postNavigateAction 以 POST 执行 nav 中的首次导航：登记改写后通过 Fetch 拦截主框架的文档请求，
+// 替换方法与请求体（Page.navigate 本身只支持 GET）。已安装请求拦截（域名策略、file: 限制）或 block_ads 时复用其拦截，否则临时启用、导航后关闭。
+func postNavigateAction(req *ScreenshotRequest, nav chromedp.Action) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		tree, err := page.GetFrameTree().Do(ctx)
+		if err != nil {
+			return err
+		}
+		targetID := chromedp.FromContext(ctx).Target.TargetID
+		navRewrites.Store(targetID, &navRewrite{
+			frameID:     tree.Frame.ID,
+			method:      req.Method,
+			body:        req.Body,
+			contentType: req.ContentType,
+		})
+		defer navRewrites.Delete(targetID)
+
+		if !requestGuardEnabled() && !req.BlockAds {
+			lctx, stop := context.WithCancel(ctx)
+			defer stop()
+			chromedp.ListenTarget(lctx, func(ev interface{}) {
+				e, ok := ev.(*fetch.EventRequestPaused)
+				if !ok {
+					return
+				}
+				// 事件回调中不能同步执行 CDP 命令
+				go func() { _ = continuePausedRequest(ctx, e) }()
+			})
+			if err := fetch.Enable().WithPatterns([]*fetch.RequestPattern{
+				{URLPattern: "*", ResourceType: network.ResourceTypeDocument, RequestStage: fetch.RequestStageRequest},
+			}).Do(ctx); err != nil {
+				return err
+			}
+			defer func() { _ = fetch.Disable().Do(ctx) }()
+		}
+		return nav.Do(ctx)
+	})
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPreviewWidth    = 480
+	defaultPreviewCacheTTL = 10 * time.Minute
+	previewCacheMaxEntries = 512
+)
+
+// PreviewScreenshot 为链接预览中的缩略图。
+type PreviewScreenshot struct {
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Base64      string `json:"base64"`
+}
+
+// LinkPreview 为 /preview 的返回结果：一次渲染得到缩略图、页面元数据与 favicon。
+type LinkPreview struct {
+	URL         string            `json:"url"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	SiteName    string            `json:"site_name"`
+	Image       string            `json:"image"`
+	Canonical   string            `json:"canonical"`
+	OpenGraph   map[string]string `json:"open_graph"`
+	Twitter     map[string]string `json:"twitter"`
+	Favicon     *FaviconData      `json:"favicon"`
+	Screenshot  PreviewScreenshot `json:"screenshot"`
+	GeneratedAt string            `json:"generated_at"`
+}
+
+// envDuration 读取 Go duration 格式的环境变量（如 10m；0 合法），未配置或非法时返回默认值。
+func envDuration(key string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
+// getPreviewCacheTTL 读取 PREVIEW_CACHE_TTL（0 表示不缓存）。
+func getPreviewCacheTTL() time.Duration {
+	return envDuration("PREVIEW_CACHE_TTL", defaultPreviewCacheTTL)
+}
+
+type ttlCacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// ttlCache 进程内 TTL 缓存（条目数有上限），键为规范化后的请求参数。
+type ttlCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]ttlCacheEntry
+}
+
+func newTTLCache(maxEntries int) *ttlCache {
+	return &ttlCache{maxEntries: maxEntries, entries: map[string]ttlCacheEntry{}}
+}
+
+// get 返回缓存内容及剩余有效期。
+func (tc *ttlCache) get(key string) ([]byte, time.Duration, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	e, ok := tc.entries[key]
+	if !ok {
+		return nil, 0, false
+	}
+	remaining := time.Until(e.expires)
+	if remaining <= 0 {
+		delete(tc.entries, key)
+		return nil, 0, false
+	}
+	return e.body, remaining, true
+}
+
+func (tc *ttlCache) put(key string, body []byte, ttl time.Duration) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	now := time.Now()
+	if len(tc.entries) >= tc.maxEntries {
+		// 先清理过期项；仍然满时淘汰最早过期的一项
+		var oldestKey string
+		var oldest time.Time
+		for k, e := range tc.entries {
+			if now.After(e.expires) {
+				delete(tc.entries, k)
+				continue
+			}
+			if oldestKey == "" || e.expires.Before(oldest) {
+				oldestKey, oldest = k, e.expires
+			}
+		}
+		if len(tc.entries) >= tc.maxEntries {
+			delete(tc.entries, oldestKey)
+		}
+	}
+	tc.entries[key] = ttlCacheEntry{body: body, expires: now.Add(ttl)}
+}
+
+var previewCache = newTTLCache(previewCacheMaxEntries)
+
+// validatePreview 校验 /preview 专用参数并补默认值。
+func (r *ScreenshotRequest) validatePreview() error {
+	if r.PreviewWidth == 0 {
+		r.PreviewWidth = defaultPreviewWidth
+	}
+	if r.PreviewWidth < 64 || r.PreviewWidth > r.Width {
+		return fmt.Errorf("preview_width must be between 64 and width (%d)", r.Width)
+	}
+	if r.HTML != "" {
+		return errors.New("html is not supported by /preview")
+	}
+	return nil
+}
+
+func previewHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := parseRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.applyDefaults()
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := req.validatePreview(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setEffectiveRequestHeader(c, &req)
+
+		ttl := getPreviewCacheTTL()
+		keyBytes, _ := json.Marshal(req)
+		cacheKey := string(keyBytes)
+		if ttl > 0 {
+			if body, remaining, ok := previewCache.get(cacheKey); ok {
+				c.Header("X-Cache", "HIT")
+				c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(remaining.Seconds())))
+				c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+				return
+			}
+		}
+
+		viewportWidth, viewportHeight := req.viewportSize()
+
+		overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+
+		sess := openChromeSession(c, overallCtx, "previewHandler")
+		if sess == nil {
+			return
+		}
+		defer sess.cancel()
+
+		var meta PageMetadata
+		var img []byte
+		scale := float64(req.PreviewWidth) / float64(viewportWidth)
+		budget := newCaptureBudget(time.Duration(req.Timeout)*time.Second, req.BestEffort)
+		actions := navigationActions(&req, viewportWidth, viewportHeight, budget)
+		actions = append(actions,
+			chromedp.EvaluateAsDevTools(pageMetadataJS, &meta),
+			chromedp.ActionFunc(func(ctx context.Context) error {
+				// 按视口截图并在 Chrome 端缩放为缩略图
+				cap := page.CaptureScreenshot().WithFromSurface(true).WithFormat(captureFormat(req.Format)).
+					WithClip(&page.Viewport{X: 0, Y: 0, Width: float64(viewportWidth), Height: float64(viewportHeight), Scale: scale})
+				if req.Format == "jpeg" || req.Format == "webp" {
+					cap = cap.WithQuality(int64(req.Quality))
+				}
+				buf, err := cap.Do(ctx)
+				if err != nil {
+					return err
+				}
+				img = normalizeColorProfile(buf)
+				return nil
+			}),
+		)
+
+		if err := chromedp.Run(sess.ctx, actions...); err != nil {
+			respondRunError(c, err, sess.wsURL, "preview timeout", "failed to generate preview")
+			return
+		}
+		meta.normalize()
+
+		preview := LinkPreview{
+			URL:         meta.URL,
+			Title:       meta.OpenGraph["og:title"],
+			Description: meta.OpenGraph["og:description"],
+			SiteName:    meta.OpenGraph["og:site_name"],
+			Image:       meta.OpenGraph["og:image"],
+			Canonical:   meta.Canonical,
+			OpenGraph:   meta.OpenGraph,
+			Twitter:     meta.Twitter,
+			Screenshot: PreviewScreenshot{
+				ContentType: contentTypeForFormat(req.Format),
+				Width:       req.PreviewWidth,
+				Height:      int(float64(viewportHeight)*scale + 0.5),
+				Base64:      base64.StdEncoding.EncodeToString(img),
+			},
+			GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		}
+		if preview.Title == "" {
+			preview.Title = meta.Title
+		}
+		if preview.Description == "" {
+			preview.Description = meta.Description
+		}
+		if preview.Image == "" {
+			preview.Image = meta.Twitter["twitter:image"]
+		}
+		if data, _, err := bestFavicon(overallCtx, &req, meta.Favicons, 0); err == nil {
+			preview.Favicon = data
+		}
+
+		body, err := json.Marshal(preview)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode preview", "details": err.Error()})
+			return
+		}
+		// best-effort 下不完整的结果不缓存
+		skipped := budget.skippedStages()
+		if len(skipped) > 0 {
+			c.Header("X-Budget-Exceeded", strings.Join(skipped, ","))
+		} else if ttl > 0 {
+			previewCache.put(cacheKey, body, ttl)
+			c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(ttl.Seconds())))
+		}
+		c.Header("X-Cache", "MISS")
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
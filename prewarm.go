@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxPrewarmURLs         = 20
+	maxPrewarmKeepAliveSec = 300
+	maxWarmSessions        = 32
+)
+
+// PrewarmRequest 为 /prewarm 的请求体；其余导航参数（width/height/headers/user_agent/wait_for 等）与 POST /screenshot 相同。
+type PrewarmRequest struct {
+	URLs []string `json:"urls"`
+	// KeepAlive > 0 时保留预热所用的 tab（秒），返回 session_id 供随后的截图请求使用。
+	KeepAlive int `json:"keep_alive"`
+}
+
+// prewarmResult 单个 URL 的预热结果。
+type prewarmResult struct {
+	URL        string `json:"url"`
+	FinalURL   string `json:"final_url,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	Code       string `json:"code,omitempty"`
+	NetError   string `json:"net_error,omitempty"`
+}
+
+// warmSession 预热后保留的 tab；只能被一次截图请求取用，过期自动关闭。
+// tab 绑定在打开它的上游浏览器上，取用时不再经过负载均衡；该浏览器断开连接时会话标记为 lost 并立即释放。
+type warmSession struct {
+	sess    *chromeSession
+	close   func()
+	expires time.Time
+	timer   *time.Timer
+	lost    atomic.Bool
+}
+
+var errWarmSessionLost = errors.New("connection to browser holding a warm session was lost")
+
+var warmSessions = struct {
+	mu sync.Mutex
+	m  map[string]*warmSession
+}{m: map[string]*warmSession{}}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// putWarmSession 登记会话并在 keepAlive 后自动关闭；会话数已满时返回错误。
+func putWarmSession(ws *warmSession, keepAlive time.Duration) (string, error) {
+	warmSessions.mu.Lock()
+	defer warmSessions.mu.Unlock()
+	if len(warmSessions.m) >= maxWarmSessions {
+		return "", fmt.Errorf("too many warm sessions (max %d)", maxWarmSessions)
+	}
+	id := newSessionID()
+	// 断开后会先释放一次，过期或取用时会再次调用 close
+	ws.close = sync.OnceFunc(ws.close)
+	ws.expires = time.Now().Add(keepAlive)
+	ws.timer = time.AfterFunc(keepAlive, func() {
+		if takeWarmSession(id) != nil {
+			ws.close()
+		}
+	})
+	warmSessions.m[id] = ws
+	// 会话仍在登记中（未被取用或过期关闭）时 tab 的 context 结束，说明与上游浏览器的连接已断开：
+	// 释放资源并保留登记直到过期，使随后的请求得到 SESSION_LOST 而不是 SESSION_NOT_FOUND
+	context.AfterFunc(ws.sess.ctx, func() {
+		warmSessions.mu.Lock()
+		registered := warmSessions.m[id] == ws
+		warmSessions.mu.Unlock()
+		if registered && !ws.lost.Swap(true) {
+			ws.close()
+			upstreams.reportFailure(ws.sess.wsURL, errWarmSessionLost)
+			log.Printf("prewarm: session %s lost its browser connection", id)
+		}
+	})
+	return id, nil
+}
+
+// warmSessionsByHost 按上游 ws host 统计仍可用的会话数，用于 /health。
+func warmSessionsByHost() map[string]int {
+	warmSessions.mu.Lock()
+	defer warmSessions.mu.Unlock()
+	out := map[string]int{}
+	for _, ws := range warmSessions.m {
+		if u, err := url.Parse(ws.sess.wsURL); err == nil && !ws.lost.Load() {
+			out[u.Host]++
+		}
+	}
+	return out
+}
+
+// takeWarmSession 取出（并移除）会话；不存在或已过期时返回 nil。
+func takeWarmSession(id string) *warmSession {
+	warmSessions.mu.Lock()
+	defer warmSessions.mu.Unlock()
+	ws, ok := warmSessions.m[id]
+	if !ok {
+		return nil
+	}
+	delete(warmSessions.m, id)
+	ws.timer.Stop()
+	return ws
+}
+
+// openWarmSession 以 session_id 对应的已预热 tab 作为本次请求的会话（deadline 与 overallCtx 一致），用完即关闭。
+// 会话不存在时已写入 404；所在浏览器已断开或其上游处于退避期时关闭会话并写入 410（SESSION_LOST）。均返回 nil。
+func openWarmSession(c *gin.Context, overallCtx context.Context, id string) *chromeSession {
+	ws := takeWarmSession(id)
+	if ws == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found or expired", "code": "SESSION_NOT_FOUND"})
+		return nil
+	}
+	if ws.lost.Load() || ws.sess.ctx.Err() != nil || upstreams.isDown(ws.sess.wsURL) {
+		ws.close()
+		c.JSON(http.StatusGone, gin.H{"error": "session lost: the browser holding it is no longer available, prewarm again", "code": "SESSION_LOST"})
+		return nil
+	}
+	taskCtx, taskCancel := context.WithCancel(ws.sess.ctx)
+	if deadline, ok := overallCtx.Deadline(); ok {
+		taskCtx, taskCancel = context.WithDeadline(ws.sess.ctx, deadline)
+	}
+	stop := context.AfterFunc(overallCtx, taskCancel)
+	return &chromeSession{ctx: taskCtx, wsURL: ws.sess.wsURL, cancel: func() {
+		stop()
+		taskCancel()
+		ws.close()
+	}}
+}
+
+// prewarmNavigateAction 导航到 url 并记录耗时与最终地址；导航失败不中断后续 URL。
+func prewarmNavigateAction(req *ScreenshotRequest, viewportWidth, viewportHeight int64, budget *captureBudget, res *prewarmResult) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		start := time.Now()
+		err := chromedp.Tasks(navigationActions(req, viewportWidth, viewportHeight, budget)).Do(ctx)
+		res.DurationMs = time.Since(start).Milliseconds()
+		if err != nil {
+			// 整体超时后剩余 URL 依次记为超时，已预热的结果与会话仍然返回
+			if ctx.Err() != nil {
+				res.Error = "prewarm timeout"
+				return nil
+			}
+			res.Error = err.Error()
+			if ne := classifyNavigationError(err); ne != nil {
+				res.Code, res.NetError = ne.code, ne.netCode
+			}
+			return nil
+		}
+		var loc string
+		if err := chromedp.Location(&loc).Do(ctx); err == nil {
+			res.FinalURL = loc
+		}
+		return nil
+	})
+}
+
+// prewarmHandler 依次打开 urls（不截图），用于预热目标站点的 CDN / 应用缓存；
+// keep_alive > 0 时保留该 tab 并返回 session_id，随后 /screenshot 传入 session_id 即在已预热的 tab 中截图。
+func prewarmHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := c.GetRawData()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body"})
+			return
+		}
+		var in struct {
+			ScreenshotRequest
+			PrewarmRequest
+		}
+		provided, strict, err := decodeRequestBody(body, &in)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		pw, req := in.PrewarmRequest, in.ScreenshotRequest
+		req.provided, req.Strict = provided, strict
+		if len(pw.URLs) == 0 || len(pw.URLs) > maxPrewarmURLs {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("urls must contain 1-%d items", maxPrewarmURLs)})
+			return
+		}
+		if pw.KeepAlive < 0 || pw.KeepAlive > maxPrewarmKeepAliveSec {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("keep_alive must be between 0 and %d seconds", maxPrewarmKeepAliveSec)})
+			return
+		}
+		if req.HTML != "" || req.URL != "" || req.SessionID != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use urls for /prewarm; url, html and session_id are not supported"})
+			return
+		}
+
+		req.applyDefaults()
+		reqs := make([]ScreenshotRequest, len(pw.URLs))
+		for i, u := range pw.URLs {
+			reqs[i] = req
+			reqs[i].URL = u
+			if err := reqs[i].validate(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("urls[%d]: %s", i, err.Error())})
+				return
+			}
+		}
+		viewportWidth, viewportHeight := reqs[0].viewportSize()
+
+		// 保留会话时 tab 不设 deadline（由 keep_alive 计时器或随后的截图请求关闭），预热本身受 timeout 约束
+		timeout := time.Duration(reqs[0].Timeout) * time.Second
+		keepAlive := time.Duration(pw.KeepAlive) * time.Second
+		var overallCtx context.Context
+		var cancel context.CancelFunc
+		if keepAlive > 0 {
+			overallCtx, cancel = context.WithCancel(context.Background())
+		} else {
+			overallCtx, cancel = context.WithTimeout(context.Background(), timeout)
+		}
+		kept := false
+		defer func() {
+			if !kept {
+				cancel()
+			}
+		}()
+
+		sess := openChromeSession(c, overallCtx, "prewarmHandler")
+		if sess == nil {
+			return
+		}
+		defer func() {
+			if !kept {
+				sess.cancel()
+			}
+		}()
+
+		runCtx, runCancel := context.WithTimeout(sess.ctx, timeout)
+		defer runCancel()
+		// 不截图，无需为截图预留时间
+		budget := newCaptureBudget(0, false)
+		results := make([]prewarmResult, len(reqs))
+		actions := make([]chromedp.Action, 0, len(reqs))
+		for i := range reqs {
+			results[i].URL = reqs[i].URL
+			actions = append(actions, prewarmNavigateAction(&reqs[i], viewportWidth, viewportHeight, budget, &results[i]))
+		}
+		if err := chromedp.Run(runCtx, actions...); err != nil {
+			respondRunError(c, err, sess.wsURL, "prewarm timeout", "failed to prewarm")
+			return
+		}
+
+		resp := gin.H{"results": results}
+		if keepAlive > 0 {
+			ws := &warmSession{sess: sess, close: func() {
+				sess.cancel()
+				cancel()
+			}}
+			id, err := putWarmSession(ws, keepAlive)
+			if err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "failed to keep session", "details": err.Error()})
+				return
+			}
+			kept = true
+			resp["session_id"] = id
+			resp["expires_at"] = ws.expires.UTC().Format(time.RFC3339)
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// siteProfileForbiddenKeys 不允许出现在站点配置中的字段（决定请求目标或行为本身的参数）。
+var siteProfileForbiddenKeys = map[string]bool{"url": true, "html": true, "session_id": true, "strict": true}
+
+// siteProfile 为某个主机名的默认参数：字段与 ScreenshotRequest 的 JSON 字段相同，仅在请求未显式传入时生效；
+// headers 按键合并（请求中的同名 header 优先）。
+type siteProfile struct {
+	pattern string
+	fields  map[string]json.RawMessage
+}
+
+// siteProfiles 由 SITE_PROFILES_FILE 加载，按匹配优先级排序（精确主机名优先，其次后缀更长的通配）。
+var siteProfiles []siteProfile
+
+// loadSiteProfiles 读取 SITE_PROFILES_FILE（JSON 对象：主机名或 *.后缀 -> 参数对象）；未配置时不启用。
+func loadSiteProfiles() error {
+	path := strings.TrimSpace(os.Getenv("SITE_PROFILES_FILE"))
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var raw map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid SITE_PROFILES_FILE %q: %w", path, err)
+	}
+
+	profiles := make([]siteProfile, 0, len(raw))
+	for pattern, fields := range raw {
+		p := strings.ToLower(strings.TrimSpace(pattern))
+		for k, v := range fields {
+			if siteProfileForbiddenKeys[k] || (!queryParamNames[k] && k != "clip" && k != "pdf") {
+				return fmt.Errorf("site profile %q: unsupported field %q", pattern, k)
+			}
+			// 确认取值能被解析为对应字段
+			var probe ScreenshotRequest
+			if err := json.Unmarshal([]byte(fmt.Sprintf("{%q:%s}", k, v)), &probe); err != nil {
+				return fmt.Errorf("site profile %q: invalid value for %q: %w", pattern, k, err)
+			}
+		}
+		profiles = append(profiles, siteProfile{pattern: p, fields: fields})
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		wi, wj := strings.HasPrefix(profiles[i].pattern, "*."), strings.HasPrefix(profiles[j].pattern, "*.")
+		if wi != wj {
+			return !wi
+		}
+		return len(profiles[i].pattern) > len(profiles[j].pattern)
+	})
+	siteProfiles = profiles
+	return nil
+}
+
+// match 精确匹配主机名；"*.example.com" 匹配其所有子域名（不含 example.com 本身）。
+func (p siteProfile) match(host string) bool {
+	if suffix, ok := strings.CutPrefix(p.pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == p.pattern
+}
+
+// applySiteProfile 按目标主机名应用第一个匹配的站点配置，并记录配置名（X-Site-Profile）。
+func (r *ScreenshotRequest) applySiteProfile() error {
+	if len(siteProfiles) == 0 || r.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, p := range siteProfiles {
+		if !p.match(host) {
+			continue
+		}
+		fields := map[string]json.RawMessage{}
+		for k, v := range p.fields {
+			if k == "headers" || !r.provided[k] {
+				fields[k] = v
+			}
+		}
+		userHeaders := make(map[string]string, len(r.Headers))
+		for k, v := range r.Headers {
+			userHeaders[k] = v
+		}
+		body, _ := json.Marshal(fields)
+		if err := json.Unmarshal(body, r); err != nil {
+			return fmt.Errorf("site profile %q: %w", p.pattern, err)
+		}
+		// json.Unmarshal 会把配置中的 header 合并进已有 map；请求中的同名 header 优先
+		for k, v := range userHeaders {
+			r.Headers[k] = v
+		}
+		r.siteProfile = p.pattern
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import "github.com/chromedp/chromedp"
+
+// pseudoLocaleJS 把页面可见文本转换为伪本地化字符串，用于 i18n 布局检查：
+// - ASCII 字母替换为带重音的近形字符（验证字形/编码覆盖）
+// - 元音重复一次，文本整体扩展约 30%~40%（模拟德语等长文案）
+// - 用 ⟦ ⟧ 包裹（发现被截断/拼接的字符串）
+// 同时处理 placeholder/title/alt/aria-label 及按钮类 input 的 value。
+const pseudoLocaleJS = `(() => {
+	const map = {
+		A: 'Å', B: 'Ɓ', C: 'Ç', D: 'Đ', E: 'É', F: 'Ƒ', G: 'Ĝ', H: 'Ĥ', I: 'Î', J: 'Ĵ', K: 'Ķ', L: 'Ļ', M: 'Ṁ',
+		N: 'Ñ', O: 'Ö', P: 'Þ', Q: 'Ǫ', R: 'Ŕ', S: 'Š', T: 'Ţ', U: 'Û', V: 'Ṽ', W: 'Ŵ', X: 'Ẋ', Y: 'Ý', Z: 'Ž',
+		a: 'å', b: 'ƀ', c: 'ç', d: 'đ', e: 'é', f: 'ƒ', g: 'ĝ', h: 'ĥ', i: 'î', j: 'ĵ', k: 'ķ', l: 'ļ', m: 'ɱ',
+		n: 'ñ', o: 'ö', p: 'þ', q: 'ǫ', r: 'ŕ', s: 'š', t: 'ţ', u: 'û', v: 'ṽ', w: 'ŵ', x: 'ẋ', y: 'ý', z: 'ž',
+	};
+	const vowels = 'aeiouAEIOU';
+	const pseudo = (text) => {
+		if (!/[A-Za-z]/.test(text)) return text;
+		const lead = text.match(/^\s*/)[0];
+		const trail = text.match(/\s*$/)[0];
+		const core = text.slice(lead.length, text.length - trail.length);
+		let out = '';
+		for (const ch of core) {
+			const m = map[ch] || ch;
+			out += vowels.includes(ch) ? m + m : m;
+		}
+		return lead + '⟦' + out + '⟧' + trail;
+	};
+	const skip = new Set(['SCRIPT', 'STYLE', 'NOSCRIPT', 'TEXTAREA', 'CODE', 'PRE', 'svg']);
+	const walker = document.createTreeWalker(document.body || document.documentElement, NodeFilter.SHOW_TEXT, {
+		acceptNode: (n) => {
+			for (let p = n.parentElement; p; p = p.parentElement) {
+				if (skip.has(p.tagName)) return NodeFilter.FILTER_REJECT;
+			}
+			return n.nodeValue.trim() ? NodeFilter.FILTER_ACCEPT : NodeFilter.FILTER_REJECT;
+		},
+	});
+	const nodes = [];
+	while (walker.nextNode()) nodes.push(walker.currentNode);
+	for (const n of nodes) n.nodeValue = pseudo(n.nodeValue);
+
+	for (const attr of ['placeholder', 'title', 'alt', 'aria-label']) {
+		for (const el of document.querySelectorAll('[' + attr + ']')) {
+			el.setAttribute(attr, pseudo(el.getAttribute(attr)));
+		}
+	}
+	for (const el of document.querySelectorAll('input[type=button], input[type=submit], input[type=reset]')) {
+		el.value = pseudo(el.value);
+	}
+	return nodes.length;
+})()`
+
+// pseudoLocaleAction 在截图前对当前页面执行伪本地化转换。
+func pseudoLocaleAction() chromedp.Action {
+	return chromedp.EvaluateAsDevTools(pseudoLocaleJS, nil)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const rateLimitIdleTTL = 10 * time.Minute
+
+// rateLimiter 未配置 RATE_LIMIT_RPM 与 RATE_LIMIT_CONCURRENCY 时为 nil（不限流）。
+var rateLimiter = newRateLimiterFromEnv()
+
+// clientBucket 单个客户端的令牌桶与进行中请求数。
+type clientBucket struct {
+	tokens   float64
+	last     time.Time
+	inflight int
+}
+
+// clientRateLimiter 按客户端（API key，否则客户端 IP）限流：令牌桶限制每分钟请求数，另限制同时进行的渲染数，
+// 避免单个客户端占满上游 browserless 的容量。超限返回 429 + Retry-After。
+type clientRateLimiter struct {
+	perSecond   float64 // 0 表示不限制速率
+	burst       float64
+	concurrency int // 0 表示不限制并发
+
+	mu      sync.Mutex
+	buckets map[string]*clientBucket
+	swept   time.Time
+}
+
+// newRateLimiterFromEnv 读取 RATE_LIMIT_RPM / RATE_LIMIT_BURST（默认等于 RPM）/ RATE_LIMIT_CONCURRENCY。
+func newRateLimiterFromEnv() *clientRateLimiter {
+	envInt := func(key string) int {
+		n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+		if err != nil || n < 0 {
+			return 0
+		}
+		return n
+	}
+	rpm, burst, concurrency := envInt("RATE_LIMIT_RPM"), envInt("RATE_LIMIT_BURST"), envInt("RATE_LIMIT_CONCURRENCY")
+	if rpm == 0 && concurrency == 0 {
+		return nil
+	}
+	if burst == 0 {
+		burst = rpm
+	}
+	return &clientRateLimiter{
+		perSecond:   float64(rpm) / 60,
+		burst:       float64(burst),
+		concurrency: concurrency,
+		buckets:     map[string]*clientBucket{},
+	}
+}
+
+// rateLimitAPIKeys 为可作为独立限流维度的 API key（RATE_LIMIT_API_KEYS，逗号分隔）；
+// 不在其中的 key 不予采信，否则客户端每次换一个随机 key 即可绕过按 IP 的限流。
+var rateLimitAPIKeys = func() map[string]bool {
+	keys := map[string]bool{}
+	for _, k := range strings.Split(os.Getenv("RATE_LIMIT_API_KEYS"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys[k] = true
+		}
+	}
+	return keys
+}()
+
+// clientKeyContextKey 为进程内转发的请求（如批量任务的每一项）携带原请求的限流维度。
+type clientKeyContextKey struct{}
+
+// withClientKey 使 ctx 上的进程内请求与原请求计入同一客户端。
+func withClientKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, clientKeyContextKey{}, key)
+}
+
+// clientKey 优先使用 RATE_LIMIT_API_KEYS 中的 API key（X-API-Key 或 Authorization: Bearer），否则使用客户端 IP。
+// API key 仅作为限流维度，不用于鉴权。
+func clientKey(c *gin.Context) string {
+	if k, ok := c.Request.Context().Value(clientKeyContextKey{}).(string); ok {
+		return k
+	}
+	k := strings.TrimSpace(c.GetHeader("X-API-Key"))
+	if auth := c.GetHeader("Authorization"); k == "" && len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		k = strings.TrimSpace(auth[7:])
+	}
+	if k != "" && rateLimitAPIKeys[k] {
+		return "key:" + k
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// acquire 尝试占用一个令牌与一个并发名额；失败时返回建议的重试等待时间。
+func (l *clientRateLimiter) acquire(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &clientBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	if l.perSecond > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+		b.last = now
+		if b.tokens < 1 {
+			return time.Duration((1 - b.tokens) / l.perSecond * float64(time.Second)), false
+		}
+	}
+	if l.concurrency > 0 && b.inflight >= l.concurrency {
+		return time.Second, false
+	}
+	if l.perSecond > 0 {
+		b.tokens--
+	}
+	b.inflight++
+	return 0, true
+}
+
+func (l *clientRateLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[key]; ok {
+		b.inflight--
+	}
+}
+
+// sweep 每分钟清理一次长时间空闲（令牌已回满且无进行中请求）的客户端（调用方需持有锁）。
+func (l *clientRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	for k, b := range l.buckets {
+		if b.inflight == 0 && now.Sub(b.last) > rateLimitIdleTTL {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+func (l *clientRateLimiter) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := clientKey(c)
+		retryAfter, ok := l.acquire(key)
+		if !ok {
+			secs := int(math.Ceil(retryAfter.Seconds()))
+			if secs < 1 {
+				secs = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(secs))
+			c.Header("X-Estimated-Wait", strconv.Itoa(secs))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "rate limit exceeded",
+				"code":  "RATE_LIMITED",
+			})
+			return
+		}
+		defer l.release(key)
+		c.Next()
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPM", "60")
+	t.Setenv("RATE_LIMIT_BURST", "2")
+	t.Setenv("RATE_LIMIT_CONCURRENCY", "")
+	l := newRateLimiterFromEnv()
+
+	for i := 0; i < 2; i++ {
+		if _, ok := l.acquire("a"); !ok {
+			t.Fatalf("request %d within burst was limited", i)
+		}
+		l.release("a")
+	}
+	wait, ok := l.acquire("a")
+	if ok {
+		t.Fatal("request beyond burst was allowed")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("retry after %v, want (0, 1s]", wait)
+	}
+	// 其他客户端不受影响
+	if _, ok := l.acquire("b"); !ok {
+		t.Error("another client was limited")
+	}
+
+	// 1 个令牌/秒：把上次补充时间往前拨 1.5s 后可再请求一次，之后再次耗尽
+	l.mu.Lock()
+	l.buckets["a"].last = l.buckets["a"].last.Add(-1500 * time.Millisecond)
+	l.mu.Unlock()
+	if _, ok := l.acquire("a"); !ok {
+		t.Fatal("request after refill was limited")
+	}
+	if _, ok := l.acquire("a"); ok {
+		t.Error("bucket refilled more than elapsed time allows")
+	}
+
+	// 令牌不超过 burst
+	l.mu.Lock()
+	l.buckets["a"].last = l.buckets["a"].last.Add(-time.Hour)
+	l.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		if _, ok := l.acquire("a"); !ok {
+			t.Fatalf("request %d after long idle was limited", i)
+		}
+	}
+	if _, ok := l.acquire("a"); ok {
+		t.Error("bucket exceeded burst after long idle")
+	}
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPM", "")
+	t.Setenv("RATE_LIMIT_BURST", "")
+	t.Setenv("RATE_LIMIT_CONCURRENCY", "1")
+	l := newRateLimiterFromEnv()
+
+	if _, ok := l.acquire("a"); !ok {
+		t.Fatal("first request was limited")
+	}
+	if _, ok := l.acquire("a"); ok {
+		t.Fatal("second concurrent request was allowed")
+	}
+	l.release("a")
+	if _, ok := l.acquire("a"); !ok {
+		t.Error("request after release was limited")
+	}
+}
+
+func TestNewRateLimiterDisabled(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPM", "")
+	t.Setenv("RATE_LIMIT_CONCURRENCY", "0")
+	if newRateLimiterFromEnv() != nil {
+		t.Error("rate limiter enabled without RATE_LIMIT_RPM or RATE_LIMIT_CONCURRENCY")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redisIOTimeout = time.Second
+
+// redisClient 为响应缓存使用的最小 Redis 客户端（RESP2，仅 GET / SET PX），单连接串行访问，出错后下次请求重连。
+type redisClient struct {
+	addr     string
+	useTLS   bool
+	username string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// newRedisClient 解析 redis://[user:password@]host:port/db（rediss:// 使用 TLS）。
+func newRedisClient(raw string) (*redisClient, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	rc := &redisClient{addr: u.Host, useTLS: u.Scheme == "rediss"}
+	if u.Port() == "" {
+		rc.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		rc.username = u.User.Username()
+		rc.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if rc.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid db %q", db)
+		}
+	}
+	return rc, nil
+}
+
+func (rc *redisClient) get(key string) ([]byte, error) {
+	return rc.do("GET", key)
+}
+
+func (rc *redisClient) set(key string, value []byte, ttl time.Duration) error {
+	_, err := rc.do("SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// do 执行一条命令；nil 回复返回 (nil, nil)。
+func (rc *redisClient) do(args ...string) ([]byte, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.conn == nil {
+		if err := rc.connect(); err != nil {
+			return nil, err
+		}
+	}
+	v, err := rc.roundTrip(args)
+	if err != nil {
+		var re redisError
+		if !errors.As(err, &re) {
+			// 连接层错误：丢弃连接，下次重连
+			rc.conn.Close()
+			rc.conn = nil
+		}
+		return nil, err
+	}
+	return v, nil
+}
+
+func (rc *redisClient) connect() error {
+	d := &net.Dialer{Timeout: redisIOTimeout}
+	var conn net.Conn
+	var err error
+	if rc.useTLS {
+		host, _, _ := net.SplitHostPort(rc.addr)
+		conn, err = tls.DialWithDialer(d, "tcp", rc.addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = d.Dial("tcp", rc.addr)
+	}
+	if err != nil {
+		return err
+	}
+	rc.conn, rc.rd = conn, bufio.NewReader(conn)
+
+	var setup [][]string
+	if rc.password != "" {
+		if rc.username != "" {
+			setup = append(setup, []string{"AUTH", rc.username, rc.password})
+		} else {
+			setup = append(setup, []string{"AUTH", rc.password})
+		}
+	}
+	if rc.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(rc.db)})
+	}
+	for _, cmd := range setup {
+		if _, err := rc.roundTrip(cmd); err != nil {
+			conn.Close()
+			rc.conn = nil
+			return fmt.Errorf("redis %s: %w", cmd[0], err)
+		}
+	}
+	return nil
+}
+
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func (rc *redisClient) roundTrip(args []string) ([]byte, error) {
+	if err := rc.conn.SetDeadline(time.Now().Add(redisIOTimeout)); err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(rc.conn, sb.String()); err != nil {
+		return nil, err
+	}
+
+	line, err := rc.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, redisError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rc.rd, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
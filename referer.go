@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// validateReferer 校验 referer（http/https 绝对地址）；不能与 headers 中的 Referer 同时使用。
+func (r *ScreenshotRequest) validateReferer() error {
+	r.Referer = strings.TrimSpace(r.Referer)
+	if r.Referer == "" {
+		if r.SpoofReferrer {
+			return errors.New("spoof_referrer requires referer")
+		}
+		return nil
+	}
+	u, err := url.Parse(r.Referer)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("referer must be an absolute http(s) URL")
+	}
+	for k := range r.Headers {
+		if strings.EqualFold(k, "Referer") {
+			return errors.New("referer cannot be combined with a Referer entry in headers")
+		}
+	}
+	return nil
+}
+
+// navigateAction 导航到 url 并等待 load（与 chromedp.Navigate 相同）。设置了 referer 时通过 Page.navigate 的
+// referrer 参数传入（仅作用于顶层导航，策略为 unsafe-url，即原样发送），而不是 extra headers（会发给所有子资源）。
+func navigateAction(url, referer string) chromedp.Action {
+	if referer == "" {
+		return chromedp.Navigate(url)
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		_, err := chromedp.RunResponse(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			_, _, errorText, _, err := page.Navigate(url).
+				WithReferrer(referer).
+				WithReferrerPolicy(page.ReferrerPolicyUnsafeURL).
+				Do(ctx)
+			if err != nil {
+				return err
+			}
+			if errorText != "" {
+				return fmt.Errorf("page load error %s", errorText)
+			}
+			return nil
+		}))
+		return err
+	})
+}
+
+// spoofReferrerAction 在页面脚本执行前把顶层文档的 document.referrer 固定为 referer
+// （用于 html 渲染或目标站点的 Referrer-Policy 会截断 referrer 的情况）。
+func spoofReferrerAction(referer string) chromedp.Action {
+	quoted, _ := json.Marshal(referer)
+	script := `(() => {
+  if (window !== window.top) return;
+  const referrer = ` + string(quoted) + `;
+  Object.defineProperty(Document.prototype, 'referrer', { get: () => referrer, configurable: true });
+})();`
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		_, err := page.AddScriptToEvaluateOnNewDocument(script).Do(ctx)
+		return err
+	})
+}
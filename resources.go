@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/page"
+)
+
+const (
+	// captureMemoryEstimate 为每个进行中的截图预留的内存（图片在 CDP 中以 base64 传输，解码、后处理与响应各有副本）。
+	captureMemoryEstimate = 128 << 20
+	// captureBytesPerPixel 为整页截图每像素预留的字节数，用于推导 MAX_CAPTURE_PIXELS。
+	captureBytesPerPixel = 8
+	// responseCacheEntryEstimate 为响应缓存单个条目的平均大小估计。
+	responseCacheEntryEstimate = 512 << 10
+	// chromeMaxPixels 为 Chrome 单张截图的面积上限（约 2.68 亿像素）。
+	chromeMaxPixels = 1 << 28
+)
+
+// containerResources 为启动时从 cgroup 读取的内存与 CPU 限制；0 表示未限制或无法读取。
+type containerResources struct {
+	memoryBytes int64
+	cpus        float64
+	source      string // cgroup2 / cgroup1，未探测到限制时为空
+}
+
+// resourceLimits 在包初始化时探测，供并发、缓存与像素预算的默认值使用。
+var resourceLimits = detectContainerResources("/sys/fs/cgroup")
+
+// detectContainerResources 依次尝试 cgroup v2（memory.max / cpu.max）与 v1（memory.limit_in_bytes / cpu.cfs_quota_us）。
+func detectContainerResources(root string) containerResources {
+	var r containerResources
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		r.memoryBytes = readCgroupInt(filepath.Join(root, "memory.max"))
+		if fields := strings.Fields(readCgroupFile(filepath.Join(root, "cpu.max"))); len(fields) == 2 {
+			r.cpus = cpuQuota(fields[0], fields[1])
+		}
+		if r.memoryBytes > 0 || r.cpus > 0 {
+			r.source = "cgroup2"
+		}
+		return r
+	}
+	r.memoryBytes = readCgroupInt(filepath.Join(root, "memory", "memory.limit_in_bytes"))
+	// v1 未限制时为接近 int64 上限的页对齐值
+	if r.memoryBytes >= 1<<60 {
+		r.memoryBytes = 0
+	}
+	r.cpus = cpuQuota(readCgroupFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us")), readCgroupFile(filepath.Join(root, "cpu", "cpu.cfs_period_us")))
+	if r.memoryBytes > 0 || r.cpus > 0 {
+		r.source = "cgroup1"
+	}
+	return r
+}
+
+func readCgroupFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readCgroupInt 读取整数限制；"max"、-1 或无法解析时返回 0。
+func readCgroupInt(path string) int64 {
+	n, err := strconv.ParseInt(readCgroupFile(path), 10, 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return n
+}
+
+func cpuQuota(quota, period string) float64 {
+	q, err1 := strconv.ParseFloat(quota, 64)
+	p, err2 := strconv.ParseFloat(period, 64)
+	if err1 != nil || err2 != nil || q <= 0 || p <= 0 {
+		return 0
+	}
+	return q / p
+}
+
+// cpuCount 为可用 CPU 数：有配额时取配额（向上取整），否则为 runtime.NumCPU()。
+func (r containerResources) cpuCount() int {
+	if r.cpus > 0 {
+		return max(1, int(math.Ceil(r.cpus)))
+	}
+	return runtime.NumCPU()
+}
+
+// defaultMaxConcurrentCaptures 为 MAX_CONCURRENT_CAPTURES 的默认值：按内存（每个截图 128MiB）与 CPU（每核 4 个）推导，
+// 未探测到限制时为 0（不限制）。
+func (r containerResources) defaultMaxConcurrentCaptures() int {
+	if r.source == "" {
+		return 0
+	}
+	n := r.cpuCount() * 4
+	if r.memoryBytes > 0 {
+		n = min(n, int(r.memoryBytes/captureMemoryEstimate))
+	}
+	return max(1, n)
+}
+
+// defaultBatchParallelism 为 BATCH_MAX_PARALLELISM 的默认值：不超过内置默认与可用 CPU 数的 2 倍。
+func (r containerResources) defaultBatchParallelism() int {
+	if r.source == "" {
+		return defaultBatchMaxParallelism
+	}
+	return max(1, min(defaultBatchMaxParallelism, r.cpuCount()*2))
+}
+
+// defaultResponseCacheEntries 为进程内响应缓存条目数的默认值：最多占用内存限制的 1/8。
+func (r containerResources) defaultResponseCacheEntries() int {
+	if r.memoryBytes <= 0 {
+		return defaultResponseCacheMaxEntries
+	}
+	return max(16, min(defaultResponseCacheMaxEntries, int(r.memoryBytes/8/responseCacheEntryEstimate)))
+}
+
+// defaultMaxCapturePixels 为 MAX_CAPTURE_PIXELS 的默认值：内存限制按并发数均分后折算为像素，未限制内存时为 0（不限制）。
+func (r containerResources) defaultMaxCapturePixels() int64 {
+	if r.memoryBytes <= 0 {
+		return 0
+	}
+	return min(chromeMaxPixels, r.memoryBytes/int64(max(1, r.defaultMaxConcurrentCaptures()))/captureBytesPerPixel)
+}
+
+// maxCapturePixels 为整页截图的像素预算（宽 × 高 × device_scale²），超出时截断高度并返回 X-Capture-Truncated；0 不限制。
+var maxCapturePixels = func() int64 {
+	if v := strings.TrimSpace(os.Getenv("MAX_CAPTURE_PIXELS")); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return resourceLimits.defaultMaxCapturePixels()
+}()
+
+// limitCapturePixels 按 maxCapturePixels 截断整页截图区域的高度，返回是否被截断。
+func limitCapturePixels(clip *page.Viewport, deviceScale float64) bool {
+	if maxCapturePixels <= 0 || clip == nil || clip.Width <= 0 {
+		return false
+	}
+	maxHeight := math.Floor(float64(maxCapturePixels) / (clip.Width * deviceScale * deviceScale))
+	if clip.Height <= maxHeight {
+		return false
+	}
+	clip.Height = max(1, maxHeight)
+	return true
+}
+
+// applyResourceLimits 在启动时设置 Go 运行时内存上限（未配置 GOMEMLIMIT 时为 cgroup 内存的 90%），
+// 并记录探测到的限制与据此得出的各项默认值。
+func applyResourceLimits() {
+	r := resourceLimits
+	if r.source == "" {
+		log.Printf("resources: no cgroup limits detected (cpus=%d)", runtime.NumCPU())
+		return
+	}
+	memLimit := "unchanged"
+	if r.memoryBytes > 0 && os.Getenv("GOMEMLIMIT") == "" {
+		debug.SetMemoryLimit(r.memoryBytes / 10 * 9)
+		memLimit = formatBytes(r.memoryBytes / 10 * 9)
+	}
+	mem := "unlimited"
+	if r.memoryBytes > 0 {
+		mem = formatBytes(r.memoryBytes)
+	}
+	_, batchParallelism := batchLimits()
+	concurrency := 0
+	if captureSlots != nil {
+		concurrency = cap(captureSlots.slots)
+	}
+	log.Printf("resources: %s memory=%s cpus=%.2f -> GOMEMLIMIT=%s max_concurrent_captures=%d batch_parallelism=%d default_response_cache_entries=%d max_capture_pixels=%d",
+		r.source, mem, r.cpus, memLimit, concurrency, batchParallelism, r.defaultResponseCacheEntries(), maxCapturePixels)
+}
+
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.0fMiB", float64(n)/(1<<20))
+}
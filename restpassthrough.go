@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// restPassthroughEnabled 为 BROWSERLESS_REST_PASSTHROUGH=true 时，简单截图请求改用 browserless 的 REST 接口
+// （POST /screenshot）完成，而不是建立 CDP 连接逐步驱动页面；其余请求仍走 CDP。
+var restPassthroughEnabled = func() bool {
+	v, _ := strconv.ParseBool(strings.TrimSpace(os.Getenv("BROWSERLESS_REST_PASSTHROUGH")))
+	return v
+}()
+
+// restUnsupported 记录 REST 接口返回 404/405 的 browserless 地址（旧版本或未开启该接口），此后不再尝试。
+var restUnsupported sync.Map
+
+var errRESTUnsupported = errors.New("browserless REST /screenshot is not available")
+
+// browserlessScreenshotBody 为 browserless POST /screenshot 的请求体（字段与 Puppeteer 的参数一致）。
+type browserlessScreenshotBody struct {
+	URL     string `json:"url,omitempty"`
+	HTML    string `json:"html,omitempty"`
+	Options struct {
+		Type     string `json:"type"`
+		Quality  int    `json:"quality,omitempty"`
+		FullPage bool   `json:"fullPage,omitempty"`
+		Clip     *Clip  `json:"clip,omitempty"`
+	} `json:"options"`
+	Viewport struct {
+		Width             int64   `json:"width"`
+		Height            int64   `json:"height"`
+		DeviceScaleFactor float64 `json:"deviceScaleFactor"`
+		IsMobile          bool    `json:"isMobile,omitempty"`
+	} `json:"viewport"`
+	GotoOptions struct {
+		WaitUntil string `json:"waitUntil"`
+		Timeout   int    `json:"timeout"`
+	} `json:"gotoOptions"`
+	WaitForSelector *struct {
+		Selector string `json:"selector"`
+		Visible  bool   `json:"visible"`
+	} `json:"waitForSelector,omitempty"`
+	WaitForTimeout      int               `json:"waitForTimeout,omitempty"`
+	SetExtraHTTPHeaders map[string]string `json:"setExtraHTTPHeaders,omitempty"`
+	UserAgent           string            `json:"userAgent,omitempty"`
+}
+
+// restPassthroughEligible 报告请求是否只用到了 REST 接口能等价实现的参数。
+func (r *ScreenshotRequest) restPassthroughEligible() bool {
+	if r.Height == heightAuto {
+		return false
+	}
+	return r.usesOnly(func(dst, src *ScreenshotRequest) {
+		dst.Engine, dst.URL, dst.HTML = src.Engine, src.URL, src.HTML
+		dst.Width, dst.Height, dst.DeviceScale, dst.Mobile = src.Width, src.Height, src.DeviceScale, src.Mobile
+		dst.Format, dst.Quality, dst.FullPage, dst.Clip = src.Format, src.Quality, src.FullPage, src.Clip
+		dst.WaitTime, dst.WaitFor, dst.WaitUntil, dst.Timeout = src.WaitTime, src.WaitFor, src.WaitUntil, src.Timeout
+		dst.Headers, dst.UserAgent = src.Headers, src.UserAgent
+		dst.Store, dst.Storage, dst.Tags, dst.ResponseType, dst.Strict = src.Store, src.Storage, src.Tags, src.ResponseType, src.Strict
+	})
+}
+
+// useRESTPassthrough 判断本次截图是否走 REST 直通：需开启 BROWSERLESS_REST_PASSTHROUGH、使用 BROWSERLESS_HTTP_URL
+// （CHROME_WS_ENDPOINT 没有 REST 接口），且不依赖只能通过 CDP 实现的功能。
+func useRESTPassthrough(c *gin.Context, req *ScreenshotRequest) bool {
+	if !restPassthroughEnabled || getChromeWSEndpoint() != "" || len(upstreams.endpoints) == 0 {
+		return false
+	}
+	// 域名策略需拦截重定向，file: 页面需拦截其引用的其他本地文件，像素预算需截断整页高度，故障注入与 CDP 回放作用于 CDP 连接
+	if hostPolicy != nil || urlScheme(req.URL) == "file" || faultInjectionEnabled || cdpReplayFrom(c) != nil || (req.FullPage && maxCapturePixels > 0) {
+		return false
+	}
+	return req.restPassthroughEligible()
+}
+
+func (r *ScreenshotRequest) browserlessScreenshotBody() browserlessScreenshotBody {
+	var b browserlessScreenshotBody
+	b.URL, b.HTML = r.URL, r.HTML
+	b.Options.Type = r.Format
+	if r.Format == "jpeg" || r.Format == "webp" {
+		b.Options.Quality = r.Quality
+	}
+	b.Options.FullPage = r.FullPage && r.Clip == nil
+	b.Options.Clip = r.Clip
+	b.Viewport.Width, b.Viewport.Height = r.viewportSize()
+	b.Viewport.DeviceScaleFactor = r.DeviceScale
+	b.Viewport.IsMobile = r.Mobile
+	b.GotoOptions.WaitUntil = r.WaitUntil
+	if b.GotoOptions.WaitUntil == "" {
+		b.GotoOptions.WaitUntil = waitUntilLoad
+	}
+	b.GotoOptions.Timeout = r.Timeout * 1000
+	if r.WaitFor != "" {
+		b.WaitForSelector = &struct {
+			Selector string `json:"selector"`
+			Visible  bool   `json:"visible"`
+		}{Selector: r.WaitFor, Visible: true}
+	}
+	b.WaitForTimeout = r.WaitTime
+	b.SetExtraHTTPHeaders = r.Headers
+	b.UserAgent = r.UserAgent
+	return b
+}
+
+// restStatusError 为 browserless REST 接口返回的非 2xx 响应。
+type restStatusError struct {
+	status int
+	body   string
+}
+
+func (e *restStatusError) Error() string {
+	return fmt.Sprintf("browserless REST /screenshot returned %d: %s", e.status, e.body)
+}
+
+// Unwrap 使 408 与页面等待超时（Puppeteer TimeoutError）按超时处理。
+func (e *restStatusError) Unwrap() error {
+	if e.status == http.StatusRequestTimeout || strings.Contains(e.body, "TimeoutError") {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// upstreamFailure 报告该响应是否说明 browserless 本身不可用（排队已满、网关错误），而不是目标页面的问题。
+func (e *restStatusError) upstreamFailure() bool {
+	switch e.status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return classifyNavigationError(e) == nil
+	}
+	return false
+}
+
+// postBrowserlessScreenshot 调用 base 的 POST /screenshot（保留 base 的路径前缀与 token 等 query），返回图片与响应头。
+func postBrowserlessScreenshot(ctx context.Context, base string, body []byte, timeoutMS int) ([]byte, http.Header, error) {
+	u, err := parseBrowserlessHTTPBase(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/screenshot"
+	u.Fragment = ""
+	q := u.Query()
+	q.Set("timeout", strconv.Itoa(timeoutMS))
+	u.RawQuery = q.Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return nil, nil, errRESTUnsupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, nil, &restStatusError{status: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+	img, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, resp.Header, nil
+}
+
+// captureViaBrowserlessREST 通过 browserless REST 接口截图：按负载均衡顺序依次尝试各地址，browserless 不可用时
+// 计入该地址的失败并换下一个。所有地址都不支持 REST 接口时 handled 为 false，由调用方改用 CDP；
+// handled 为 true 且 img 为 nil 时已写入错误响应。
+func captureViaBrowserlessREST(c *gin.Context, ctx context.Context, req *ScreenshotRequest) (img []byte, handled bool) {
+	if upstreamBreaker != nil {
+		if retryAfter, ok := upstreamBreaker.allow(); !ok {
+			respondCircuitOpen(c, retryAfter)
+			return nil, true
+		}
+	}
+	body, err := json.Marshal(req.browserlessScreenshotBody())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode browserless request", "details": err.Error()})
+		return nil, true
+	}
+
+	var lastErr error
+	var lastBase string
+	for _, e := range upstreams.order() {
+		if _, ok := restUnsupported.Load(e.base); ok {
+			continue
+		}
+		done := upstreams.track(e)
+		img, header, err := postBrowserlessScreenshot(ctx, e.base, body, req.Timeout*1000)
+		done()
+		if errors.Is(err, errRESTUnsupported) {
+			restUnsupported.Store(e.base, true)
+			log.Printf("restPassthrough: %s has no REST /screenshot, using CDP for it", redactSensitiveURL(e.base))
+			continue
+		}
+		var statusErr *restStatusError
+		if err != nil && ctx.Err() == nil && (!errors.As(err, &statusErr) || statusErr.upstreamFailure()) {
+			// browserless 不可达或过载：计入该地址与熔断器的失败，换下一个地址
+			upstreams.markFailure(e, err)
+			recordUpstreamResult(ctx, err)
+			lastErr, lastBase = err, e.base
+			continue
+		}
+		if err != nil {
+			respondRunError(c, err, e.base, "screenshot timeout", "failed to screenshot")
+			return nil, true
+		}
+
+		upstreams.markHealthy(e)
+		recordUpstreamResult(ctx, nil)
+		if v, err := strconv.ParseInt(header.Get("X-Response-Code"), 10, 64); err == nil {
+			req.output.status.Store(v)
+		}
+		req.output.page.URL = header.Get("X-Response-URL")
+		c.Header("X-Capture-Backend", "browserless-rest")
+		return normalizeColorProfile(img), true
+	}
+	if lastErr == nil {
+		return nil, false
+	}
+	respondRunError(c, lastErr, lastBase, "screenshot timeout", "failed to screenshot")
+	return nil, true
+}
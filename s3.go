@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// s3Store 使用 S3 PutObject（SigV4 签名）上传截图，兼容 MinIO / R2 等 S3 兼容存储。
+type s3Store struct {
+	bucket        string
+	region        string
+	endpoint      *url.URL // 未配置 S3_ENDPOINT 时为 https://s3.<region>.amazonaws.com
+	pathStyle     bool
+	publicBaseURL string
+
+	accessKey    *secret
+	secretKey    *secret
+	sessionToken *secret
+
+	client *http.Client
+}
+
+// newS3StoreFromEnv 读取 S3_BUCKET / S3_REGION / S3_ENDPOINT / S3_FORCE_PATH_STYLE / S3_PUBLIC_BASE_URL
+// 与 AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY / AWS_SESSION_TOKEN（均可改用 *_FILE 从文件读取并自动重新加载）；
+// 未配置 S3_BUCKET 时返回 nil。
+func newS3StoreFromEnv() (*s3Store, error) {
+	bucket := strings.TrimSpace(os.Getenv("S3_BUCKET"))
+	if bucket == "" {
+		return nil, nil
+	}
+	s := &s3Store{
+		bucket:        bucket,
+		region:        strings.TrimSpace(os.Getenv("S3_REGION")),
+		publicBaseURL: strings.TrimRight(strings.TrimSpace(os.Getenv("S3_PUBLIC_BASE_URL")), "/"),
+		client:        &http.Client{Timeout: storageUploadTimeout},
+	}
+	var err error
+	for _, c := range []struct {
+		dst  **secret
+		name string
+	}{{&s.accessKey, "AWS_ACCESS_KEY_ID"}, {&s.secretKey, "AWS_SECRET_ACCESS_KEY"}, {&s.sessionToken, "AWS_SESSION_TOKEN"}} {
+		if *c.dst, err = loadSecret(c.name); err != nil {
+			return nil, err
+		}
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	if s.accessKey.get() == "" || s.secretKey.get() == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required when S3_BUCKET is set")
+	}
+	raw := strings.TrimSpace(os.Getenv("S3_ENDPOINT"))
+	if raw == "" {
+		raw = "https://s3." + s.region + ".amazonaws.com"
+	} else {
+		// 自定义 endpoint（MinIO 等）默认使用 path-style
+		s.pathStyle = true
+	}
+	ep, err := url.Parse(raw)
+	if err != nil || (ep.Scheme != "http" && ep.Scheme != "https") || ep.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", raw)
+	}
+	s.endpoint = ep
+	if v := strings.TrimSpace(os.Getenv("S3_FORCE_PATH_STYLE")); v != "" {
+		if s.pathStyle, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid S3_FORCE_PATH_STYLE %q", v)
+		}
+	}
+	return s, nil
+}
+
+// objectURL 返回对象的请求地址：virtual-hosted（bucket.host/key）或 path-style（host/bucket/key）。
+func (s *s3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	base := strings.TrimRight(u.Path, "/")
+	if s.pathStyle {
+		u.Path = base + "/" + s.bucket + "/" + key
+		u.RawPath = base + "/" + s3EscapePath(s.bucket) + "/" + s3EscapePath(key)
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = base + "/" + key
+		u.RawPath = base + "/" + s3EscapePath(key)
+	}
+	return &u
+}
+
+func (s *s3Store) put(ctx context.Context, key, contentType string, body []byte, meta map[string]string) (string, error) {
+	u := s.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range meta {
+		req.Header.Set("X-Amz-Meta-"+k, s3MetaValue(v))
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return "", fmt.Errorf("s3 put %s: status %d: %s", key, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if s.publicBaseURL != "" {
+		return s.publicBaseURL + "/" + s3EscapePath(key), nil
+	}
+	return u.String(), nil
+}
+
+func (s *s3Store) describe() gin.H {
+	return gin.H{"type": "s3", "bucket": s.bucket, "region": s.region}
+}
+
+// sign 按 AWS Signature Version 4 为请求添加 Authorization 等头（payload 使用实际 SHA-256）。
+func (s *s3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if token := s.sessionToken.get(); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		names = append(names, strings.ToLower(k))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		v := req.Header.Get(k)
+		if k == "host" {
+			v = req.URL.Host
+		}
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := s.scope(date)
+	signature := s.signature(date, amzDate, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey.get(), scope, signedHeaders, signature))
+}
+
+// presign 生成 GET 对象的 SigV4 查询串签名地址（UNSIGNED-PAYLOAD，有效期 ttl）。
+// 地址使用 S3 endpoint 而不是 S3_PUBLIC_BASE_URL（CDN 无法校验签名）。
+func (s *s3Store) presign(key string, ttl time.Duration, now time.Time) (string, error) {
+	u := s.objectURL(key)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := now.UTC().Format("20060102")
+	q := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.accessKey.get() + "/" + s.scope(date)},
+		"X-Amz-Date":          {amzDate},
+		"X-Amz-Expires":       {strconv.Itoa(int(ttl.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	if token := s.sessionToken.get(); token != "" {
+		q.Set("X-Amz-Security-Token", token)
+	}
+	canonicalQuery := strings.ReplaceAll(q.Encode(), "+", "%20")
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		canonicalQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	u.RawQuery = canonicalQuery + "&X-Amz-Signature=" + s.signature(date, amzDate, canonicalRequest)
+	return u.String(), nil
+}
+
+func (s *s3Store) canPresign() bool { return true }
+
+func (s *s3Store) scope(date string) string {
+	return date + "/" + s.region + "/s3/aws4_request"
+}
+
+// signature 计算 canonicalRequest 的 SigV4 签名（hex）。
+func (s *s3Store) signature(date, amzDate, canonicalRequest string) string {
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + s.scope(date) + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+s.secretKey.get()), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3MetaValue 编码 x-amz-meta-* 的取值：S3 元数据只能是 US-ASCII，含非 ASCII 或控制字符时按 RFC 2047
+// 编码（S3 存储前会解码），其余原样返回。
+func s3MetaValue(v string) string {
+	return mime.BEncoding.Encode("UTF-8", v)
+}
+
+// s3EscapePath 按 SigV4 规则编码对象键：除 A-Z a-z 0-9 - _ . ~ 与 "/" 外全部百分号编码。
+func s3EscapePath(key string) string {
+	var sb strings.Builder
+	for i := 0; i < len(key); i++ {
+		b := key[i]
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' || b == '/' {
+			sb.WriteByte(b)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", b)
+	}
+	return sb.String()
+}
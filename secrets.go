@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultSecretsReloadInterval = time.Minute
+
+// secret 为凭证类配置：优先读取 <NAME>_FILE 指向的文件（Kubernetes / Docker 挂载的 secret），
+// 否则读取环境变量 <NAME>。来自文件的值由 watchSecrets 定期重新读取，轮换后无需重启。
+type secret struct {
+	name  string
+	path  string
+	value atomic.Pointer[string]
+}
+
+var (
+	fileSecretsMu sync.Mutex
+	fileSecrets   []*secret
+)
+
+// loadSecret 读取名为 name 的凭证；同时设置 <NAME> 与 <NAME>_FILE 时返回错误，避免不清楚实际生效的是哪一个。
+func loadSecret(name string) (*secret, error) {
+	s := &secret{name: name, path: strings.TrimSpace(os.Getenv(name + "_FILE"))}
+	if s.path == "" {
+		v := strings.TrimSpace(os.Getenv(name))
+		s.value.Store(&v)
+		return s, nil
+	}
+	if os.Getenv(name) != "" {
+		return nil, fmt.Errorf("%s and %s_FILE are mutually exclusive", name, name)
+	}
+	if _, err := s.reload(); err != nil {
+		return nil, err
+	}
+	fileSecretsMu.Lock()
+	fileSecrets = append(fileSecrets, s)
+	fileSecretsMu.Unlock()
+	log.Printf("secrets: %s loaded from %s", name, s.path)
+	return s, nil
+}
+
+// get 返回当前值；nil secret 视为未配置。
+func (s *secret) get() string {
+	if s == nil {
+		return ""
+	}
+	return *s.value.Load()
+}
+
+// reload 重新读取文件（去掉首尾空白），返回值是否变化；读取失败或文件为空时保留当前值。
+func (s *secret) reload() (bool, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, fmt.Errorf("read %s_FILE: %w", s.name, err)
+	}
+	v := strings.TrimSpace(string(data))
+	if v == "" {
+		return false, fmt.Errorf("%s_FILE %s is empty", s.name, s.path)
+	}
+	if old := s.value.Load(); old != nil && *old == v {
+		return false, nil
+	}
+	s.value.Store(&v)
+	return true, nil
+}
+
+// watchSecrets 每隔 SECRETS_RELOAD_INTERVAL（默认 1m，0 不重新加载）重新读取来自文件的凭证。
+func watchSecrets() {
+	fileSecretsMu.Lock()
+	n := len(fileSecrets)
+	fileSecretsMu.Unlock()
+	interval := envDuration("SECRETS_RELOAD_INTERVAL", defaultSecretsReloadInterval)
+	if n == 0 || interval <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(interval) {
+			fileSecretsMu.Lock()
+			secrets := append([]*secret(nil), fileSecrets...)
+			fileSecretsMu.Unlock()
+			for _, s := range secrets {
+				changed, err := s.reload()
+				if err != nil {
+					log.Printf("secrets: keeping current %s: %v", s.name, err)
+				} else if changed {
+					log.Printf("secrets: %s reloaded from %s", s.name, s.path)
+				}
+			}
+		}
+	}()
+}
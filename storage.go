@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultStorageKeyTemplate = "{date}/{host}/{id}.{ext}"
+	storageUploadTimeout      = 60 * time.Second
+	defaultPresignTTL         = 15 * time.Minute
+	maxPresignTTL             = 7 * 24 * time.Hour
+)
+
+// response_type 取值：binary（默认，直接返回内容）、url（上传后返回限时签名地址）。
+const (
+	responseTypeBinary = "binary"
+	responseTypeURL    = "url"
+)
+
+// objectStore 为截图结果的存储后端（store=true 时上传，并返回对象地址而不是图片本身）。
+type objectStore interface {
+	// put 上传对象并返回可访问的地址；meta 为对象元数据（如 tags），不支持元数据的后端忽略。
+	put(ctx context.Context, key, contentType string, body []byte, meta map[string]string) (string, error)
+	// describe 返回后端信息（/health 与响应中的 bucket 等字段）。
+	describe() gin.H
+}
+
+// presigner 为支持生成限时签名地址的存储后端（response_type=url）。
+type presigner interface {
+	// canPresign 报告当前配置（凭证类型、签名密钥）是否能生成签名地址。
+	canPresign() bool
+	presign(key string, ttl time.Duration, now time.Time) (string, error)
+}
+
+// captureStores 为已配置的存储后端（按名称：s3、gcs、local），未配置任何后端时为空（store=true 返回 400）；
+// defaultStoreName 为请求未指定 storage 时使用的后端。
+var (
+	captureStores    = map[string]objectStore{}
+	defaultStoreName string
+)
+
+// loadCaptureStore 按已配置的环境变量注册存储后端；配置不完整时启动失败。
+// 同时配置多个后端时需要用 STORAGE_BACKEND 指定默认后端。
+func loadCaptureStore() error {
+	s3, err := newS3StoreFromEnv()
+	if err != nil {
+		return fmt.Errorf("s3: %w", err)
+	}
+	if s3 != nil {
+		captureStores["s3"] = s3
+	}
+	gcs, err := newGCSStoreFromEnv()
+	if err != nil {
+		return fmt.Errorf("gcs: %w", err)
+	}
+	if gcs != nil {
+		captureStores["gcs"] = gcs
+	}
+	local, err := newLocalStoreFromEnv()
+	if err != nil {
+		return fmt.Errorf("local: %w", err)
+	}
+	if local != nil {
+		captureStores["local"] = local
+		log.Printf("storage: local backend at %s", local.dir)
+		go local.janitor()
+	}
+
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND")))
+	switch {
+	case name != "":
+		if _, ok := captureStores[name]; !ok {
+			return fmt.Errorf("STORAGE_BACKEND %q is not configured", name)
+		}
+		defaultStoreName = name
+	case len(captureStores) > 1:
+		return errors.New("STORAGE_BACKEND is required when multiple storage backends are configured")
+	default:
+		for n := range captureStores {
+			defaultStoreName = n
+		}
+	}
+	return nil
+}
+
+// lookupStore 返回请求指定（为空时为默认）的存储后端。
+func lookupStore(name string) (objectStore, error) {
+	if len(captureStores) == 0 {
+		return nil, errors.New("store requires a storage backend (set S3_BUCKET, GCS_BUCKET or STORAGE_LOCAL_DIR)")
+	}
+	if name == "" {
+		name = defaultStoreName
+	}
+	store, ok := captureStores[name]
+	if !ok {
+		return nil, fmt.Errorf("storage backend %q is not configured", name)
+	}
+	return store, nil
+}
+
+// presignTTL 读取 STORAGE_PRESIGN_TTL（默认 15 分钟，最长 7 天）。
+func presignTTL() time.Duration {
+	return min(envDuration("STORAGE_PRESIGN_TTL", defaultPresignTTL), maxPresignTTL)
+}
+
+// validateResponseType 校验 response_type；url 隐含 store=true，且存储后端需支持签名地址；
+// json 直接在响应中返回 base64 内容，不能与 store / trace 同时使用。
+func (r *ScreenshotRequest) validateResponseType() error {
+	r.ResponseType = strings.ToLower(strings.TrimSpace(r.ResponseType))
+	switch r.ResponseType {
+	case "", responseTypeBinary:
+		return nil
+	case responseTypeURL:
+		r.Store = true
+		store, err := lookupStore(r.Storage)
+		if err != nil {
+			return err
+		}
+		if p, ok := store.(presigner); !ok || !p.canPresign() {
+			return fmt.Errorf("storage backend %q does not support presigned URLs", store.describe()["type"])
+		}
+		return nil
+	case responseTypeJSON:
+		if r.Store {
+			return errors.New("response_type=json cannot be combined with store")
+		}
+		if r.Trace {
+			return errors.New("response_type=json cannot be combined with trace")
+		}
+		return nil
+	default:
+		return errors.New("response_type must be one of: binary, url, json")
+	}
+}
+
+// storageStats 返回 /health 中的 storage 字段；未配置时为 nil。
+func storageStats() gin.H {
+	if len(captureStores) == 0 {
+		return nil
+	}
+	backends := gin.H{}
+	for name, store := range captureStores {
+		// 本地存储额外返回文件数、占用与清理统计
+		if st, ok := store.(interface{ stats() gin.H }); ok {
+			backends[name] = st.stats()
+			continue
+		}
+		backends[name] = store.describe()
+	}
+	return gin.H{"default": defaultStoreName, "backends": backends}
+}
+
+// StoredObject 为 store=true 时的响应体。
+type StoredObject struct {
+	URL         string   `json:"url"`
+	Key         string   `json:"key"`
+	ContentType string   `json:"content_type"`
+	Size        int      `json:"size"`
+	SHA256      string   `json:"sha256"`
+	StoredAt    string   `json:"stored_at"`
+	SourceURL   string   `json:"source_url,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Backend     gin.H    `json:"backend"`
+}
+
+// storageKey 按模板生成对象键（STORAGE_PREFIX 作为前缀）。占位符：
+// {date} 2006-01-02、{time} 150405、{host} 目标主机名（html 为 "html"）、{hash} 内容 SHA-256 前 16 位、{id} 随机 ID、{ext} 扩展名、
+// {tag} 第一个标签（没有时为 "untagged"）。
+func storageKey(template string, req *ScreenshotRequest, contentType string, body []byte, now time.Time) string {
+	if template == "" {
+		template = defaultStorageKeyTemplate
+	}
+	host := req.targetHost()
+	tag := "untagged"
+	if len(req.Tags) > 0 {
+		tag = req.Tags[0]
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	key := strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("150405"),
+		"{host}", host,
+		"{hash}", sha256Hex(body)[:16],
+		"{id}", hex.EncodeToString(id),
+		"{ext}", strings.TrimPrefix(extensionForContentType(contentType), "."),
+		"{tag}", tag,
+	).Replace(template)
+	if prefix := strings.Trim(os.Getenv("STORAGE_PREFIX"), "/ "); prefix != "" {
+		key = prefix + "/" + key
+	}
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
+
+// storageKeyTemplate 读取 STORAGE_KEY_TEMPLATE（未配置时为 {date}/{host}/{id}.{ext}）。
+func storageKeyTemplate() string {
+	return strings.TrimSpace(os.Getenv("STORAGE_KEY_TEMPLATE"))
+}
+
+// PresignedObject 为 response_type=url 时的响应体。
+type PresignedObject struct {
+	URL         string `json:"url"`
+	ExpiresAt   string `json:"expires_at"`
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+// respondOutput 返回截图结果：store=true 时上传到存储后端并返回对象地址与元数据（JSON），
+// response_type=url 时返回限时签名地址，response_type=json 时返回 base64 内容与页面信息，否则直接返回内容。
+func respondOutput(c *gin.Context, req *ScreenshotRequest, contentType string, body []byte, direct func()) {
+	if req.ResponseType == responseTypeJSON {
+		respondJSONOutput(c, req.output, contentType, body)
+		return
+	}
+	if !req.Store {
+		direct()
+		return
+	}
+	obj, err := storeCapture(req, contentType, body)
+	if err != nil {
+		log.Printf("store: upload failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to store capture", "code": "STORAGE_FAILED", "details": redactURLsInString(err.Error())})
+		return
+	}
+	if req.ResponseType != responseTypeURL {
+		c.JSON(http.StatusOK, obj)
+		return
+	}
+
+	store, _ := lookupStore(req.Storage)
+	p, _ := store.(presigner)
+	ttl := presignTTL()
+	now := time.Now().UTC()
+	signed, err := p.presign(obj.Key, ttl, now)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to presign stored capture", "code": "STORAGE_FAILED", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, PresignedObject{
+		URL:         signed,
+		ExpiresAt:   now.Add(ttl).Format(time.RFC3339),
+		Key:         obj.Key,
+		ContentType: obj.ContentType,
+		Size:        obj.Size,
+		SHA256:      obj.SHA256,
+	})
+}
+
+func storeCapture(req *ScreenshotRequest, contentType string, body []byte) (*StoredObject, error) {
+	store, err := lookupStore(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	key := storageKey(storageKeyTemplate(), req, contentType, body, now)
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageUploadTimeout)
+	defer cancel()
+	objURL, err := store.put(ctx, key, contentType, body, req.storageMetadata())
+	if err != nil {
+		return nil, err
+	}
+	obj := &StoredObject{
+		URL:         objURL,
+		Key:         key,
+		ContentType: contentType,
+		Size:        len(body),
+		SHA256:      sha256Hex(body),
+		StoredAt:    now.Format(time.RFC3339),
+		Tags:        req.Tags,
+		Backend:     store.describe(),
+	}
+	if req.HTML == "" {
+		obj.SourceURL = req.URL
+	}
+	return obj, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryParamNames 为 GET 方式可用的参数名：ScreenshotRequest 的 JSON 字段中除 clip/pdf/html 与 /assert 专用字段之外的全部字段。
+var queryParamNames = func() map[string]bool {
+	names := map[string]bool{}
+	t := reflect.TypeOf(ScreenshotRequest{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		names[name] = true
+	}
+	delete(names, "clip")
+	delete(names, "pdf")
+	delete(names, "html")
+	delete(names, "assertions")
+	delete(names, "screenshot_on_failure")
+	return names
+}()
+
+// strictByDefault 读取 STRICT_VALIDATION（请求未传 strict 时的默认值）。
+func strictByDefault() bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("STRICT_VALIDATION")))
+	return err == nil && v
+}
+
+// decodeRequestBody 解析 JSON 请求体到 v，返回显式出现的顶层字段（小写）与是否启用 strict。
+// strict（请求中的 strict 字段，未传时取 STRICT_VALIDATION）下拒绝未知字段，包括嵌套对象（如 pdf）中的拼写错误。
+func decodeRequestBody(body []byte, v interface{}) (map[string]bool, bool, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return nil, false, errors.New("invalid JSON body")
+	}
+	provided := make(map[string]bool, len(keys))
+	for k := range keys {
+		provided[strings.ToLower(k)] = true
+	}
+	strict := strictByDefault()
+	if raw, ok := keys["strict"]; ok {
+		if err := json.Unmarshal(raw, &strict); err != nil {
+			return nil, false, errors.New("strict must be a boolean")
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return nil, false, fmt.Errorf("unknown parameter %s (strict mode)", field)
+		}
+		return nil, false, errors.New("invalid JSON body")
+	}
+	return provided, strict, nil
+}
+
+// checkQueryParams strict 下拒绝 GET 请求中的未知参数；返回显式出现的参数名。
+func checkQueryParams(c *gin.Context, strict bool) (map[string]bool, error) {
+	provided := map[string]bool{}
+	var unknown []string
+	for k := range c.Request.URL.Query() {
+		provided[k] = true
+		if !queryParamNames[k] {
+			unknown = append(unknown, strconv.Quote(k))
+		}
+	}
+	if strict && len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown parameter %s (strict mode)", strings.Join(unknown, ", "))
+	}
+	return provided, nil
+}
+
+// presetConflict strict 下，若预设/裁剪会改变请求中显式传入的参数，则返回错误而不是静默调整。
+func (r *ScreenshotRequest) presetConflict(key string, conflict bool, format string, args ...interface{}) error {
+	if !r.Strict || !r.provided[key] || !conflict {
+		return nil
+	}
+	return fmt.Errorf(format+" (strict mode)", args...)
+}
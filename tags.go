@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxTags      = 10
+	maxTagLength = 64
+)
+
+// tagPattern 限制标签字符，保证可以直接用于对象键与存储元数据（HTTP 头）。
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]*$`)
+
+// validateTags 校验 tags：最多 10 个，每个 1-64 个字符（字母、数字与 _ . : -），去重并保持顺序。
+func (r *ScreenshotRequest) validateTags() error {
+	if len(r.Tags) > maxTags {
+		return fmt.Errorf("tags must contain at most %d items", maxTags)
+	}
+	seen := make(map[string]bool, len(r.Tags))
+	tags := r.Tags[:0]
+	for i, t := range r.Tags {
+		t = strings.TrimSpace(t)
+		if len(t) > maxTagLength || !tagPattern.MatchString(t) {
+			return fmt.Errorf("tags[%d] must be 1-%d characters of letters, digits, '_', '.', ':' or '-'", i, maxTagLength)
+		}
+		if !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	r.Tags = tags
+	return nil
+}
+
+// storageMetadata 为随对象写入存储后端的元数据（S3 x-amz-meta-*、GCS metadata）；没有时为 nil。
+func (r *ScreenshotRequest) storageMetadata() map[string]string {
+	if len(r.Tags) == 0 {
+		return nil
+	}
+	return map[string]string{"tags": strings.Join(r.Tags, ",")}
+}
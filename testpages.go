@@ -0,0 +1,77 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTestImageDelay 限制 /_test/img.svg 的人为延迟。
+const maxTestImageDelay = 10 * time.Second
+
+//go:embed testpages/*.html
+var testPagesFS embed.FS
+
+// testPagesEnabled 读取 TEST_PAGES（默认开启）；为 false 时不注册 /_test/*。
+func testPagesEnabled() bool {
+	v := strings.TrimSpace(os.Getenv("TEST_PAGES"))
+	if v == "" {
+		return true
+	}
+	enabled, err := strconv.ParseBool(v)
+	return err != nil || enabled
+}
+
+// registerTestPageRoutes 注册内置测试页：/_test/<name>（long、lazy、slow、shadow、iframe、dialog）
+// 与 /_test/img.svg（可带 n、delay 参数的占位图），供集成测试在不依赖外网的情况下截图。
+// 注意页面由上游 Chrome 访问，URL 需使用 Chrome 能访问到的本服务地址。
+func registerTestPageRoutes(r *gin.Engine) {
+	if !testPagesEnabled() {
+		return
+	}
+	r.GET("/_test/*page", func(c *gin.Context) {
+		name := strings.Trim(c.Param("page"), "/")
+		c.Header("Cache-Control", "no-store")
+		if name == "img.svg" {
+			serveTestImage(c)
+			return
+		}
+		if name == "" {
+			name = "index"
+		}
+		var data []byte
+		err := fmt.Errorf("invalid test page %q", name)
+		if !strings.ContainsAny(name, `/\`) {
+			data, err = testPagesFS.ReadFile("testpages/" + strings.TrimSuffix(name, ".html") + ".html")
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "test page not found"})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", data)
+	})
+}
+
+// serveTestImage 返回编号占位图（SVG），delay 为响应前等待的毫秒数（用于懒加载/网络空闲测试）。
+func serveTestImage(c *gin.Context) {
+	n, _ := strconv.Atoi(c.Query("n"))
+	if ms, err := strconv.Atoi(c.Query("delay")); err == nil && ms > 0 {
+		delay := min(time.Duration(ms)*time.Millisecond, maxTestImageDelay)
+		select {
+		case <-time.After(delay):
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+	hue := (n * 67) % 360
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="400" height="300">`+
+		`<rect width="400" height="300" fill="hsl(%d,70%%,60%%)"/>`+
+		`<text x="200" y="170" font-family="sans-serif" font-size="64" text-anchor="middle" fill="#fff">%d</text></svg>`, hue, n)
+	c.Data(http.StatusOK, "image/svg+xml", []byte(svg))
+}
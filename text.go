@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	textFormatPlain = "plain"
+	textFormatANSI  = "ansi"
+	textFormatJSON  = "json"
+
+	defaultTextWidth = 100
+)
+
+// TextBlock 页面可读文本中的一个块（标题/段落/列表项/代码/引用）。
+type TextBlock struct {
+	Type  string `json:"type"`
+	Level int    `json:"level,omitempty"`
+	Text  string `json:"text"`
+}
+
+// PageText 为 /text 的提取结果。
+type PageText struct {
+	Title  string      `json:"title"`
+	URL    string      `json:"url"`
+	Blocks []TextBlock `json:"blocks"`
+}
+
+// readableTextJS 以 Readability 的简化思路提取正文：优先 article/main，跳过导航/页脚/表单等噪声与不可见元素，
+// 按块级结构输出标题、段落、列表项、代码与引用。
+const readableTextJS = `(() => {
+	const root = document.querySelector('article') || document.querySelector('main, [role=main]') || document.body;
+	const skip = new Set(['SCRIPT', 'STYLE', 'NOSCRIPT', 'NAV', 'FOOTER', 'ASIDE', 'FORM', 'IFRAME', 'TEMPLATE', 'BUTTON', 'SELECT', 'svg']);
+	const blocks = [];
+	const clean = (t) => (t || '').replace(/[ \t ]+/g, ' ').replace(/\s*\n\s*/g, '\n').trim();
+	const push = (type, text, level) => {
+		text = type === 'code' ? (text || '').replace(/\s+$/, '') : clean(text);
+		if (text) blocks.push(level ? { type, level, text } : { type, text });
+	};
+	const isBlock = (el) => {
+		const d = getComputedStyle(el).display;
+		return d !== 'inline' && d !== 'inline-block' && d !== 'contents' && d !== 'none';
+	};
+	const walk = (el) => {
+		if (skip.has(el.tagName)) return;
+		const cs = getComputedStyle(el);
+		if (cs.display === 'none' || cs.visibility === 'hidden') return;
+		const tag = el.tagName;
+		if (/^H[1-6]$/.test(tag)) return push('heading', el.innerText, +tag[1]);
+		if (tag === 'P') return push('paragraph', el.innerText);
+		if (tag === 'LI') return push('list_item', el.innerText);
+		if (tag === 'PRE') return push('code', el.innerText);
+		if (tag === 'BLOCKQUOTE') return push('quote', el.innerText);
+		if (!Array.from(el.children).some(isBlock)) return push('paragraph', el.innerText);
+		for (const ch of el.children) walk(ch);
+	};
+	if (root) walk(root);
+	return { title: document.title, url: location.href, blocks };
+})()`
+
+// wrapText 按显示宽度（按 rune 计）折行，保留原有换行。
+func wrapText(s string, width int, indent string) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			lines = append(lines, indent)
+			continue
+		}
+		line := indent
+		lineLen := len([]rune(indent))
+		for _, w := range words {
+			wl := len([]rune(w))
+			if lineLen > len([]rune(indent)) && lineLen+1+wl > width {
+				lines = append(lines, line)
+				line, lineLen = indent, len([]rune(indent))
+			}
+			if lineLen > len([]rune(indent)) {
+				line += " "
+				lineLen++
+			}
+			line += w
+			lineLen += wl
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// renderPageText 将提取结果渲染为纯文本（Markdown 风格）或 ANSI 终端预览。
+func renderPageText(t *PageText, format string, width int) string {
+	const (
+		reset = "\x1b[0m"
+		bold  = "\x1b[1m"
+		dim   = "\x1b[2m"
+		cyan  = "\x1b[36m"
+		green = "\x1b[32m"
+	)
+	ansi := format == textFormatANSI
+	style := func(code, s string) string {
+		if !ansi {
+			return s
+		}
+		return code + s + reset
+	}
+
+	var sb strings.Builder
+	if t.Title != "" {
+		sb.WriteString(style(bold+cyan, t.Title) + "\n")
+		sb.WriteString(style(dim, t.URL) + "\n\n")
+	}
+	for _, b := range t.Blocks {
+		switch b.Type {
+		case "heading":
+			prefix := ""
+			if !ansi {
+				prefix = strings.Repeat("#", b.Level) + " "
+			}
+			sb.WriteString(style(bold, prefix+b.Text) + "\n\n")
+		case "list_item":
+			for i, l := range wrapText(b.Text, width, "  ") {
+				if i == 0 {
+					l = "- " + strings.TrimPrefix(l, "  ")
+				}
+				sb.WriteString(l + "\n")
+			}
+		case "code":
+			for _, l := range strings.Split(b.Text, "\n") {
+				sb.WriteString(style(green, "    "+l) + "\n")
+			}
+			sb.WriteString("\n")
+		case "quote":
+			for _, l := range wrapText(b.Text, width, "> ") {
+				sb.WriteString(style(dim, l) + "\n")
+			}
+			sb.WriteString("\n")
+		default:
+			for _, l := range wrapText(b.Text, width, "") {
+				sb.WriteString(l + "\n")
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func textHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, err := parseRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.applyDefaults()
+		if err := req.validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := req.validateText(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		setEffectiveRequestHeader(c, &req)
+
+		viewportWidth, viewportHeight := req.viewportSize()
+
+		overallCtx, cancel := context.WithTimeout(context.Background(), time.Duration(req.Timeout)*time.Second)
+		defer cancel()
+
+		sess := openChromeSession(c, overallCtx, "textHandler")
+		if sess == nil {
+			return
+		}
+		defer sess.cancel()
+
+		var result PageText
+		budget := newCaptureBudget(time.Duration(req.Timeout)*time.Second, req.BestEffort)
+		actions := navigationActions(&req, viewportWidth, viewportHeight, budget)
+		actions = append(actions, chromedp.EvaluateAsDevTools(readableTextJS, &result))
+
+		if err := chromedp.Run(sess.ctx, actions...); err != nil {
+			respondRunError(c, err, sess.wsURL, "text extraction timeout", "failed to extract text")
+			return
+		}
+		if result.Blocks == nil {
+			result.Blocks = []TextBlock{}
+		}
+
+		if req.TextFormat == textFormatJSON {
+			c.JSON(http.StatusOK, result)
+			return
+		}
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(renderPageText(&result, req.TextFormat, req.TextWidth)))
+	}
+}
+
+// validateText 校验 /text 专用参数并补默认值。
+func (r *ScreenshotRequest) validateText() error {
+	r.TextFormat = strings.ToLower(strings.TrimSpace(r.TextFormat))
+	if r.TextFormat == "" {
+		r.TextFormat = textFormatPlain
+	}
+	if r.TextFormat != textFormatPlain && r.TextFormat != textFormatANSI && r.TextFormat != textFormatJSON {
+		return errors.New("text_format must be one of: plain, ansi, json")
+	}
+	if r.TextWidth == 0 {
+		r.TextWidth = defaultTextWidth
+	}
+	if r.TextWidth < 20 || r.TextWidth > 400 {
+		return errors.New("text_width must be between 20 and 400")
+	}
+	return nil
+}
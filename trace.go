@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/tracing"
+	"github.com/chromedp/chromedp"
+)
+
+// defaultTraceCategories 与 Chrome DevTools Performance 面板录制时使用的类别基本一致，
+// 生成的 trace 可直接拖入 DevTools / Perfetto 查看火焰图。
+var defaultTraceCategories = []string{
+	"-*",
+	"devtools.timeline",
+	"v8.execute",
+	"disabled-by-default-devtools.timeline",
+	"disabled-by-default-devtools.timeline.frame",
+	"toplevel",
+	"blink.console",
+	"blink.user_timing",
+	"latencyInfo",
+	"disabled-by-default-devtools.timeline.stack",
+	"disabled-by-default-v8.cpu_profiler",
+}
+
+// parseTraceCategories 解析逗号分隔的 trace 类别（GET 参数使用）。
+func parseTraceCategories(raw string) []string {
+	var out []string
+	for _, c := range strings.Split(raw, ",") {
+		c = strings.TrimSpace(c)
+		if c != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// traceRecorder 以 ReportEvents 模式收集 Tracing.dataCollected 事件。
+type traceRecorder struct {
+	mu     sync.Mutex
+	events []json.RawMessage
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newTraceRecorder() *traceRecorder {
+	return &traceRecorder{done: make(chan struct{})}
+}
+
+// listen 需在 chromedp.Run 之前注册到 taskCtx 上。
+func (t *traceRecorder) listen(ctx context.Context) {
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch e := ev.(type) {
+		case *tracing.EventDataCollected:
+			t.mu.Lock()
+			for _, v := range e.Value {
+				t.events = append(t.events, json.RawMessage(v))
+			}
+			t.mu.Unlock()
+		case *tracing.EventTracingComplete:
+			t.once.Do(func() { close(t.done) })
+		}
+	})
+}
+
+// start 返回开始录制的 action；以 "-" 开头的类别视为排除项（与 Puppeteer 约定一致）。
+func (t *traceRecorder) start(categories []string) chromedp.Action {
+	if len(categories) == 0 {
+		categories = defaultTraceCategories
+	}
+	cfg := &tracing.TraceConfig{}
+	for _, c := range categories {
+		if strings.HasPrefix(c, "-") {
+			cfg.ExcludedCategories = append(cfg.ExcludedCategories, strings.TrimPrefix(c, "-"))
+		} else {
+			cfg.IncludedCategories = append(cfg.IncludedCategories, c)
+		}
+	}
+	return tracing.Start().
+		WithTransferMode(tracing.TransferModeReportEvents).
+		WithTraceConfig(cfg)
+}
+
+// stop 结束录制并等待 tracingComplete，确保所有 dataCollected 事件都已到达。
+func (t *traceRecorder) stop() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := tracing.End().Do(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-t.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// traceJSON 以 Chrome trace 对象格式（{"traceEvents": [...]}）返回录制结果。
+func (t *traceRecorder) traceJSON() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	events := make([]json.RawMessage, len(t.events))
+	copy(events, t.events)
+	return map[string]interface{}{"traceEvents": events}
+}
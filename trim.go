@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	// defaultTrimTolerance 为未传 trim_tolerance 时每个通道允许的色差（可吸收 JPEG/WebP 压缩噪点）。
+	defaultTrimTolerance = 8
+	// maxTrimPixels 超过该像素数的图片不做裁边（Chrome canvas 面积上限约 2.68 亿像素，且内存开销过大）。
+	maxTrimPixels = 100_000_000
+)
+
+// TrimResult 为各边裁掉的像素数（输出图片像素，含 device_scale）；Skipped 表示图片过大未裁边。
+type TrimResult struct {
+	Top     int  `json:"top"`
+	Right   int  `json:"right"`
+	Bottom  int  `json:"bottom"`
+	Left    int  `json:"left"`
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// validateTrim 校验 trim 相关参数，并补全 trim_tolerance 默认值。
+func (r *ScreenshotRequest) validateTrim() error {
+	if !r.Trim {
+		return nil
+	}
+	if !r.provided["trim_tolerance"] && r.TrimTolerance == 0 {
+		r.TrimTolerance = defaultTrimTolerance
+	}
+	if r.TrimTolerance < 0 || r.TrimTolerance > 255 {
+		return errors.New("trim_tolerance must be between 0 and 255")
+	}
+	if r.TrimMax < 0 {
+		return errors.New("trim_max must be >= 0")
+	}
+	return nil
+}
+
+// trimImageJS 在页面中解码截图、以左上角像素为背景色逐边扫描，裁掉色差不超过 tol（含 alpha）的整行/整列，
+// 再按原格式重新编码。每边最多裁 maxTrim 像素（0 不限制）；整张图为同一颜色时不裁剪。
+const trimImageJS = `async (b64, type, quality, tol, maxTrim, maxPixels) => {
+	const bin = atob(b64);
+	const bytes = new Uint8Array(bin.length);
+	for (let i = 0; i < bin.length; i++) bytes[i] = bin.charCodeAt(i);
+	const bmp = await createImageBitmap(new Blob([bytes], { type }));
+	const w = bmp.width, h = bmp.height;
+	if (w * h > maxPixels) return { top: 0, right: 0, bottom: 0, left: 0, skipped: true };
+
+	const cv = new OffscreenCanvas(w, h);
+	const g = cv.getContext('2d', { willReadFrequently: true });
+	g.drawImage(bmp, 0, 0);
+	const d = g.getImageData(0, 0, w, h).data;
+	const r0 = d[0], g0 = d[1], b0 = d[2], a0 = d[3];
+	const same = (x, y) => {
+		const i = (y * w + x) * 4;
+		return Math.abs(d[i] - r0) <= tol && Math.abs(d[i + 1] - g0) <= tol &&
+			Math.abs(d[i + 2] - b0) <= tol && Math.abs(d[i + 3] - a0) <= tol;
+	};
+	const rowSame = (y, x0, x1) => { for (let x = x0; x < x1; x++) if (!same(x, y)) return false; return true; };
+	const colSame = (x, y0, y1) => { for (let y = y0; y < y1; y++) if (!same(x, y)) return false; return true; };
+	const limit = maxTrim > 0 ? maxTrim : Infinity;
+
+	let top = 0;
+	while (top < h && top < limit && rowSame(top, 0, w)) top++;
+	if (top === h) return { top: 0, right: 0, bottom: 0, left: 0 };
+	let bottom = 0;
+	while (bottom < h - top - 1 && bottom < limit && rowSame(h - 1 - bottom, 0, w)) bottom++;
+	let left = 0;
+	while (left < w - 1 && left < limit && colSame(left, top, h - bottom)) left++;
+	let right = 0;
+	while (right < w - left - 1 && right < limit && colSame(w - 1 - right, top, h - bottom)) right++;
+	if (top + bottom + left + right === 0) return { top, right, bottom, left };
+
+	const ow = w - left - right, oh = h - top - bottom;
+	const out = new OffscreenCanvas(ow, oh);
+	out.getContext('2d').drawImage(cv, left, top, ow, oh, 0, 0, ow, oh);
+	const blob = await out.convertToBlob({ type, quality: quality / 100 });
+	const ob = new Uint8Array(await blob.arrayBuffer());
+	let s = '';
+	for (let i = 0; i < ob.length; i += 0x8000) s += String.fromCharCode.apply(null, ob.subarray(i, i + 0x8000));
+	return { top, right, bottom, left, data: btoa(s) };
+}`
+
+// trimImageAction 对 *img 做自动裁边（在当前 tab 中由 Chrome 解码/编码，支持 png/jpeg/webp），结果写回 *img。
+func trimImageAction(req *ScreenshotRequest, img *[]byte, res *TrimResult) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		args, err := json.Marshal([]interface{}{
+			base64.StdEncoding.EncodeToString(*img), contentTypeForFormat(req.Format),
+			req.Quality, req.TrimTolerance, req.TrimMax, maxTrimPixels,
+		})
+		if err != nil {
+			return err
+		}
+		var out struct {
+			TrimResult
+			Data string `json:"data"`
+		}
+		expr := fmt.Sprintf("(%s)(...%s)", trimImageJS, args)
+		if err := chromedp.EvaluateAsDevTools(expr, &out, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}).Do(ctx); err != nil {
+			return fmt.Errorf("trim: %w", err)
+		}
+		*res = out.TrimResult
+		if out.Data == "" {
+			return nil
+		}
+		buf, err := base64.StdEncoding.DecodeString(out.Data)
+		if err != nil {
+			return fmt.Errorf("trim: %w", err)
+		}
+		*img = buf
+		return nil
+	})
+}
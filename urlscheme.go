@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxDataURLBytes 与 Chrome 的 URL 长度上限（2MB）一致。
+const maxDataURLBytes = 2 << 20
+
+// allowFileURLs 读取 ALLOW_FILE_URLS；为 true 时才允许 file: URL。
+func allowFileURLs() bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv("ALLOW_FILE_URLS")))
+	return err == nil && v
+}
+
+// fileURLAllowedDirs 读取 FILE_URL_ALLOWED_DIRS（逗号分隔的绝对路径，指上游 Chrome 所在文件系统）。
+func fileURLAllowedDirs() []string {
+	var dirs []string
+	for _, d := range strings.Split(os.Getenv("FILE_URL_ALLOWED_DIRS"), ",") {
+		d = strings.TrimSpace(d)
+		if d == "" || !strings.HasPrefix(d, "/") {
+			continue
+		}
+		dirs = append(dirs, path.Clean(d))
+	}
+	return dirs
+}
+
+func urlScheme(raw string) string {
+	scheme, _, ok := strings.Cut(strings.TrimSpace(raw), ":")
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
+
+// validateDataURL data: URL 始终允许，但受 maxDataURLBytes 限制。
+func validateDataURL(raw string) error {
+	if len(raw) > maxDataURLBytes {
+		return fmt.Errorf("data: url must be at most %d bytes", maxDataURLBytes)
+	}
+	if !strings.Contains(raw, ",") {
+		return errors.New("data: url must contain a comma separating metadata and data")
+	}
+	return nil
+}
+
+// validateFileURL file: URL 需 ALLOW_FILE_URLS=true，且路径位于 FILE_URL_ALLOWED_DIRS 之一内。
+// 文件由上游 Chrome 读取，路径指的是 Chrome 所在机器/容器的文件系统；无法在本服务侧解析符号链接。
+func validateFileURL(raw string) error {
+	if !allowFileURLs() {
+		return errors.New("file: urls are disabled, set ALLOW_FILE_URLS=true to enable")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Opaque != "" {
+		return errors.New("url must be a valid file:///absolute/path URL")
+	}
+	if u.Host != "" && !strings.EqualFold(u.Host, "localhost") {
+		return errors.New("file: url must not have a remote host")
+	}
+	if !strings.HasPrefix(u.Path, "/") {
+		return errors.New("url must be a valid file:///absolute/path URL")
+	}
+	p := path.Clean(u.Path)
+	for _, dir := range fileURLAllowedDirs() {
+		if p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("file path %q is not under FILE_URL_ALLOWED_DIRS", p)
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestValidateFileURL(t *testing.T) {
+	t.Setenv("ALLOW_FILE_URLS", "true")
+	t.Setenv("FILE_URL_ALLOWED_DIRS", "/srv/pages, relative, /data/")
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"file:///srv/pages/index.html", true},
+		{"file:///srv/pages", true},
+		{"file://localhost/data/a.html", true},
+		{"file:///srv/pages/../secrets/key", false},
+		{"file:///srv/pages-other/index.html", false},
+		{"file:///etc/passwd", false},
+		{"file://remote.host/srv/pages/index.html", false},
+		{"file:srv/pages/index.html", false},
+		{"file:///relative/index.html", false},
+	}
+	for _, tt := range tests {
+		if err := validateFileURL(tt.url); (err == nil) != tt.ok {
+			t.Errorf("validateFileURL(%q) = %v, want ok=%v", tt.url, err, tt.ok)
+		}
+	}
+
+	t.Setenv("ALLOW_FILE_URLS", "false")
+	if err := validateFileURL("file:///srv/pages/index.html"); err == nil {
+		t.Error("file: url accepted with ALLOW_FILE_URLS=false")
+	}
+}
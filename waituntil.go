@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	waitUntilLoad             = "load"
+	waitUntilDOMContentLoaded = "domcontentloaded"
+	waitUntilNetworkIdle0     = "networkidle0"
+	waitUntilNetworkIdle2     = "networkidle2"
+
+	// networkIdleWindow 为 networkidle 要求的持续空闲时长（与 Puppeteer 一致）。
+	networkIdleWindow = 500 * time.Millisecond
+)
+
+// validateWaitUntil 校验 wait_until：为空（等同 load）、load、domcontentloaded、networkidle0 或 networkidle2。
+func (r *ScreenshotRequest) validateWaitUntil() error {
+	switch r.WaitUntil {
+	case "", waitUntilLoad, waitUntilDOMContentLoaded, waitUntilNetworkIdle0, waitUntilNetworkIdle2:
+		return nil
+	}
+	return errors.New("wait_until must be load, domcontentloaded, networkidle0 or networkidle2")
+}
+
+// networkIdleTracker 为 wait_until=networkidle0/2 创建请求计数器，其他取值返回 nil。
+func (r *ScreenshotRequest) networkIdleTracker() *networkIdleTracker {
+	switch r.WaitUntil {
+	case waitUntilNetworkIdle0:
+		return newNetworkIdleTracker(0)
+	case waitUntilNetworkIdle2:
+		return newNetworkIdleTracker(2)
+	}
+	return nil
+}
+
+// domContentLoadedNavigateAction 导航到 url，在主文档触发 DOMContentLoaded 后即返回，不等待图片等子资源（load 事件）。
+func domContentLoadedNavigateAction(url, referer string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		lctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		fired := make(chan struct{}, 1)
+		chromedp.ListenTarget(lctx, func(ev interface{}) {
+			if _, ok := ev.(*page.EventDomContentEventFired); ok {
+				select {
+				case fired <- struct{}{}:
+				default:
+				}
+			}
+		})
+
+		nav := page.Navigate(url)
+		if referer != "" {
+			nav = nav.WithReferrer(referer).WithReferrerPolicy(page.ReferrerPolicyUnsafeURL)
+		}
+		_, loaderID, errorText, _, err := nav.Do(ctx)
+		if err != nil {
+			return err
+		}
+		if errorText != "" {
+			return fmt.Errorf("page load error %s", errorText)
+		}
+		// 同文档导航（仅 fragment 变化）不会产生新的文档
+		if loaderID == "" {
+			return nil
+		}
+		select {
+		case <-fired:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+const maxWebStorageBytes = 1 << 20
+
+// validateWebStorage 校验 local_storage / session_storage：只能写入截图目标（http/https）的源，总大小不超过 1MB。
+func (r *ScreenshotRequest) validateWebStorage() error {
+	if len(r.LocalStorage) == 0 && len(r.SessionStorage) == 0 {
+		return nil
+	}
+	if r.HTML != "" {
+		return errors.New("local_storage and session_storage require url")
+	}
+	if webStorageOrigin(r.URL) == "" {
+		return errors.New("local_storage and session_storage require an http/https url")
+	}
+	size := 0
+	for _, m := range []map[string]string{r.LocalStorage, r.SessionStorage} {
+		for k, v := range m {
+			size += len(k) + len(v)
+		}
+	}
+	if size > maxWebStorageBytes {
+		return errors.New("local_storage and session_storage must be at most 1MB in total")
+	}
+	return nil
+}
+
+// webStorageOrigin 返回 http/https 地址的源（scheme://host[:port]），与页面中的 location.origin 一致。
+func webStorageOrigin(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// webStorageAction 通过 Page.addScriptToEvaluateOnNewDocument 在页面脚本执行前写入 localStorage / sessionStorage。
+// 只作用于与截图目标同源的顶层文档（多步导航的中间站不受影响）；每次加载该源的文档都会重新写入。
+func webStorageAction(req *ScreenshotRequest) chromedp.Action {
+	origin, _ := json.Marshal(webStorageOrigin(req.URL))
+	local, _ := json.Marshal(req.LocalStorage)
+	session, _ := json.Marshal(req.SessionStorage)
+	script := `(() => {
+  if (window !== window.top || location.origin !== ` + string(origin) + `) return;
+  const seed = (storage, items) => {
+    if (!items) return;
+    try {
+      for (const [k, v] of Object.entries(items)) storage.setItem(k, v);
+    } catch (e) {}
+  };
+  seed(window.localStorage, ` + string(local) + `);
+  seed(window.sessionStorage, ` + string(session) + `);
+})();`
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		_, err := page.AddScriptToEvaluateOnNewDocument(script).Do(ctx)
+		return err
+	})
+}
+
+// redactedValues 返回键不变、值替换为 REDACTED 的副本（用于 X-Effective-Request）。
+func redactedValues(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k := range m {
+		out[k] = "REDACTED"
+	}
+	return out
+}